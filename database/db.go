@@ -2,20 +2,25 @@ package database
 
 import (
 	"database/sql"
-	"errors"
 	"fmt"
-	"log"
 	"os"
+	"strconv"
+	"time"
 
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
+// Connection Pool defaults. Each of them can be overridden by the env variables below.
+const (
+	defaultMaxOpenConns    = 25
+	defaultMaxIdleConns    = 25
+	defaultConnMaxLifetime = 30 * time.Minute
+	defaultConnMaxIdleTime = 5 * time.Minute
+)
+
+// The method opens the Connection Pool shared by the whole application.
+// It should be called once on startup, the returned DB is safe for concurrent use.
 func ConnectToDB() (*sql.DB, error) {
-	errEnv := godotenv.Load(".env")
-	if errEnv != nil {
-		log.Fatalf("Error loading .env file")
-	}
 	var (
 		host     = os.Getenv("DB_HOST")
 		port     = os.Getenv("DB_PORT")
@@ -29,14 +34,35 @@ func ConnectToDB() (*sql.DB, error) {
 
 	db, err := sql.Open("postgres", psqlInfo)
 	if err != nil {
-		log.Println("connectoToDB1", err)
-		return nil, errors.New(err.Error())
+		return nil, fmt.Errorf("Error opening the DB: %w", err)
 	}
 
+	db.SetMaxOpenConns(envInt("DB_MAX_OPEN_CONNS", defaultMaxOpenConns))
+	db.SetMaxIdleConns(envInt("DB_MAX_IDLE_CONNS", defaultMaxIdleConns))
+	db.SetConnMaxLifetime(envDuration("DB_CONN_MAX_LIFETIME", defaultConnMaxLifetime))
+	db.SetConnMaxIdleTime(envDuration("DB_CONN_MAX_IDLE_TIME", defaultConnMaxIdleTime))
+
 	if err = db.Ping(); err != nil {
-		log.Println("connectoToDB2", err)
-		return nil, errors.New(err.Error())
+		db.Close()
+		return nil, fmt.Errorf("Error connecting to the DB: %w", err)
 	}
 
 	return db, nil
 }
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// Durations are set in Go format, e.g. "30m" or "90s"
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
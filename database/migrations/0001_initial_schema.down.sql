@@ -0,0 +1,25 @@
+DROP TABLE IF EXISTS requested_materials;
+
+DROP TYPE IF EXISTS REQUEST_STATUS;
+
+DROP TABLE IF EXISTS incoming_materials;
+
+DROP TABLE IF EXISTS users;
+
+DROP TYPE IF EXISTS ROLE;
+
+DROP TABLE IF EXISTS transactions_log;
+
+DROP TABLE IF EXISTS prices;
+
+DROP TABLE IF EXISTS materials;
+
+DROP TYPE IF EXISTS OWNER;
+
+DROP TYPE IF EXISTS MATERIAL_TYPE;
+
+DROP TABLE IF EXISTS locations;
+
+DROP TABLE IF EXISTS warehouses;
+
+DROP TABLE IF EXISTS customers;
@@ -1,22 +1,5 @@
-CREATE DATABASE tag_db;
-
-DROP TABLE IF EXISTS transactions_log;
-
-DROP TABLE IF EXISTS prices;
-
-DROP TABLE IF EXISTS materials;
-
-DROP TABLE IF EXISTS incoming_materials;
-
-DROP TABLE IF EXISTS customers;
-
-DROP TABLE IF EXISTS locations;
-
-DROP TABLE IF EXISTS warehouses;
-
-DROP TYPE IF EXISTS material_type;
-
-DROP TYPE IF EXISTS owner;
+-- The schema of the former database/db.sql. Existing databases built from it already have it,
+-- so every statement is skipped if its table or type exists and running it there only records version 1.
 
 CREATE TABLE IF NOT EXISTS customers (
 	customer_id SERIAL PRIMARY KEY,
@@ -36,27 +19,35 @@ CREATE TABLE IF NOT EXISTS locations (
 	CONSTRAINT unique_location_name_warehouse_id UNIQUE (name, warehouse_id)
 );
 
-CREATE TYPE MATERIAL_TYPE AS ENUM (
-	'ACT LABEL',
-	'BUBBLE',
-	'BURGO',
-	'CARRIER',
-	'ENVELOPE',
-	'FREE SHIPPING',
-	'INSERT',
-	'KEYCHAIN',
-	'LABELS',
-	'PAPER',
-	'PRINT',
-	'RIBBON',
-	'SHIPPING',
-	'STICKER',
-	'WEARABLE',
-	'CHIPS',
-	'CARDS'
-);
-
-CREATE TYPE OWNER AS ENUM ('Tag', 'Customer');
+DO $$ BEGIN
+	CREATE TYPE MATERIAL_TYPE AS ENUM (
+		'ACT LABEL',
+		'BUBBLE',
+		'BURGO',
+		'CARRIER',
+		'ENVELOPE',
+		'FREE SHIPPING',
+		'INSERT',
+		'KEYCHAIN',
+		'LABELS',
+		'PAPER',
+		'PRINT',
+		'RIBBON',
+		'SHIPPING',
+		'STICKER',
+		'WEARABLE',
+		'CHIPS',
+		'CARDS'
+	);
+EXCEPTION
+	WHEN duplicate_object THEN NULL;
+END $$;
+
+DO $$ BEGIN
+	CREATE TYPE OWNER AS ENUM ('Tag', 'Customer');
+EXCEPTION
+	WHEN duplicate_object THEN NULL;
+END $$;
 
 CREATE TABLE IF NOT EXISTS materials (
 	material_id SERIAL PRIMARY KEY,
@@ -94,6 +85,25 @@ CREATE TABLE IF NOT EXISTS transactions_log (
 	serial_number_range VARCHAR(100)
 );
 
+DO $$ BEGIN
+	CREATE TYPE ROLE AS ENUM (
+		'admin',
+		'warehouse',
+		'csr',
+		'production',
+		'vault'
+	);
+EXCEPTION
+	WHEN duplicate_object THEN NULL;
+END $$;
+
+CREATE TABLE IF NOT EXISTS users (
+	user_id SERIAL PRIMARY KEY,
+	username VARCHAR(100) NOT NULL,
+	password VARCHAR(100) NOT NULL,
+	role ROLE NOT NULL
+);
+
 CREATE TABLE IF NOT EXISTS incoming_materials (
 	shipping_id SERIAL PRIMARY KEY,
 	customer_id INT REFERENCES customers (customer_id) NOT NULL,
@@ -109,22 +119,11 @@ CREATE TABLE IF NOT EXISTS incoming_materials (
 	user_id INT REFERENCES users (user_id) NOT NULL
 );
 
-CREATE TYPE ROLE AS ENUM (
-	'admin',
-	'warehouse',
-	'csr',
-	'production',
-	'vault'
-);
-
-CREATE TABLE IF NOT EXISTS users (
-	user_id SERIAL PRIMARY KEY,
-	username VARCHAR(100) NOT NULL,
-	password VARCHAR(100) NOT NULL,
-	role ROLE NOT NULL
-);
-
-CREATE TYPE REQUEST_STATUS AS ENUM ('pending', 'sent', 'declined');
+DO $$ BEGIN
+	CREATE TYPE REQUEST_STATUS AS ENUM ('pending', 'sent', 'declined');
+EXCEPTION
+	WHEN duplicate_object THEN NULL;
+END $$;
 
 CREATE TABLE IF NOT EXISTS requested_materials (
 	request_id SERIAL PRIMARY KEY,
@@ -137,4 +136,4 @@ CREATE TABLE IF NOT EXISTS requested_materials (
 	notes TEXT NOT NULL,
 	updated_at DATE,
 	requested_at DATE
-);
\ No newline at end of file
+);
@@ -0,0 +1,5 @@
+ALTER TABLE prices
+	DROP CONSTRAINT IF EXISTS prices_quantity_non_negative;
+
+ALTER TABLE materials
+	DROP CONSTRAINT IF EXISTS materials_quantity_non_negative;
@@ -0,0 +1,5 @@
+ALTER TABLE materials
+	ADD CONSTRAINT materials_quantity_non_negative CHECK (quantity >= 0);
+
+ALTER TABLE prices
+	ADD CONSTRAINT prices_quantity_non_negative CHECK (quantity >= 0);
@@ -0,0 +1,10 @@
+-- Passwords are stored as bcrypt hashes (60 chars), plaintext rows are upgraded on the next login
+CREATE TABLE IF NOT EXISTS sessions (
+	session_id SERIAL PRIMARY KEY,
+	token_hash CHAR(64) NOT NULL UNIQUE,
+	user_id INT REFERENCES users (user_id) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS sessions_expires_at_idx ON sessions (expires_at);
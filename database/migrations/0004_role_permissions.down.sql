@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+
+CREATE TYPE ROLE AS ENUM (
+	'admin',
+	'warehouse',
+	'csr',
+	'production',
+	'vault'
+);
+
+ALTER TABLE users
+	DROP CONSTRAINT IF EXISTS users_role_fkey;
+
+-- Fails if a User has a Role added after the migration
+ALTER TABLE users
+	ALTER COLUMN role TYPE ROLE USING role::ROLE;
+
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,80 @@
+-- Roles and their Permissions are rows instead of the ROLE enum, so they can be changed without a release
+CREATE TABLE IF NOT EXISTS roles (
+	name VARCHAR(50) PRIMARY KEY,
+	description VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+INSERT INTO roles (name, description) VALUES
+	('admin', 'Full access including users and roles'),
+	('warehouse', 'Receives, moves and removes materials'),
+	('csr', 'Sends incoming materials and manages customers'),
+	('production', 'Requests materials'),
+	('vault', 'Handles the vault materials (CARDS, CHIPS)'),
+	('viewer', 'Read-only access to materials and reports');
+
+ALTER TABLE users
+	ALTER COLUMN role TYPE VARCHAR(50) USING role::TEXT;
+
+ALTER TABLE users
+	ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES roles (name);
+
+DROP TYPE ROLE;
+
+CREATE TABLE IF NOT EXISTS permissions (
+	name VARCHAR(50) PRIMARY KEY,
+	description VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+INSERT INTO permissions (name, description) VALUES
+	('materials.read', 'View materials, incoming and requested materials, locations, customers and warehouses'),
+	('reports.read', 'View the transaction and balance reports'),
+	('incoming.create', 'Send incoming materials'),
+	('incoming.update', 'Edit incoming materials including their cost'),
+	('materials.receive', 'Place incoming materials into locations'),
+	('materials.move', 'Move materials between locations'),
+	('materials.remove', 'Remove material quantities'),
+	('materials.update', 'Edit materials'),
+	('vault.manage', 'Receive, move and remove the vault materials (CARDS, CHIPS)'),
+	('requests.create', 'Request materials'),
+	('requests.update', 'Process requested materials'),
+	('customers.create', 'Create customers'),
+	('warehouses.create', 'Create warehouses and locations'),
+	('data.import', 'Import data'),
+	('roles.manage', 'Change the permissions of the roles');
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+	role VARCHAR(50) REFERENCES roles (name) ON DELETE CASCADE,
+	permission VARCHAR(50) REFERENCES permissions (name) ON DELETE CASCADE,
+	PRIMARY KEY (role, permission)
+);
+
+INSERT INTO role_permissions (role, permission)
+	SELECT 'admin', name FROM permissions;
+
+INSERT INTO role_permissions (role, permission) VALUES
+	('warehouse', 'materials.read'),
+	('warehouse', 'reports.read'),
+	('warehouse', 'incoming.create'),
+	('warehouse', 'materials.receive'),
+	('warehouse', 'materials.move'),
+	('warehouse', 'materials.remove'),
+	('warehouse', 'materials.update'),
+	('warehouse', 'requests.update'),
+	('warehouse', 'warehouses.create'),
+	('csr', 'materials.read'),
+	('csr', 'reports.read'),
+	('csr', 'incoming.create'),
+	('csr', 'incoming.update'),
+	('csr', 'customers.create'),
+	('csr', 'requests.create'),
+	('production', 'materials.read'),
+	('production', 'requests.create'),
+	('vault', 'materials.read'),
+	('vault', 'reports.read'),
+	('vault', 'materials.receive'),
+	('vault', 'materials.move'),
+	('vault', 'materials.remove'),
+	('vault', 'vault.manage'),
+	('vault', 'requests.update'),
+	('viewer', 'materials.read'),
+	('viewer', 'reports.read');
@@ -0,0 +1,7 @@
+DELETE FROM permissions WHERE name = 'users.manage';
+
+ALTER TABLE users
+	DROP CONSTRAINT IF EXISTS users_username_key;
+
+ALTER TABLE users
+	DROP COLUMN IF EXISTS is_active;
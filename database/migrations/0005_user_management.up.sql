@@ -0,0 +1,12 @@
+-- Deactivated Users cannot log in, but are kept for the history of their materials and requests
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT true;
+
+ALTER TABLE users
+	ADD CONSTRAINT users_username_key UNIQUE (username);
+
+INSERT INTO permissions (name, description) VALUES
+	('users.manage', 'Create users, change their roles and passwords, deactivate them');
+
+INSERT INTO role_permissions (role, permission) VALUES
+	('admin', 'users.manage');
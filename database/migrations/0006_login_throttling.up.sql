@@ -0,0 +1,21 @@
+-- Failed logins per "user:<username>" and "ip:<address>" key, a key is locked until locked_until
+CREATE TABLE IF NOT EXISTS login_throttles (
+	throttle_key VARCHAR(300) PRIMARY KEY,
+	failures INT NOT NULL DEFAULT 0,
+	last_failure_at TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP NOT NULL
+);
+
+-- Every login attempt, user_id is NULL for unknown usernames
+CREATE TABLE IF NOT EXISTS login_history (
+	login_id SERIAL PRIMARY KEY,
+	username VARCHAR(255) NOT NULL,
+	user_id INT REFERENCES users (user_id),
+	ip_address VARCHAR(64) NOT NULL,
+	success BOOLEAN NOT NULL,
+	reason VARCHAR(50) NOT NULL,
+	attempted_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS login_history_username_idx ON login_history (username, attempted_at);
+CREATE INDEX IF NOT EXISTS login_history_attempted_at_idx ON login_history (attempted_at);
@@ -0,0 +1,19 @@
+-- Service accounts authenticate with API keys only, never with a password
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS is_service BOOLEAN NOT NULL DEFAULT false;
+
+-- Only the SHA-256 hash of a key is stored, the prefix lets people tell the keys apart
+CREATE TABLE IF NOT EXISTS api_keys (
+	key_id SERIAL PRIMARY KEY,
+	user_id INT REFERENCES users (user_id) NOT NULL,
+	name VARCHAR(100) NOT NULL,
+	key_prefix VARCHAR(16) NOT NULL,
+	key_hash CHAR(64) NOT NULL UNIQUE,
+	created_by INT REFERENCES users (user_id) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP,
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
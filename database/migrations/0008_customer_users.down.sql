@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS user_customers;
+
+UPDATE users SET role = 'viewer' WHERE role = 'customer';
+DELETE FROM roles WHERE name = 'customer';
+
+ALTER TABLE roles
+	DROP COLUMN IF EXISTS customer_scoped;
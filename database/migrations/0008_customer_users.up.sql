@@ -0,0 +1,16 @@
+-- Users of a customer scoped Role see the materials and reports of their own customers only
+ALTER TABLE roles
+	ADD COLUMN IF NOT EXISTS customer_scoped BOOLEAN NOT NULL DEFAULT false;
+
+INSERT INTO roles (name, description, customer_scoped) VALUES
+	('customer', 'Customer portal: read-only access to the own materials and reports', true);
+
+INSERT INTO role_permissions (role, permission) VALUES
+	('customer', 'materials.read'),
+	('customer', 'reports.read');
+
+CREATE TABLE IF NOT EXISTS user_customers (
+	user_id INT REFERENCES users (user_id) ON DELETE CASCADE NOT NULL,
+	customer_id INT REFERENCES customers (customer_id) NOT NULL,
+	PRIMARY KEY (user_id, customer_id)
+);
@@ -0,0 +1,6 @@
+ALTER TABLE transactions_log
+	DROP COLUMN IF EXISTS approved_by,
+	DROP COLUMN IF EXISTS user_id;
+
+DROP TABLE IF EXISTS pending_operations;
+DROP TABLE IF EXISTS dual_control_types;
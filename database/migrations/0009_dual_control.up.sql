@@ -0,0 +1,31 @@
+-- Moves and removals of these material types wait for the approval of a second vault User
+CREATE TABLE IF NOT EXISTS dual_control_types (
+	material_type VARCHAR(50) PRIMARY KEY
+);
+
+INSERT INTO dual_control_types (material_type) VALUES
+	('CARDS'),
+	('CHIPS');
+
+CREATE TABLE IF NOT EXISTS pending_operations (
+	operation_id SERIAL PRIMARY KEY,
+	operation VARCHAR(10) NOT NULL CHECK (operation IN ('move', 'remove')),
+	material_id INT REFERENCES materials (material_id) NOT NULL,
+	quantity INT NOT NULL CHECK (quantity > 0),
+	location_id INT REFERENCES locations (location_id),
+	job_ticket VARCHAR(100) NOT NULL DEFAULT '',
+	serial_number_range VARCHAR(100) NOT NULL DEFAULT '',
+	status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+	requested_by INT REFERENCES users (user_id) NOT NULL,
+	requested_at TIMESTAMP NOT NULL,
+	decided_by INT REFERENCES users (user_id),
+	decided_at TIMESTAMP,
+	CONSTRAINT pending_operations_second_user CHECK (status <> 'approved' OR decided_by <> requested_by)
+);
+
+CREATE INDEX IF NOT EXISTS pending_operations_status_idx ON pending_operations (status, requested_at);
+
+-- The User who made the change and the one who approved it
+ALTER TABLE transactions_log
+	ADD COLUMN IF NOT EXISTS user_id INT REFERENCES users (user_id),
+	ADD COLUMN IF NOT EXISTS approved_by INT REFERENCES users (user_id);
@@ -0,0 +1,35 @@
+-- Every mutating service call adds a row, the rows are never changed or deleted
+CREATE TABLE IF NOT EXISTS audit_log (
+	audit_id SERIAL PRIMARY KEY,
+	user_id INT REFERENCES users (user_id),
+	action VARCHAR(50) NOT NULL,
+	entity VARCHAR(50) NOT NULL,
+	entity_id VARCHAR(100) NOT NULL DEFAULT '',
+	changes JSONB NOT NULL DEFAULT '{}',
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity, entity_id);
+CREATE INDEX IF NOT EXISTS audit_log_user_idx ON audit_log (user_id, created_at);
+
+CREATE OR REPLACE FUNCTION audit_log_immutable() RETURNS TRIGGER AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log rows cannot be changed or deleted';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_immutable ON audit_log;
+CREATE TRIGGER audit_log_immutable
+	BEFORE UPDATE OR DELETE ON audit_log
+	FOR EACH ROW EXECUTE FUNCTION audit_log_immutable();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+CREATE TRIGGER audit_log_no_truncate
+	BEFORE TRUNCATE ON audit_log
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_log_immutable();
+
+INSERT INTO permissions (name, description) VALUES
+	('audit.read', 'Read the audit log of all changes');
+
+INSERT INTO role_permissions (role, permission) VALUES
+	('admin', 'audit.read');
@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS transactions_log_no_truncate ON transactions_log;
+DROP TRIGGER IF EXISTS transactions_log_immutable ON transactions_log;
+DROP FUNCTION IF EXISTS transactions_log_immutable();
+
+DROP TABLE IF EXISTS transactions_log_head;
+
+ALTER TABLE transactions_log
+	DROP COLUMN IF EXISTS row_hash,
+	DROP COLUMN IF EXISTS prev_hash;
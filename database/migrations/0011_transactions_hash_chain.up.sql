@@ -0,0 +1,30 @@
+-- Every Transaction Log keeps the SHA-256 of its content and of the previous row's hash.
+-- The rows written before this migration are not chained and keep empty hashes.
+ALTER TABLE transactions_log
+	ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS row_hash VARCHAR(64) NOT NULL DEFAULT '';
+
+-- The last chained row, its lock orders concurrent inserts
+CREATE TABLE IF NOT EXISTS transactions_log_head (
+	single_row BOOLEAN PRIMARY KEY DEFAULT true CHECK (single_row),
+	transaction_id INT NOT NULL DEFAULT 0,
+	row_hash VARCHAR(64) NOT NULL DEFAULT ''
+);
+
+INSERT INTO transactions_log_head (single_row) VALUES (true) ON CONFLICT DO NOTHING;
+
+CREATE OR REPLACE FUNCTION transactions_log_immutable() RETURNS TRIGGER AS $$
+BEGIN
+	RAISE EXCEPTION 'transactions_log rows cannot be changed or deleted';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS transactions_log_immutable ON transactions_log;
+CREATE TRIGGER transactions_log_immutable
+	BEFORE UPDATE OR DELETE ON transactions_log
+	FOR EACH ROW EXECUTE FUNCTION transactions_log_immutable();
+
+DROP TRIGGER IF EXISTS transactions_log_no_truncate ON transactions_log;
+CREATE TRIGGER transactions_log_no_truncate
+	BEFORE TRUNCATE ON transactions_log
+	FOR EACH STATEMENT EXECUTE FUNCTION transactions_log_immutable();
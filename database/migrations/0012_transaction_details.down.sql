@@ -0,0 +1,12 @@
+ALTER TABLE pending_operations
+	DROP COLUMN IF EXISTS request_id;
+
+ALTER TABLE transactions_log
+	DROP COLUMN IF EXISTS correlation_id,
+	DROP COLUMN IF EXISTS request_id,
+	DROP COLUMN IF EXISTS shipping_id,
+	DROP COLUMN IF EXISTS to_location_id,
+	DROP COLUMN IF EXISTS from_location_id,
+	DROP COLUMN IF EXISTS transaction_type;
+
+DROP TYPE IF EXISTS TRANSACTION_TYPE;
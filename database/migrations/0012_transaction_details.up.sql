@@ -0,0 +1,25 @@
+CREATE TYPE TRANSACTION_TYPE AS ENUM (
+	'receipt',
+	'move_out',
+	'move_in',
+	'issue',
+	'adjustment',
+	'import',
+	'reversal'
+);
+
+-- The rows written before keep no type, locations or references.
+-- correlation_id is shared by all rows of one stock change, e.g. both halves of a move.
+ALTER TABLE transactions_log
+	ADD COLUMN IF NOT EXISTS transaction_type TRANSACTION_TYPE,
+	ADD COLUMN IF NOT EXISTS from_location_id INT REFERENCES locations (location_id),
+	ADD COLUMN IF NOT EXISTS to_location_id INT REFERENCES locations (location_id),
+	ADD COLUMN IF NOT EXISTS shipping_id INT,
+	ADD COLUMN IF NOT EXISTS request_id INT REFERENCES requested_materials (request_id),
+	ADD COLUMN IF NOT EXISTS correlation_id VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS transactions_log_correlation_idx ON transactions_log (correlation_id);
+
+-- A removal waiting for an approval keeps the Request it fulfils
+ALTER TABLE pending_operations
+	ADD COLUMN IF NOT EXISTS request_id INT REFERENCES requested_materials (request_id);
@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE name = 'transactions.reverse';
+
+ALTER TABLE transactions_log
+	DROP COLUMN IF EXISTS reverses_id;
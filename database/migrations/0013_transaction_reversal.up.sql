@@ -0,0 +1,11 @@
+-- A reversal row points to the row it compensates, every row can be reversed once
+ALTER TABLE transactions_log
+	ADD COLUMN IF NOT EXISTS reverses_id INT REFERENCES transactions_log (transaction_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS transactions_log_reverses_idx ON transactions_log (reverses_id);
+
+INSERT INTO permissions (name, description) VALUES
+	('transactions.reverse', 'Reverse mistaken removals and moves');
+
+INSERT INTO role_permissions (role, permission) VALUES
+	('admin', 'transactions.reverse');
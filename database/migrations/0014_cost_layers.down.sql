@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS prices_layers_idx;
+
+-- Fails while a Material has several layers with the same cost
+ALTER TABLE prices
+	DROP COLUMN IF EXISTS received_at,
+	DROP COLUMN IF EXISTS original_quantity,
+	ADD CONSTRAINT unique_material_id_cost UNIQUE (material_id, cost);
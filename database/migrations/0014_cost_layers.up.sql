@@ -0,0 +1,29 @@
+-- Every receipt is its own cost layer, consumed oldest first
+ALTER TABLE prices
+	ADD COLUMN IF NOT EXISTS received_at TIMESTAMP,
+	ADD COLUMN IF NOT EXISTS original_quantity INT;
+
+-- Existing layers may hold several merged receipts, they keep the first receipt date and the received total
+UPDATE prices p SET
+	received_at = r.received_at,
+	original_quantity = GREATEST(p.quantity, r.quantity)
+FROM (
+	SELECT price_id, MIN(updated_at) AS received_at, SUM(quantity_change) AS quantity
+	FROM transactions_log
+	WHERE quantity_change > 0
+	GROUP BY price_id
+) r
+WHERE r.price_id = p.price_id;
+
+UPDATE prices SET
+	received_at = NOW(),
+	original_quantity = quantity
+WHERE received_at IS NULL;
+
+ALTER TABLE prices
+	ALTER COLUMN received_at SET NOT NULL,
+	ALTER COLUMN received_at SET DEFAULT NOW(),
+	ALTER COLUMN original_quantity SET NOT NULL,
+	DROP CONSTRAINT IF EXISTS unique_material_id_cost;
+
+CREATE INDEX IF NOT EXISTS prices_layers_idx ON prices (material_id, received_at, price_id);
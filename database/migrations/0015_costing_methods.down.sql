@@ -0,0 +1,6 @@
+DELETE FROM permissions WHERE name = 'costing.manage';
+
+ALTER TABLE pending_operations
+	DROP COLUMN IF EXISTS layers;
+
+DROP TABLE IF EXISTS costing_methods;
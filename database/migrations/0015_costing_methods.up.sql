@@ -0,0 +1,20 @@
+-- Costing method of the Materials of an Owner, a Customer or both, the Materials without a rule use FIFO
+CREATE TABLE IF NOT EXISTS costing_methods (
+	costing_id SERIAL PRIMARY KEY,
+	owner OWNER,
+	customer_id INT REFERENCES customers (customer_id),
+	method VARCHAR(10) NOT NULL CHECK (method IN ('fifo', 'average', 'specific')),
+	CONSTRAINT costing_methods_scope CHECK (owner IS NOT NULL OR customer_id IS NOT NULL)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS costing_methods_scope_idx ON costing_methods (COALESCE(owner::TEXT, ''), COALESCE(customer_id, 0));
+
+-- The cost layers chosen by the requesting User for the specific identification
+ALTER TABLE pending_operations
+	ADD COLUMN IF NOT EXISTS layers JSONB NOT NULL DEFAULT '[]';
+
+INSERT INTO permissions (name, description) VALUES
+	('costing.manage', 'Choose the costing methods of Owners and Customers');
+
+INSERT INTO role_permissions (role, permission) VALUES
+	('admin', 'costing.manage');
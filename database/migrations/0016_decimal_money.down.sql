@@ -0,0 +1,5 @@
+ALTER TABLE incoming_materials
+	ALTER COLUMN cost TYPE DECIMAL;
+
+ALTER TABLE prices
+	ALTER COLUMN cost TYPE DECIMAL;
@@ -0,0 +1,6 @@
+-- Unit costs keep 4 decimal places, see storage.UnitCostPlaces
+ALTER TABLE prices
+	ALTER COLUMN cost TYPE NUMERIC(18, 4) USING ROUND(cost, 4);
+
+ALTER TABLE incoming_materials
+	ALTER COLUMN cost TYPE NUMERIC(18, 4) USING ROUND(cost, 4);
@@ -0,0 +1,9 @@
+DELETE FROM permissions WHERE name = 'rates.manage';
+
+DROP TABLE IF EXISTS exchange_rates;
+
+ALTER TABLE prices
+	DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE incoming_materials
+	DROP COLUMN IF EXISTS currency;
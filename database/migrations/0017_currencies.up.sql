@@ -0,0 +1,20 @@
+-- The costs so far were entered in US dollars
+ALTER TABLE incoming_materials
+	ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE prices
+	ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+-- Units of the base currency (BASE_CURRENCY) per unit of the currency, valid from the effective date until the next one
+CREATE TABLE IF NOT EXISTS exchange_rates (
+	currency CHAR(3) NOT NULL,
+	effective_date DATE NOT NULL,
+	rate NUMERIC(18, 8) NOT NULL CHECK (rate > 0),
+	PRIMARY KEY (currency, effective_date)
+);
+
+INSERT INTO permissions (name, description) VALUES
+	('rates.manage', 'Maintain the exchange rates');
+
+INSERT INTO role_permissions (role, permission) VALUES
+	('admin', 'rates.manage');
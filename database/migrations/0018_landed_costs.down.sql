@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS landed_allocations;
+
+DROP TABLE IF EXISTS landed_charges;
+
+ALTER TABLE prices
+	DROP COLUMN IF EXISTS landed_cost;
+
+ALTER TABLE incoming_materials
+	DROP COLUMN IF EXISTS landed_cost,
+	DROP COLUMN IF EXISTS unit_weight;
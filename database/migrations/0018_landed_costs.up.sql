@@ -0,0 +1,36 @@
+-- Landed charges per unit of the Incoming Materials, included in the cost of the layers from their receipt
+ALTER TABLE incoming_materials
+	ADD COLUMN IF NOT EXISTS unit_weight NUMERIC(18, 4) NOT NULL DEFAULT 0 CHECK (unit_weight >= 0),
+	ADD COLUMN IF NOT EXISTS landed_cost NUMERIC(18, 4) NOT NULL DEFAULT 0;
+
+ALTER TABLE prices
+	ADD COLUMN IF NOT EXISTS landed_cost NUMERIC(18, 4) NOT NULL DEFAULT 0;
+
+-- Freight, duties and other charges of one or more shipments
+CREATE TABLE IF NOT EXISTS landed_charges (
+	charge_id SERIAL PRIMARY KEY,
+	description TEXT NOT NULL,
+	amount NUMERIC(18, 4) NOT NULL CHECK (amount > 0),
+	currency CHAR(3) NOT NULL,
+	method VARCHAR(10) NOT NULL CHECK (method IN ('quantity', 'value', 'weight')),
+	charge_date DATE NOT NULL,
+	user_id INT REFERENCES users (user_id),
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- The share of a charge of every shipment in the currency of the shipment.
+-- The shipment is not referenced: a received Incoming Material is kept with quantity 0 (fully received),
+-- but the ones received before the Migration 0020 were deleted.
+CREATE TABLE IF NOT EXISTS landed_allocations (
+	charge_id INT NOT NULL REFERENCES landed_charges (charge_id),
+	shipping_id INT NOT NULL,
+	stock_id VARCHAR(100) NOT NULL,
+	basis NUMERIC(18, 4) NOT NULL,
+	amount NUMERIC(18, 4) NOT NULL,
+	currency CHAR(3) NOT NULL,
+	quantity INT NOT NULL,
+	unit_cost NUMERIC(18, 4) NOT NULL,
+	PRIMARY KEY (charge_id, shipping_id)
+);
+
+CREATE INDEX IF NOT EXISTS landed_allocations_shipping_idx ON landed_allocations (shipping_id);
@@ -0,0 +1,6 @@
+DELETE FROM pending_operations WHERE operation = 'reverse';
+
+ALTER TABLE pending_operations
+	DROP COLUMN IF EXISTS transaction_id,
+	DROP CONSTRAINT IF EXISTS pending_operations_operation_check,
+	ADD CONSTRAINT pending_operations_operation_check CHECK (operation IN ('move', 'remove'));
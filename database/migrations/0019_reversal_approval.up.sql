@@ -0,0 +1,5 @@
+-- Reversals of the material types under dual control wait for the approval of a second vault User as well
+ALTER TABLE pending_operations
+	DROP CONSTRAINT IF EXISTS pending_operations_operation_check,
+	ADD CONSTRAINT pending_operations_operation_check CHECK (operation IN ('move', 'remove', 'reverse')),
+	ADD COLUMN IF NOT EXISTS transaction_id INT REFERENCES transactions_log (transaction_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS transactions_log_price_idx;
+DROP INDEX IF EXISTS transactions_log_shipping_idx;
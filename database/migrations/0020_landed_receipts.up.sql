@@ -0,0 +1,4 @@
+-- Landed charges reach the cost layers received from a shipment through its receipts and follow them through
+-- the moves and the weighted average, a received Incoming Material is kept with no quantity
+CREATE INDEX IF NOT EXISTS transactions_log_shipping_idx ON transactions_log (shipping_id);
+CREATE INDEX IF NOT EXISTS transactions_log_price_idx ON transactions_log (price_id);
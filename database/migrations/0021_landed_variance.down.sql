@@ -0,0 +1,2 @@
+ALTER TABLE landed_allocations
+	DROP COLUMN IF EXISTS variance;
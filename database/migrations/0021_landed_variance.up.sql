@@ -0,0 +1,4 @@
+-- A landed charge no longer changes the cost of received layers: the stock on hand is revalued by adjustment
+-- Transaction Logs and the share of the units already consumed is kept as the variance of the allocation
+ALTER TABLE landed_allocations
+	ADD COLUMN IF NOT EXISTS variance NUMERIC(18, 4) NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE incoming_materials
+	DROP COLUMN IF EXISTS landed_value;
@@ -0,0 +1,7 @@
+-- The value of the landed charges of the quantity still incoming, so the receipts add up to the allocated amounts
+ALTER TABLE incoming_materials
+	ADD COLUMN IF NOT EXISTS landed_value NUMERIC(18, 4) NOT NULL DEFAULT 0;
+
+UPDATE incoming_materials
+SET landed_value = ROUND(landed_cost * quantity, 2)
+WHERE landed_cost <> 0;
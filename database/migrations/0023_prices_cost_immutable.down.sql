@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS prices_cost_immutable ON prices;
+DROP FUNCTION IF EXISTS prices_cost_immutable();
@@ -0,0 +1,12 @@
+-- The Transaction Logs are valued at the cost of their layer, which the hash chain does not cover.
+-- Only the quantity of a layer changes, a landed charge revalues the stock by a new layer.
+CREATE OR REPLACE FUNCTION prices_cost_immutable() RETURNS TRIGGER AS $$
+BEGIN
+	RAISE EXCEPTION 'the cost of prices rows cannot be changed';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS prices_cost_immutable ON prices;
+CREATE TRIGGER prices_cost_immutable
+	BEFORE UPDATE OF cost, landed_cost, currency ON prices
+	FOR EACH ROW EXECUTE FUNCTION prices_cost_immutable();
@@ -8,6 +8,8 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/leekchan/accounting v1.0.0
 	github.com/lib/pq v1.10.9
+	github.com/shopspring/decimal v0.0.0-20180709203117-cd690d0c9e24
+	golang.org/x/crypto v0.31.0
 )
 
 require (
@@ -15,5 +17,4 @@ require (
 	github.com/felixge/httpsnoop v1.0.3 // indirect
 	github.com/gorilla/websocket v1.5.3
 	github.com/pkg/errors v0.8.1 // indirect
-	github.com/shopspring/decimal v0.0.0-20180709203117-cd690d0c9e24 // indirect
 )
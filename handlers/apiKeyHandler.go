@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"inv_app/services/users"
+	"inv_app/services/validation"
+	"net/http"
+)
+
+func (s *Server) GetAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	userId := pathID(r, &errs, "id")
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	keys, err := users.FetchAPIKeys(r.Context(), s.Store, userId)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "API Keys List", Data: keys}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	userId := pathID(r, &errs, "id")
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	var newKey users.NewAPIKeyJSON
+	if !checkValid(w, r, decodeJSON(r, &newKey)) || !checkValid(w, r, users.ValidateAPIKey(newKey)) {
+		return
+	}
+	key, err := users.CreateAPIKey(r.Context(), s.Store, userId, newKey)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "API Key created, it is not shown again", Data: key}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	keyId := pathID(r, &errs, "id")
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	err := users.RevokeAPIKey(r.Context(), s.Store, keyId)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "API Key revoked"}
+	json.NewEncoder(w).Encode(res)
+}
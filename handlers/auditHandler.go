@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"inv_app/services/audit"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"net/http"
+)
+
+func (s *Server) GetAuditHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	filter := storage.AuditFilter{
+		UserID:   queryInt(r, &errs, "userId"),
+		Entity:   r.URL.Query().Get("entity"),
+		EntityID: r.URL.Query().Get("entityId"),
+		DateFrom: queryDate(r, &errs, "dateFrom"),
+		DateTo:   queryDate(r, &errs, "dateTo"),
+	}
+	page := queryPage(r, &errs, storage.AuditSortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	entries, total, err := audit.FetchEntries(r.Context(), s.Store, filter, page)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeList(w, "", entries, total, page)
+}
+
+func (s *Server) VerifyTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := audit.VerifyTransactions(r.Context(), s.Store)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	message := "Transaction Logs chain is valid"
+	if !report.Valid {
+		message = "Transaction Logs chain is broken"
+	}
+	res := SuccessResponseJSON{Message: message, Data: report}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"encoding/json"
+	"inv_app/services/materials"
+	"net/http"
+)
+
+func (s *Server) GetCostingMethodsHandler(w http.ResponseWriter, r *http.Request) {
+	costing, err := materials.FetchCostingMethods(r.Context(), s.Store)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "Costing Methods", Data: costing}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) SetCostingMethodsHandler(w http.ResponseWriter, r *http.Request) {
+	var costing materials.CostingMethodsJSON
+	if !checkValid(w, r, decodeJSON(r, &costing)) || !checkValid(w, r, materials.ValidateCostingMethods(costing)) {
+		return
+	}
+	err := materials.SetCostingMethods(r.Context(), s.Store, costing)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "Costing Methods updated", Data: costing}
+	json.NewEncoder(w).Encode(res)
+}
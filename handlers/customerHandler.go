@@ -2,33 +2,37 @@ package handlers
 
 import (
 	"encoding/json"
-	"inv_app/database"
 	"inv_app/services/customers"
+	"inv_app/services/validation"
+	"inv_app/storage"
 	"net/http"
 )
 
-func CreateCustomerHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
+func (s *Server) CreateCustomerHandler(w http.ResponseWriter, r *http.Request) {
 	var customer customers.CustomerJSON
-	json.NewDecoder(r.Body).Decode(&customer)
-	err := customers.CreateCustomer(customer, db)
+	if !checkValid(w, r, decodeJSON(r, &customer)) {
+		return
+	}
+	err := customers.CreateCustomer(r.Context(), s.Store, customer)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	json.NewEncoder(w).Encode(customer)
 }
 
-func GetCustomersHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-	customers, err := customers.FetchCustomers(db)
+func (s *Server) GetCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	page := queryPage(r, &errs, storage.CustomerSortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	customers, total, err := customers.FetchCustomers(r.Context(), s.Store, page)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
-	json.NewEncoder(w).Encode(customers)
+	writeList(w, "", customers, total, page)
 }
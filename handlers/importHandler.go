@@ -2,21 +2,21 @@ package handlers
 
 import (
 	"encoding/json"
-	"inv_app/database"
 	"inv_app/services/import_data"
 	"net/http"
 )
 
-func ImportData(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-
+func (s *Server) ImportData(w http.ResponseWriter, r *http.Request) {
 	var dataToImport import_data.ImportJSON
-	json.NewDecoder(r.Body).Decode(&dataToImport)
-	importRes, err := import_data.ImportDataToDB(db, dataToImport)
+	// Spreadsheet rows may have extra columns, so unknown fields are not rejected here
+	if err := json.NewDecoder(r.Body).Decode(&dataToImport); err != nil {
+		checkValid(w, r, decodeError(err))
+		return
+	}
+	importRes, err := import_data.ImportDataToDB(r.Context(), s.Store, dataToImport)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"inv_app/services/materials"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"net/http"
+)
+
+func (s *Server) GetLandedChargesHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	shippingId := queryInt(r, &errs, "shippingId")
+	page := queryPage(r, &errs, storage.LandedCostSortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	charges, total, err := materials.FetchLandedCharges(r.Context(), s.Store, shippingId, page)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeList(w, "", charges, total, page)
+}
+
+func (s *Server) AllocateLandedChargeHandler(w http.ResponseWriter, r *http.Request) {
+	var charge materials.LandedChargeJSON
+	if !checkValid(w, r, decodeJSON(r, &charge)) || !checkValid(w, r, materials.ValidateLandedCharge(charge)) {
+		return
+	}
+	charge, err := materials.AllocateLandedCharge(r.Context(), s.Store, charge)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "Landed Charge allocated", Data: charge}
+	json.NewEncoder(w).Encode(res)
+}
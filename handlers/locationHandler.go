@@ -1,34 +1,39 @@
 package handlers
 
 import (
-	"encoding/json"
-	"inv_app/database"
 	"inv_app/services/locations"
+	"inv_app/services/validation"
+	"inv_app/storage"
 	"net/http"
 )
 
-func GetLocationsHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-
-	locations, err := locations.FetchLocations(db)
+func (s *Server) GetLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	page := queryPage(r, &errs, storage.LocationSortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	locations, total, err := locations.FetchLocations(r.Context(), s.Store, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
-	json.NewEncoder(w).Encode(locations)
+	writeList(w, "", locations, total, page)
 }
 
-func GetAvailableLocationsHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
+func (s *Server) GetAvailableLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	page := queryPage(r, &errs, storage.LocationSortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
 	stockId := r.URL.Query().Get("stockId")
 	owner := r.URL.Query().Get("owner")
 
-	locations, err := locations.FetchAvailableLocations(db, locations.LocationFilter{StockId: stockId, Owner: owner})
+	locations, total, err := locations.FetchAvailableLocations(r.Context(), s.Store, storage.LocationFilter{StockId: stockId, Owner: owner}, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
-	json.NewEncoder(w).Encode(locations)
+	writeList(w, "", locations, total, page)
 }
@@ -1,253 +1,278 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
-	"inv_app/database"
 	"inv_app/services/materials"
+	"inv_app/services/users"
+	"inv_app/services/validation"
+	"inv_app/storage"
 	"net/http"
-	"strconv"
 )
 
-func GetMaterialTypesHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-	materialTypes, err := materials.FetchMaterialTypes(db)
+func (s *Server) GetMaterialTypesHandler(w http.ResponseWriter, r *http.Request) {
+	materialTypes, err := materials.FetchMaterialTypes(r.Context(), s.Store)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	json.NewEncoder(w).Encode(materialTypes)
 }
 
-func SendMaterialHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
+func (s *Server) SendMaterialHandler(w http.ResponseWriter, r *http.Request) {
 	var material materials.IncomingMaterialJSON
-	json.NewDecoder(r.Body).Decode(&material)
-	err := materials.SendMaterial(material, db)
+	if !checkValid(w, r, decodeJSON(r, &material)) {
+		return
+	}
+	// The Materials are sent/requested by the authenticated User
+	if user, ok := users.FromContext(r.Context()); ok {
+		material.UserID = user.UserID
+	}
+	if !checkValid(w, r, materials.ValidateIncoming(material)) {
+		return
+	}
+	err := materials.SendMaterial(r.Context(), s.Store, material)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	json.NewEncoder(w).Encode(material)
 }
 
-func GetIncomingMaterialsHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-
-	materialId := r.URL.Query().Get("materialId")
-	id, _ := strconv.Atoi(materialId)
-	materials, err := materials.GetIncomingMaterials(db, id)
+func (s *Server) GetIncomingMaterialsHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	id := queryInt(r, &errs, "materialId")
+	page := queryPage(r, &errs, storage.IncomingSortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	materials, total, err := materials.GetIncomingMaterials(r.Context(), s.Store, id, page)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(materials)
+	writeList(w, "", materials, total, page)
 }
 
-func UpdateIncomingMaterialHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-
+func (s *Server) UpdateIncomingMaterialHandler(w http.ResponseWriter, r *http.Request) {
 	var material materials.IncomingMaterialJSON
-	json.NewDecoder(r.Body).Decode(&material)
-	err := materials.UpdateIncomingMaterial(db, material)
+	if !checkValid(w, r, decodeJSON(r, &material)) {
+		return
+	}
+	if !checkValid(w, r, materials.ValidateIncomingUpdate(material)) {
+		return
+	}
+	err := materials.UpdateIncomingMaterial(r.Context(), s.Store, material)
 
 	if err != nil {
-		errRes := ErrorResponseJSON{Message: err.Error()}
-		res, _ := json.Marshal(errRes)
-		http.Error(w, string(res), http.StatusConflict)
+		writeError(w, r, err)
 		return
 	}
 	res := SuccessResponseJSON{Message: "Requested Material Updated"}
 	json.NewEncoder(w).Encode(res)
 }
 
-func CreateMaterialHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-
+func (s *Server) CreateMaterialHandler(w http.ResponseWriter, r *http.Request) {
 	var material materials.MaterialJSON
-	json.NewDecoder(r.Body).Decode(&material)
+	if !checkValid(w, r, decodeJSON(r, &material)) {
+		return
+	}
+	if !checkValid(w, r, materials.ValidateCreate(material)) {
+		return
+	}
 
-	ctx := context.TODO()
-	materialId, err := materials.CreateMaterial(ctx, db, material)
+	materialId, err := materials.CreateMaterial(r.Context(), s.Store, material)
 
 	if err != nil {
-		errRes := ErrorResponseJSON{Message: err.Error()}
-		res, _ := json.Marshal(errRes)
-		http.Error(w, string(res), http.StatusConflict)
+		writeError(w, r, err)
 		return
 	}
 	res := SuccessResponseJSON{Message: "Material ID created", Data: materialId}
 	json.NewEncoder(w).Encode(res)
 }
 
-func GetMaterialsHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-
-	materialId := r.URL.Query().Get("materialId")
-	id, _ := strconv.Atoi(materialId)
+func (s *Server) GetMaterialsHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	id := queryInt(r, &errs, "materialId")
+	page := queryPage(r, &errs, storage.MaterialSortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
 	stockId := r.URL.Query().Get("stockId")
 	customerName := r.URL.Query().Get("customerName")
 	description := r.URL.Query().Get("description")
 	locationName := r.URL.Query().Get("locationName")
 
-	filterOpts := &materials.MaterialFilter{
+	filterOpts := &storage.MaterialFilter{
 		MaterialId:   id,
 		StockId:      stockId,
 		CustomerName: customerName,
 		Description:  description,
 		LocationName: locationName,
 	}
-	materials, err := materials.GetMaterials(db, filterOpts)
+	materials, total, err := materials.GetMaterials(r.Context(), s.Store, filterOpts, page)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(materials)
+	writeList(w, "", materials, total, page)
 }
 
-func UpdateMaterialHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
+func (s *Server) GetMaterialHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	materialId := pathID(r, &errs, "id")
+	page := queryPage(r, &errs, storage.HistorySortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	history, total, err := materials.GetMaterialHistory(r.Context(), s.Store, materialId, page)
 
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeList(w, "", history, total, page)
+}
+
+func (s *Server) UpdateMaterialHandler(w http.ResponseWriter, r *http.Request) {
 	var material materials.MaterialJSON
-	json.NewDecoder(r.Body).Decode(&material)
-	err := materials.UpdateMaterial(db, material)
+	if !checkValid(w, r, decodeJSON(r, &material)) {
+		return
+	}
+	if !checkValid(w, r, materials.ValidateUpdate(material)) {
+		return
+	}
+	err := materials.UpdateMaterial(r.Context(), s.Store, material)
 
 	if err != nil {
-		errRes := ErrorResponseJSON{Message: err.Error()}
-		res, _ := json.Marshal(errRes)
-		http.Error(w, string(res), http.StatusConflict)
+		writeError(w, r, err)
 		return
 	}
 	res := SuccessResponseJSON{Message: "Material Updated"}
 	json.NewEncoder(w).Encode(res)
 }
 
-func MoveMaterialHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
+func (s *Server) MoveMaterialHandler(w http.ResponseWriter, r *http.Request) {
 	var material materials.MaterialJSON
-	json.NewDecoder(r.Body).Decode(&material)
+	if !checkValid(w, r, decodeJSON(r, &material)) {
+		return
+	}
+	if !checkValid(w, r, materials.ValidateMove(material)) {
+		return
+	}
 
-	ctx := context.TODO()
-	err := materials.MoveMaterial(ctx, db, material)
+	operationId, err := materials.MoveMaterial(r.Context(), s.Store, material)
 
 	if err != nil {
-		errRes := ErrorResponseJSON{Message: err.Error()}
-		res, _ := json.Marshal(errRes)
-		http.Error(w, string(res), http.StatusConflict)
+		writeError(w, r, err)
+		return
+	}
+	if operationId != 0 {
+		writeOperationPending(w, operationId)
 		return
 	}
 	res := SuccessResponseJSON{Message: "Material Moved", Data: material}
 	json.NewEncoder(w).Encode(res)
 }
 
-func RemoveMaterialHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
+func (s *Server) RemoveMaterialHandler(w http.ResponseWriter, r *http.Request) {
 	var material materials.MaterialJSON
-	json.NewDecoder(r.Body).Decode(&material)
+	if !checkValid(w, r, decodeJSON(r, &material)) {
+		return
+	}
+	if !checkValid(w, r, materials.ValidateRemove(material)) {
+		return
+	}
 
-	ctx := context.TODO()
-	err := materials.RemoveMaterial(ctx, db, material)
+	operationId, err := materials.RemoveMaterial(r.Context(), s.Store, material)
 
 	if err != nil {
-		errRes := ErrorResponseJSON{Message: err.Error()}
-		res, _ := json.Marshal(errRes)
-		http.Error(w, string(res), http.StatusConflict)
+		writeError(w, r, err)
+		return
+	}
+	if operationId != 0 {
+		writeOperationPending(w, operationId)
 		return
 	}
 	res := SuccessResponseJSON{Message: "Material Quantity Removed", Data: material}
 	json.NewEncoder(w).Encode(res)
 }
 
-func RequestMaterialsHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
+func (s *Server) RequestMaterialsHandler(w http.ResponseWriter, r *http.Request) {
 	var materialsData materials.RequestedMaterialsJSON
-	json.NewDecoder(r.Body).Decode(&materialsData)
+	if !checkValid(w, r, decodeJSON(r, &materialsData)) {
+		return
+	}
+	// The Materials are sent/requested by the authenticated User
+	if user, ok := users.FromContext(r.Context()); ok {
+		materialsData.UserID = user.UserID
+	}
+	if !checkValid(w, r, materials.ValidateRequest(materialsData)) {
+		return
+	}
 
-	ctx := context.TODO()
-	err := materials.RequestMaterials(ctx, db, materialsData)
+	err := materials.RequestMaterials(r.Context(), s.Store, materialsData)
 
 	if err != nil {
-		errRes := ErrorResponseJSON{Message: err.Error()}
-		res, _ := json.Marshal(errRes)
-		http.Error(w, string(res), http.StatusConflict)
+		writeError(w, r, err)
 		return
 	}
 	res := SuccessResponseJSON{Message: "Materials requested"}
 	json.NewEncoder(w).Encode(res)
 }
 
-func GetRequestedMaterialsHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-
-	requestId := r.URL.Query().Get("requestId")
-	id, _ := strconv.Atoi(requestId)
+func (s *Server) GetRequestedMaterialsHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	id := queryInt(r, &errs, "requestId")
+	requestedAt := queryDate(r, &errs, "requestedAt")
+	page := queryPage(r, &errs, storage.RequestSortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
 	stockId := r.URL.Query().Get("stockId")
 	status := r.URL.Query().Get("status")
-	requestedAt := r.URL.Query().Get("requestedAt")
-	filterOpts := materials.MaterialFilter{
+	filterOpts := storage.MaterialFilter{
 		RequestId:   id,
 		StockId:     stockId,
 		Status:      status,
 		RequestedAt: requestedAt,
 	}
-	materials, err := materials.GetRequestedMaterials(db, filterOpts)
+	materials, total, err := materials.GetRequestedMaterials(r.Context(), s.Store, filterOpts, page)
 
 	if err != nil {
-		errRes := ErrorResponseJSON{Message: err.Error()}
-		res, _ := json.Marshal(errRes)
-		http.Error(w, string(res), http.StatusConflict)
+		writeError(w, r, err)
 		return
 	}
-	res := SuccessResponseJSON{Message: "Requested Materials List", Data: materials}
-	json.NewEncoder(w).Encode(res)
+	writeList(w, "Requested Materials List", materials, total, page)
 }
 
-func UpdateRequestedMaterialHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-
+func (s *Server) UpdateRequestedMaterialHandler(w http.ResponseWriter, r *http.Request) {
 	var material materials.MaterialJSON
-	json.NewDecoder(r.Body).Decode(&material)
-	err := materials.UpdateRequestedMaterial(db, material)
+	if !checkValid(w, r, decodeJSON(r, &material)) {
+		return
+	}
+	if !checkValid(w, r, materials.ValidateRequestUpdate(material)) {
+		return
+	}
+	err := materials.UpdateRequestedMaterial(r.Context(), s.Store, material)
 
 	if err != nil {
-		errRes := ErrorResponseJSON{Message: err.Error()}
-		res, _ := json.Marshal(errRes)
-		http.Error(w, string(res), http.StatusConflict)
+		writeError(w, r, err)
 		return
 	}
 	res := SuccessResponseJSON{Message: "Requested Material Updated"}
 	json.NewEncoder(w).Encode(res)
 }
 
-func GetMaterialDescriptionHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-
+func (s *Server) GetMaterialDescriptionHandler(w http.ResponseWriter, r *http.Request) {
 	stockId := r.URL.Query().Get("stockId")
-	description, err := materials.GetMaterialDescription(db, stockId)
+	description, err := materials.GetMaterialDescription(r.Context(), s.Store, stockId)
 
 	if err != nil {
-		errRes := ErrorResponseJSON{Message: err.Error()}
-		res, _ := json.Marshal(errRes)
-		http.Error(w, string(res), http.StatusConflict)
+		writeError(w, r, err)
 		return
 	}
 	res := SuccessResponseJSON{Message: "Material Description Requested", Data: description}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"inv_app/services/users"
+	"net/http"
+	"strings"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+type requestIdKey struct{}
+
+// RequestIDMiddleware keeps the X-Request-ID of the client or generates a new one,
+// returns it in the response header and puts it into the request Context for the error responses and logs.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(RequestIDHeader)
+		if requestId == "" || len(requestId) > 128 {
+			requestId = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestId)
+		ctx := context.WithValue(r.Context(), requestIdKey{}, requestId)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// AuthMiddleware lets through the requests with a valid Session token or API key only
+// and puts the authenticated User into the request Context (see users.FromContext).
+func (s *Server) AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var user users.UserJSON
+		var err error
+		if key := requestAPIKey(r); key != "" {
+			user, err = users.AuthenticateAPIKey(r.Context(), s.Store, key)
+		} else {
+			user, err = users.Authenticate(r.Context(), s.Store, requestToken(r))
+		}
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(users.WithUser(r.Context(), user)))
+	})
+}
+
+// Require lets through the requests of the Users having the Permission, others get 403 naming it.
+func (s *Server) Require(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := users.Require(r.Context(), permission); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// The token is sent as "Authorization: Bearer <token>".
+// Browsers cannot set headers on a WebSocket handshake, so it may be sent as the "token" query parameter there.
+func requestToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return r.URL.Query().Get("token")
+	}
+	return ""
+}
+
+// Service accounts send their key as "Authorization: ApiKey <key>"
+func requestAPIKey(r *http.Request) string {
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "ApiKey "); ok {
+		return strings.TrimSpace(key)
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"inv_app/services/materials"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"net/http"
+)
+
+// The move or removal is waiting for the approval of a second User
+func writeOperationPending(w http.ResponseWriter, operationId int) {
+	res := SuccessResponseJSON{
+		Message: "Operation is pending approval",
+		Data:    map[string]int{"operationId": operationId},
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) GetOperationsHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	page := queryPage(r, &errs, storage.OperationSortFields)
+	status := r.URL.Query().Get("status")
+	if !checkValid(w, r, errs.Err()) || !checkValid(w, r, materials.ValidateOperationStatus(status)) {
+		return
+	}
+	operations, total, err := materials.FetchOperations(r.Context(), s.Store, status, page)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeList(w, "", operations, total, page)
+}
+
+func (s *Server) ApproveOperationHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	operationId := pathID(r, &errs, "id")
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	err := materials.ApproveOperation(r.Context(), s.Store, operationId)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "Operation approved"}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) RejectOperationHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	operationId := pathID(r, &errs, "id")
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	err := materials.RejectOperation(r.Context(), s.Store, operationId)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "Operation rejected"}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) GetDualControlHandler(w http.ResponseWriter, r *http.Request) {
+	dualControl, err := materials.FetchDualControl(r.Context(), s.Store)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "Dual Control Material Types", Data: dualControl}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) SetDualControlHandler(w http.ResponseWriter, r *http.Request) {
+	var dualControl materials.DualControlJSON
+	if !checkValid(w, r, decodeJSON(r, &dualControl)) {
+		return
+	}
+	err := materials.SetDualControl(r.Context(), s.Store, dualControl)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "Dual Control Material Types updated", Data: dualControl}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"encoding/json"
+	"inv_app/services/reports"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"net/http"
+)
+
+func (s *Server) GetExchangeRatesHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	page := queryPage(r, &errs, storage.RateSortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	rates, total, err := reports.FetchExchangeRates(r.Context(), s.Store, r.URL.Query().Get("currency"), page)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeList(w, "", rates, total, page)
+}
+
+func (s *Server) SetExchangeRateHandler(w http.ResponseWriter, r *http.Request) {
+	var rate reports.ExchangeRateJSON
+	if !checkValid(w, r, decodeJSON(r, &rate)) || !checkValid(w, r, reports.ValidateExchangeRate(rate)) {
+		return
+	}
+	err := reports.SetExchangeRate(r.Context(), s.Store, rate)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "Exchange Rate saved", Data: rate}
+	json.NewEncoder(w).Encode(res)
+}
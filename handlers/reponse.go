@@ -1,10 +1,145 @@
 package handlers
 
+import (
+	"encoding/json"
+	"errors"
+	"inv_app/services/errs"
+	"inv_app/services/users"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"log"
+	"net/http"
+	"strconv"
+)
+
 type SuccessResponseJSON struct {
 	Message string `json:"message"`
 	Data    any    `json:"data"`
 }
 
+// ListResponseJSON is the body of the list endpoints, Total is the number of rows matching the filter.
+type ListResponseJSON struct {
+	Message string `json:"message,omitempty"`
+	Data    any    `json:"data"`
+	Total   int    `json:"total"`
+	Limit   int    `json:"limit"`
+	Offset  int    `json:"offset"`
+}
+
+// ErrorResponseJSON is the body of every failed request.
+// Clients branch on Code, the Message is for people and may change.
 type ErrorResponseJSON struct {
-	Message string `json:"message"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"requestId"`
+}
+
+// Error codes of ErrorResponseJSON
+const (
+	CodeValidation           = "VALIDATION_FAILED"
+	CodeNotFound             = "NOT_FOUND"
+	CodeInsufficientQuantity = "INSUFFICIENT_QUANTITY"
+	CodeConflict             = "CONFLICT"
+	CodeUnauthorized         = "UNAUTHORIZED"
+	CodeForbidden            = "FORBIDDEN"
+	CodeMethodNotAllowed     = "METHOD_NOT_ALLOWED"
+	CodeTooManyRequests      = "TOO_MANY_REQUESTS"
+	CodeInternal             = "INTERNAL_ERROR"
+)
+
+// Sentinel errors of the services with their HTTP status and code, checked in order
+var errorStatuses = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{errs.ErrValidation, http.StatusBadRequest, CodeValidation},
+	{errs.ErrUnauthorized, http.StatusUnauthorized, CodeUnauthorized},
+	{errs.ErrForbidden, http.StatusForbidden, CodeForbidden},
+	{errs.ErrNotFound, http.StatusNotFound, CodeNotFound},
+	{errs.ErrInsufficientQuantity, http.StatusConflict, CodeInsufficientQuantity},
+	{errs.ErrConflict, http.StatusConflict, CodeConflict},
+	{errs.ErrTooManyRequests, http.StatusTooManyRequests, CodeTooManyRequests},
+}
+
+// The method answers with the ErrorResponseJSON matching err.
+// Unknown errors are logged and answered with 500 without exposing their message.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	requestId := RequestIDFromContext(r.Context())
+	for _, known := range errorStatuses {
+		if errors.Is(err, known.err) {
+			setRetryAfter(w, err)
+			writeErrorResponse(w, known.status, ErrorResponseJSON{
+				Code:      known.code,
+				Message:   err.Error(),
+				Details:   errorDetails(err),
+				RequestID: requestId,
+			})
+			return
+		}
+	}
+
+	log.Printf("Request %s failed: %v", requestId, err)
+	writeErrorResponse(w, http.StatusInternalServerError, ErrorResponseJSON{
+		Code:      CodeInternal,
+		Message:   "Internal server error",
+		RequestID: requestId,
+	})
+}
+
+func errorDetails(err error) any {
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		return fieldErrs
+	}
+	var serviceErr *errs.Error
+	if errors.As(err, &serviceErr) && serviceErr.Details != nil {
+		return serviceErr.Details
+	}
+	return nil
+}
+
+// Throttled clients are told when to retry in the Retry-After header as well
+func setRetryAfter(w http.ResponseWriter, err error) {
+	var serviceErr *errs.Error
+	if !errors.As(err, &serviceErr) {
+		return
+	}
+	if throttle, ok := serviceErr.Details.(users.ThrottleDetails); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(throttle.RetryAfter))
+	}
+}
+
+func writeList(w http.ResponseWriter, message string, data any, total int, page storage.Page) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ListResponseJSON{
+		Message: message,
+		Data:    data,
+		Total:   total,
+		Limit:   page.Limit,
+		Offset:  page.Offset,
+	})
+}
+
+func writeErrorResponse(w http.ResponseWriter, status int, res ErrorResponseJSON) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeErrorResponse(w, http.StatusNotFound, ErrorResponseJSON{
+		Code:      CodeNotFound,
+		Message:   "Route not found: " + r.Method + " " + r.URL.Path,
+		RequestID: RequestIDFromContext(r.Context()),
+	})
+}
+
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	writeErrorResponse(w, http.StatusMethodNotAllowed, ErrorResponseJSON{
+		Code:      CodeMethodNotAllowed,
+		Message:   "Method not allowed: " + r.Method + " " + r.URL.Path,
+		RequestID: RequestIDFromContext(r.Context()),
+	})
 }
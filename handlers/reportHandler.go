@@ -1,61 +1,79 @@
 package handlers
 
 import (
-	"encoding/json"
-	"inv_app/database"
 	"inv_app/services/reports"
+	"inv_app/services/validation"
+	"inv_app/storage"
 	"net/http"
-	"strconv"
 )
 
-func GetTransactionsReport(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-
-	customerIdStr := r.URL.Query().Get("customerId")
-	customerId, _ := strconv.Atoi(customerIdStr)
+func (s *Server) GetTransactionsReport(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	customerId := queryInt(r, &errs, "customerId")
+	dateFrom := queryDate(r, &errs, "dateFrom")
+	dateTo := queryDate(r, &errs, "dateTo")
+	currency, rateDate := queryCurrency(r, &errs)
+	page := queryPage(r, &errs, storage.TransactionSortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
 	owner := r.URL.Query().Get("owner")
 	materialType := r.URL.Query().Get("materialType")
-	dateFrom := r.URL.Query().Get("dateFrom")
-	dateTo := r.URL.Query().Get("dateTo")
 
-	trxRep := reports.TransactionReport{Report: reports.Report{DB: db}, TrxFilter: reports.SearchQuery{
+	trxRep := reports.TransactionReport{Report: reports.Report{Store: s.Store, Page: page}, TrxFilter: storage.ReportFilter{
 		CustomerId:   customerId,
 		Owner:        owner,
 		MaterialType: materialType,
 		DateFrom:     dateFrom,
 		DateTo:       dateTo,
+		Currency:     currency,
+		RateDate:     rateDate,
 	}}
-	trxReport, err := trxRep.GetReportList()
+	trxReport, total, err := trxRep.GetReportList(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(trxReport)
+	writeList(w, "", trxReport, total, page)
 }
 
-func GetBalanceReport(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-
-	customerIdStr := r.URL.Query().Get("customerId")
-	customerId, _ := strconv.Atoi(customerIdStr)
+func (s *Server) GetBalanceReport(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	customerId := queryInt(r, &errs, "customerId")
+	dateAsOf := queryDate(r, &errs, "dateAsOf")
+	currency, rateDate := queryCurrency(r, &errs)
+	page := queryPage(r, &errs, storage.BalanceSortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
 	owner := r.URL.Query().Get("owner")
 	materialType := r.URL.Query().Get("materialType")
-	dateAsOf := r.URL.Query().Get("dateAsOf")
 
-	balanceRep := reports.BalanceReport{Report: reports.Report{DB: db}, BlcFilter: reports.SearchQuery{
+	balanceRep := reports.BalanceReport{Report: reports.Report{Store: s.Store, Page: page}, BlcFilter: storage.ReportFilter{
 		CustomerId:   customerId,
 		Owner:        owner,
 		MaterialType: materialType,
 		DateAsOf:     dateAsOf,
+		Currency:     currency,
+		RateDate:     rateDate,
 	}}
-	balanceReport, err := balanceRep.GetReportList()
+	balanceReport, total, err := balanceRep.GetReportList(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(balanceReport)
+	writeList(w, "", balanceReport, total, page)
+}
+
+// The method reads the "converted" and "rateDate" query parameters of a report.
+// A converted report values the stock in the base currency with the rates of the rate date.
+func queryCurrency(r *http.Request, errs *validation.Errors) (string, string) {
+	converted := queryBool(r, errs, "converted")
+	rateDate := queryDate(r, errs, "rateDate")
+	if converted == nil || !*converted {
+		return "", ""
+	}
+	return storage.BaseCurrency(), rateDate
 }
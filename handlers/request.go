@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// The method decodes the request body into dst. Unknown fields, wrong types and trailing data
+// are returned as validation.Errors, so the handler answers them with 400.
+func decodeJSON(r *http.Request, dst any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return validation.Errors{{Field: "body", Message: "must contain a single JSON object"}}
+	}
+	return nil
+}
+
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		return validation.Errors{{Field: typeErr.Field, Message: "must be of type " + typeErr.Type.String()}}
+	case errors.As(err, &syntaxErr):
+		return validation.Errors{{Field: "body", Message: fmt.Sprintf("malformed JSON at position %d", syntaxErr.Offset)}}
+	case errors.Is(err, io.EOF):
+		return validation.Errors{{Field: "body", Message: "is required"}}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return validation.Errors{{Field: field, Message: "is not allowed"}}
+	}
+	return validation.Errors{{Field: "body", Message: "malformed JSON"}}
+}
+
+// The method reads an optional integer query parameter, a missing one is 0
+func queryInt(r *http.Request, errs *validation.Errors, name string) int {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		errs.Add(name, "must be an integer")
+	}
+	return number
+}
+
+// The method reads an optional "true"/"false" query parameter, a missing one is nil
+func queryBool(r *http.Request, errs *validation.Errors, name string) *bool {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		errs.Add(name, "must be true or false")
+		return nil
+	}
+	return &b
+}
+
+// The method reads a required ID path variable
+func pathID(r *http.Request, errs *validation.Errors, name string) int {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		errs.Add(name, "must be an integer")
+		return 0
+	}
+	errs.ID(name, id)
+	return id
+}
+
+// The method reads an optional "YYYY-MM-DD" query parameter
+func queryDate(r *http.Request, errs *validation.Errors, name string) string {
+	value := r.URL.Query().Get(name)
+	errs.Date(name, value)
+	return value
+}
+
+// Limits of the rows returned by a list endpoint
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// The method reads the "limit", "offset", "sort" and "order" (asc or desc) query parameters of a list endpoint.
+// The sort field must be one of the given ones.
+func queryPage(r *http.Request, errs *validation.Errors, sortFields []string) storage.Page {
+	page := storage.Page{
+		Limit:  defaultPageLimit,
+		Offset: queryInt(r, errs, "offset"),
+		SortBy: r.URL.Query().Get("sort"),
+	}
+	if r.URL.Query().Has("limit") {
+		page.Limit = queryInt(r, errs, "limit")
+		if page.Limit < 1 || page.Limit > maxPageLimit {
+			errs.Add("limit", fmt.Sprintf("must be between 1 and %d", maxPageLimit))
+		}
+	}
+	errs.NonNegative("offset", page.Offset)
+	if page.SortBy != "" {
+		errs.OneOf("sort", page.SortBy, sortFields...)
+	}
+	if order := r.URL.Query().Get("order"); order != "" {
+		errs.OneOf("order", order, "asc", "desc")
+		page.Desc = order == "desc"
+	}
+	return page
+}
+
+// The method answers with 400 and the per-field details if err is not nil
+// and returns false, so the handler can stop processing the request.
+func checkValid(w http.ResponseWriter, r *http.Request, err error) bool {
+	if err == nil {
+		return true
+	}
+	writeError(w, r, err)
+	return false
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"inv_app/services/users"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type RolePermissionsJSON struct {
+	Permissions []string `json:"permissions"`
+}
+
+func (s *Server) GetRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := users.FetchRoles(r.Context(), s.Store)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "Roles List", Data: roles}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) GetPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	permissions, err := users.FetchPermissions(r.Context(), s.Store)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "Permissions List", Data: permissions}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) UpdateRolePermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	var rolePermissions RolePermissionsJSON
+	if !checkValid(w, r, decodeJSON(r, &rolePermissions)) {
+		return
+	}
+	role := mux.Vars(r)["role"]
+	err := users.SetRolePermissions(r.Context(), s.Store, role, rolePermissions.Permissions)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "Role Permissions Updated", Data: rolePermissions}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"database/sql"
+	"inv_app/storage"
+	"inv_app/storage/postgres"
+)
+
+// Server holds the dependencies shared by all HTTP Handlers.
+// It is created once in main and the DB Pool is reused by every request.
+type Server struct {
+	DB    *sql.DB
+	Store storage.Store
+}
+
+func NewServer(db *sql.DB) *Server {
+	return &Server{DB: db, Store: postgres.New(db)}
+}
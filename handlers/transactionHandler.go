@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"encoding/json"
+	"inv_app/services/materials"
+	"inv_app/services/validation"
+	"net/http"
+)
+
+func (s *Server) ReverseTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	transactionId := pathID(r, &errs, "id")
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	reversal, operationId, err := materials.ReverseTransaction(r.Context(), s.Store, transactionId)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if operationId != 0 {
+		writeOperationPending(w, operationId)
+		return
+	}
+	res := SuccessResponseJSON{Message: "Transaction reversed", Data: reversal}
+	json.NewEncoder(w).Encode(res)
+}
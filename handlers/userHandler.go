@@ -2,25 +2,215 @@ package handlers
 
 import (
 	"encoding/json"
-	"inv_app/database"
 	"inv_app/services/users"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"net"
 	"net/http"
 )
 
 // Auth
-func AuthUsersHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
+func (s *Server) AuthUsersHandler(w http.ResponseWriter, r *http.Request) {
 	var user users.UserJSON
-	json.NewDecoder(r.Body).Decode(&user)
-	authUser, err := users.AuthUser(db, user)
+	if !checkValid(w, r, decodeJSON(r, &user)) {
+		return
+	}
+	authUser, err := users.AuthUser(r.Context(), s.Store, user, clientIP(r))
 
 	if err != nil {
-		errRes := ErrorResponseJSON{Message: err.Error()}
-		res, _ := json.Marshal(errRes)
-		http.Error(w, string(res), http.StatusUnauthorized)
+		writeError(w, r, err)
 		return
 	}
 	res := SuccessResponseJSON{Message: "User authenticated", Data: authUser}
 	json.NewEncoder(w).Encode(res)
 }
+
+func (s *Server) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	err := users.Logout(r.Context(), s.Store, requestToken(r))
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "User logged out"}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) CurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := users.FromContext(r.Context())
+	res := SuccessResponseJSON{Message: "Current User", Data: user}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var password users.PasswordJSON
+	if !checkValid(w, r, decodeJSON(r, &password)) || !checkValid(w, r, users.ValidatePasswordChange(password)) {
+		return
+	}
+	err := users.ChangePassword(r.Context(), s.Store, requestToken(r), password)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "Password changed"}
+	json.NewEncoder(w).Encode(res)
+}
+
+// Users management
+func (s *Server) GetUsersHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	page := queryPage(r, &errs, storage.UserSortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	userList, total, err := users.FetchUsers(r.Context(), s.Store, page)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeList(w, "", userList, total, page)
+}
+
+func (s *Server) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var user users.UserJSON
+	if !checkValid(w, r, decodeJSON(r, &user)) || !checkValid(w, r, users.ValidateCreate(user)) {
+		return
+	}
+	userId, err := users.CreateUser(r.Context(), s.Store, user)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	user.UserID = userId
+	user.Password = ""
+	user.IsActive = true
+	res := SuccessResponseJSON{Message: "User created", Data: user}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	userId := pathID(r, &errs, "id")
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	var user users.UpdateUserJSON
+	if !checkValid(w, r, decodeJSON(r, &user)) || !checkValid(w, r, users.ValidateUpdate(user)) {
+		return
+	}
+	err := users.UpdateUser(r.Context(), s.Store, userId, user)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "User updated"}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	userId := pathID(r, &errs, "id")
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	var password users.PasswordJSON
+	if !checkValid(w, r, decodeJSON(r, &password)) || !checkValid(w, r, users.ValidatePasswordReset(password)) {
+		return
+	}
+	err := users.ResetPassword(r.Context(), s.Store, userId, password.Password)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "Password reset"}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) UnlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	userId := pathID(r, &errs, "id")
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	err := users.UnlockUser(r.Context(), s.Store, userId)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "User unlocked"}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) GetLoginHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	filter := storage.LoginFilter{
+		Username: r.URL.Query().Get("username"),
+		Success:  queryBool(r, &errs, "success"),
+	}
+	page := queryPage(r, &errs, storage.LoginSortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	attempts, total, err := users.FetchLoginHistory(r.Context(), s.Store, filter, page)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeList(w, "", attempts, total, page)
+}
+
+// The IP address of the TCP connection, a proxy in front of the server hides the client one
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type UserCustomersJSON struct {
+	CustomerIDs []int `json:"customerIds"`
+}
+
+func (s *Server) GetUserCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	userId := pathID(r, &errs, "id")
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	customerIds, err := users.FetchUserCustomers(r.Context(), s.Store, userId)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "User Customers", Data: UserCustomersJSON{CustomerIDs: customerIds}}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) UpdateUserCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	userId := pathID(r, &errs, "id")
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	var userCustomers UserCustomersJSON
+	if !checkValid(w, r, decodeJSON(r, &userCustomers)) {
+		return
+	}
+	err := users.SetUserCustomers(r.Context(), s.Store, userId, userCustomers.CustomerIDs)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	res := SuccessResponseJSON{Message: "User Customers Updated", Data: userCustomers}
+	json.NewEncoder(w).Encode(res)
+}
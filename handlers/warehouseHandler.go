@@ -2,34 +2,36 @@ package handlers
 
 import (
 	"encoding/json"
-	"inv_app/database"
+	"inv_app/services/validation"
 	"inv_app/services/warehouses"
+	"inv_app/storage"
 	"net/http"
 )
 
-func CreateWarehouseHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-
+func (s *Server) CreateWarehouseHandler(w http.ResponseWriter, r *http.Request) {
 	var warehouse warehouses.WarehouseJSON
-	json.NewDecoder(r.Body).Decode(&warehouse)
-	err := warehouses.CreateWarehouse(warehouse, db)
+	if !checkValid(w, r, decodeJSON(r, &warehouse)) {
+		return
+	}
+	err := warehouses.CreateWarehouse(r.Context(), s.Store, warehouse)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	json.NewEncoder(w).Encode(warehouse)
 }
 
-func GetWarehouseHandler(w http.ResponseWriter, r *http.Request) {
-	db, _ := database.ConnectToDB()
-	defer db.Close()
-
-	warehouses, err := warehouses.FetchWarehouses(db)
+func (s *Server) GetWarehouseHandler(w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	page := queryPage(r, &errs, storage.WarehouseSortFields)
+	if !checkValid(w, r, errs.Err()) {
+		return
+	}
+	warehouses, total, err := warehouses.FetchWarehouses(r.Context(), s.Store, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
-	json.NewEncoder(w).Encode(warehouses)
+	writeList(w, "", warehouses, total, page)
 }
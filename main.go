@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
+	"inv_app/database"
 	routeHandlers "inv_app/handlers"
+	"inv_app/services/audit"
+	"inv_app/services/users"
 	"inv_app/services/websocket"
+	"inv_app/storage/postgres"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -15,54 +23,184 @@ import (
 )
 
 func main() {
+	// Env loading
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Fatalf("Error loading .env file")
+	}
+	port := os.Getenv("PORT")
+
+	// DB Pool shared by all Handlers and the WebSocket Hub
+	db, err := database.ConnectToDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Schema Migrations: go run . migrate up|down|status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrations(db, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Hash chain check of the Transaction Logs: go run . verify-log
+	if len(os.Args) > 1 && os.Args[1] == "verify-log" {
+		if err := verifyLog(db); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	server := routeHandlers.NewServer(db)
+	hub := websocket.NewHub(server.Store)
+
 	router := mux.NewRouter()
+	router.Use(routeHandlers.RequestIDMiddleware)
+	router.NotFoundHandler = routeHandlers.RequestIDMiddleware(http.HandlerFunc(routeHandlers.NotFoundHandler))
+	router.MethodNotAllowedHandler = routeHandlers.RequestIDMiddleware(http.HandlerFunc(routeHandlers.MethodNotAllowedHandler))
 	origins := handlers.AllowedOrigins([]string{"*"})
 	methods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"})
-	headers := handlers.AllowedHeaders([]string{"Content-Type", "Authorization"})
+	headers := handlers.AllowedHeaders([]string{"Content-Type", "Authorization", routeHandlers.RequestIDHeader})
+	exposedHeaders := handlers.ExposedHeaders([]string{routeHandlers.RequestIDHeader})
 
 	// Auth
-	router.HandleFunc("/users/auth", routeHandlers.AuthUsersHandler).Methods("POST")
+	router.HandleFunc("/users/auth", server.AuthUsersHandler).Methods("POST")
+
+	// All other Routes require a Session token and a Permission of the User Role
+	api := router.PathPrefix("/").Subrouter()
+	api.Use(server.AuthMiddleware)
+
+	api.HandleFunc("/users/me", server.CurrentUserHandler).Methods("GET")
+	api.HandleFunc("/users/logout", server.LogoutHandler).Methods("POST")
+	api.HandleFunc("/users/me/password", server.ChangePasswordHandler).Methods("PUT")
+
+	// Users
+	api.HandleFunc("/users", server.Require(users.PermUsersManage, server.GetUsersHandler)).Methods("GET")
+	api.HandleFunc("/users", server.Require(users.PermUsersManage, server.CreateUserHandler)).Methods("POST")
+	api.HandleFunc("/users/{id:[0-9]+}", server.Require(users.PermUsersManage, server.UpdateUserHandler)).Methods("PATCH")
+	api.HandleFunc("/users/{id:[0-9]+}/password", server.Require(users.PermUsersManage, server.ResetPasswordHandler)).Methods("PUT")
+	api.HandleFunc("/users/{id:[0-9]+}/unlock", server.Require(users.PermUsersManage, server.UnlockUserHandler)).Methods("POST")
+	api.HandleFunc("/users/logins", server.Require(users.PermUsersManage, server.GetLoginHistoryHandler)).Methods("GET")
+	api.HandleFunc("/users/{id:[0-9]+}/customers", server.Require(users.PermUsersManage, server.GetUserCustomersHandler)).Methods("GET")
+	api.HandleFunc("/users/{id:[0-9]+}/customers", server.Require(users.PermUsersManage, server.UpdateUserCustomersHandler)).Methods("PUT")
+
+	// API keys of the service accounts
+	api.HandleFunc("/users/{id:[0-9]+}/api_keys", server.Require(users.PermUsersManage, server.GetAPIKeysHandler)).Methods("GET")
+	api.HandleFunc("/users/{id:[0-9]+}/api_keys", server.Require(users.PermUsersManage, server.CreateAPIKeyHandler)).Methods("POST")
+	api.HandleFunc("/api_keys/{id:[0-9]+}", server.Require(users.PermUsersManage, server.RevokeAPIKeyHandler)).Methods("DELETE")
+
+	// Roles and Permissions
+	api.HandleFunc("/roles", server.Require(users.PermRolesManage, server.GetRolesHandler)).Methods("GET")
+	api.HandleFunc("/roles/{role}/permissions", server.Require(users.PermRolesManage, server.UpdateRolePermissionsHandler)).Methods("PUT")
+	api.HandleFunc("/permissions", server.Require(users.PermRolesManage, server.GetPermissionsHandler)).Methods("GET")
 
 	// WebSocket
-	router.HandleFunc("/ws", websocket.WsEndpoint)
+	api.HandleFunc("/ws", server.Require(users.PermMaterialsRead, hub.WsEndpoint))
 
 	// Routes
-	router.HandleFunc("/customers", routeHandlers.CreateCustomerHandler).Methods("POST")
-	router.HandleFunc("/customers", routeHandlers.GetCustomersHandler).Methods("GET")
+	api.HandleFunc("/customers", server.Require(users.PermCustomersCreate, server.CreateCustomerHandler)).Methods("POST")
+	api.HandleFunc("/customers", server.Require(users.PermMaterialsRead, server.GetCustomersHandler)).Methods("GET")
 
-	router.HandleFunc("/materials", routeHandlers.CreateMaterialHandler).Methods("POST")
-	router.HandleFunc("/materials", routeHandlers.GetMaterialsHandler).Methods("GET")
-	router.HandleFunc("/materials", routeHandlers.UpdateMaterialHandler).Methods("PATCH")
-	router.HandleFunc("/material_types", routeHandlers.GetMaterialTypesHandler).Methods("GET")
-	router.HandleFunc("/materials/move-to-location", routeHandlers.MoveMaterialHandler).Methods("PATCH")
-	router.HandleFunc("/materials/remove-from-location", routeHandlers.RemoveMaterialHandler).Methods("PATCH")
-	router.HandleFunc("/materials/description", routeHandlers.GetMaterialDescriptionHandler).Methods("GET")
+	api.HandleFunc("/materials", server.Require(users.PermMaterialsReceive, server.CreateMaterialHandler)).Methods("POST")
+	api.HandleFunc("/materials", server.Require(users.PermMaterialsRead, server.GetMaterialsHandler)).Methods("GET")
+	api.HandleFunc("/materials", server.Require(users.PermMaterialsUpdate, server.UpdateMaterialHandler)).Methods("PATCH")
+	api.HandleFunc("/materials/{id:[0-9]+}/history", server.Require(users.PermMaterialsRead, server.GetMaterialHistoryHandler)).Methods("GET")
+	api.HandleFunc("/material_types", server.Require(users.PermMaterialsRead, server.GetMaterialTypesHandler)).Methods("GET")
+	api.HandleFunc("/materials/move-to-location", server.Require(users.PermMaterialsMove, server.MoveMaterialHandler)).Methods("PATCH")
+	api.HandleFunc("/materials/remove-from-location", server.Require(users.PermMaterialsRemove, server.RemoveMaterialHandler)).Methods("PATCH")
+	api.HandleFunc("/materials/description", server.Require(users.PermMaterialsRead, server.GetMaterialDescriptionHandler)).Methods("GET")
 
-	router.HandleFunc("/requested_materials", routeHandlers.RequestMaterialsHandler).Methods("POST")
-	router.HandleFunc("/requested_materials", routeHandlers.GetRequestedMaterialsHandler).Methods("GET")
-	router.HandleFunc("/requested_materials", routeHandlers.UpdateRequestedMaterialHandler).Methods("PATCH")
+	api.HandleFunc("/vault/operations", server.Require(users.PermVaultManage, server.GetOperationsHandler)).Methods("GET")
+	api.HandleFunc("/vault/operations/{id:[0-9]+}/approve", server.Require(users.PermVaultManage, server.ApproveOperationHandler)).Methods("POST")
+	api.HandleFunc("/vault/operations/{id:[0-9]+}/reject", server.Require(users.PermVaultManage, server.RejectOperationHandler)).Methods("POST")
+	api.HandleFunc("/vault/dual_control", server.Require(users.PermVaultManage, server.GetDualControlHandler)).Methods("GET")
+	api.HandleFunc("/vault/dual_control", server.Require(users.PermRolesManage, server.SetDualControlHandler)).Methods("PUT")
+	api.HandleFunc("/costing_methods", server.Require(users.PermReportsRead, server.GetCostingMethodsHandler)).Methods("GET")
+	api.HandleFunc("/costing_methods", server.Require(users.PermCostingManage, server.SetCostingMethodsHandler)).Methods("PUT")
 
-	router.HandleFunc("/incoming_materials", routeHandlers.SendMaterialHandler).Methods("POST")
-	router.HandleFunc("/incoming_materials", routeHandlers.GetIncomingMaterialsHandler).Methods("GET")
-	router.HandleFunc("/incoming_materials", routeHandlers.UpdateIncomingMaterialHandler).Methods("PUT")
+	api.HandleFunc("/requested_materials", server.Require(users.PermRequestsCreate, server.RequestMaterialsHandler)).Methods("POST")
+	api.HandleFunc("/requested_materials", server.Require(users.PermMaterialsRead, server.GetRequestedMaterialsHandler)).Methods("GET")
+	api.HandleFunc("/requested_materials", server.Require(users.PermRequestsUpdate, server.UpdateRequestedMaterialHandler)).Methods("PATCH")
 
-	router.HandleFunc("/warehouses", routeHandlers.CreateWarehouseHandler).Methods("POST")
-	router.HandleFunc("/warehouses", routeHandlers.GetWarehouseHandler).Methods("GET")
-	router.HandleFunc("/locations", routeHandlers.GetLocationsHandler).Methods("GET")
-	router.HandleFunc("/available_locations", routeHandlers.GetAvailableLocationsHandler).Methods("GET")
+	api.HandleFunc("/incoming_materials", server.Require(users.PermIncomingCreate, server.SendMaterialHandler)).Methods("POST")
+	api.HandleFunc("/incoming_materials", server.Require(users.PermMaterialsRead, server.GetIncomingMaterialsHandler)).Methods("GET")
+	api.HandleFunc("/incoming_materials", server.Require(users.PermIncomingUpdate, server.UpdateIncomingMaterialHandler)).Methods("PUT")
+	api.HandleFunc("/landed_costs", server.Require(users.PermIncomingUpdate, server.GetLandedChargesHandler)).Methods("GET")
+	api.HandleFunc("/landed_costs", server.Require(users.PermIncomingUpdate, server.AllocateLandedChargeHandler)).Methods("POST")
 
-	router.HandleFunc("/reports/transactions", routeHandlers.GetTransactionsReport).Methods("GET")
-	router.HandleFunc("/reports/balance", routeHandlers.GetBalanceReport).Methods("GET")
+	api.HandleFunc("/warehouses", server.Require(users.PermWarehousesCreate, server.CreateWarehouseHandler)).Methods("POST")
+	api.HandleFunc("/warehouses", server.Require(users.PermMaterialsRead, server.GetWarehouseHandler)).Methods("GET")
+	api.HandleFunc("/locations", server.Require(users.PermMaterialsRead, server.GetLocationsHandler)).Methods("GET")
+	api.HandleFunc("/available_locations", server.Require(users.PermMaterialsRead, server.GetAvailableLocationsHandler)).Methods("GET")
 
-	router.HandleFunc("/import_data", routeHandlers.ImportData).Methods("POST")
+	api.HandleFunc("/reports/transactions", server.Require(users.PermReportsRead, server.GetTransactionsReport)).Methods("GET")
+	api.HandleFunc("/reports/balance", server.Require(users.PermReportsRead, server.GetBalanceReport)).Methods("GET")
+	api.HandleFunc("/exchange_rates", server.Require(users.PermReportsRead, server.GetExchangeRatesHandler)).Methods("GET")
+	api.HandleFunc("/exchange_rates", server.Require(users.PermRatesManage, server.SetExchangeRateHandler)).Methods("PUT")
+	api.HandleFunc("/transactions/{id:[0-9]+}/reverse", server.Require(users.PermTransactionsReverse, server.ReverseTransactionHandler)).Methods("POST")
 
-	// Env loading
-	err := godotenv.Load(".env")
+	api.HandleFunc("/audit", server.Require(users.PermAuditRead, server.GetAuditHandler)).Methods("GET")
+	api.HandleFunc("/audit/transactions_chain", server.Require(users.PermAuditRead, server.VerifyTransactionsHandler)).Methods("GET")
+
+	api.HandleFunc("/import_data", server.Require(users.PermDataImport, server.ImportData)).Methods("POST")
+
+	fmt.Println("Server running on port: " + port)
+	log.Fatal(http.ListenAndServe(":"+port, handlers.CORS(origins, methods, headers, exposedHeaders)(router)))
+}
+
+// The method prints the result of the chain check and fails if the chain is broken
+func verifyLog(db *sql.DB) error {
+	report, err := audit.VerifyTransactions(context.Background(), postgres.New(db))
 	if err != nil {
-		log.Fatalf("Error loading .env file")
+		return err
 	}
-	port := os.Getenv("PORT")
+	fmt.Printf("Checked: %d, unchained: %d, last transaction: %d\n", report.Checked, report.Unchained, report.LastTransactionID)
+	if !report.Valid {
+		return fmt.Errorf("Broken link at transaction %d: %s", report.BrokenLink.TransactionID, report.BrokenLink.Reason)
+	}
+	fmt.Println("The chain is valid")
+	return nil
+}
 
-	fmt.Println("Server running on port: " + port)
-	log.Fatal(http.ListenAndServe(":"+port, handlers.CORS(origins, methods, headers)(router)))
+func runMigrations(db *sql.DB, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: migrate up|down|status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(db)
+		for _, migration := range applied {
+			fmt.Printf("Applied: %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+	case "down":
+		reverted, err := database.MigrateDown(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted: %d_%s\n", reverted.Version, reverted.Name)
+	case "status":
+		statuses, err := database.MigrationsStatus(db)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = "applied at " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%d_%s: %s\n", status.Version, status.Name, appliedAt)
+		}
+	default:
+		return errors.New("Unknown migrate command: " + args[0])
+	}
+	return nil
 }
@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"inv_app/storage"
+	"time"
+)
+
+// Actions of the audit log
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionReceive = "receive"
+	ActionMove    = "move"
+	ActionRemove  = "remove"
+	ActionRequest = "request"
+	ActionApprove = "approve"
+	ActionReject  = "reject"
+	ActionImport  = "import"
+	ActionUnlock  = "unlock"
+	ActionRevoke  = "revoke"
+	ActionReverse = "reverse"
+	// A new password of the User, the password itself is never audited
+	ActionSetPassword = "set_password"
+)
+
+// Entities of the audit log
+const (
+	EntityMaterial     = "material"
+	EntityIncoming     = "incoming_material"
+	EntityRequest      = "requested_material"
+	EntityCustomer     = "customer"
+	EntityWarehouse    = "warehouse"
+	EntityLocation     = "location"
+	EntityOperation    = "pending_operation"
+	EntityDualControl  = "dual_control"
+	EntityUser         = "user"
+	EntityRole         = "role"
+	EntityAPIKey       = "api_key"
+	EntityTransaction  = "transaction"
+	EntityCosting      = "costing_methods"
+	EntityRate         = "exchange_rate"
+	EntityLandedCharge = "landed_charge"
+)
+
+// Change is one mutation of an Entity by the User.
+// Before is nil for a created Entity, After is nil for a deleted one, both are encoded as JSON objects.
+type Change struct {
+	UserID   int
+	Action   string
+	Entity   string
+	EntityID any
+	Before   any
+	After    any
+}
+
+// FieldChange is the value of a field before and after the Change, nil if the field was not set
+type FieldChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// The method adds the Change to the audit log in the Transaction of the mutation,
+// so the Entry is kept only if the mutation is committed.
+func Record(ctx context.Context, tx storage.Tx, change Change) error {
+	diff, err := Diff(change.Before, change.After)
+	if err != nil {
+		return err
+	}
+	changes, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	entityId := ""
+	if change.EntityID != nil {
+		entityId = fmt.Sprint(change.EntityID)
+	}
+	return tx.Audit().Add(ctx, storage.AuditEntry{
+		UserID:    change.UserID,
+		Action:    change.Action,
+		Entity:    change.Entity,
+		EntityID:  entityId,
+		Changes:   changes,
+		CreatedAt: time.Now(),
+	})
+}
+
+// The method returns the JSON fields whose values differ between before and after
+func Diff(before any, after any) (map[string]FieldChange, error) {
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make(map[string]FieldChange)
+	for field, value := range beforeFields {
+		if !bytes.Equal(value, afterFields[field]) {
+			diff[field] = FieldChange{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			diff[field] = FieldChange{After: value}
+		}
+	}
+	return diff, nil
+}
+
+func jsonFields(value any) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if value == nil {
+		return fields, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+type EntryJSON struct {
+	AuditID   int             `json:"auditId"`
+	UserID    int             `json:"userId"`
+	Username  string          `json:"username"`
+	Action    string          `json:"action"`
+	Entity    string          `json:"entity"`
+	EntityID  string          `json:"entityId"`
+	Changes   json.RawMessage `json:"changes"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+func FetchEntries(ctx context.Context, store storage.Store, filter storage.AuditFilter, page storage.Page) ([]EntryJSON, int, error) {
+	list, total, err := store.Audit().List(ctx, filter, page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]EntryJSON, 0, len(list))
+	for _, entry := range list {
+		entries = append(entries, EntryJSON{
+			AuditID:   entry.AuditID,
+			UserID:    entry.UserID,
+			Username:  entry.Username,
+			Action:    entry.Action,
+			Entity:    entry.Entity,
+			EntityID:  entry.EntityID,
+			Changes:   entry.Changes,
+			CreatedAt: entry.CreatedAt,
+		})
+	}
+	return entries, total, nil
+}
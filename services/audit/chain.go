@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"context"
+	"inv_app/storage"
+)
+
+// ChainReportJSON is the result of walking the hash chain of the Transaction Logs.
+// Unchained rows were written before the chain was introduced and cannot be verified.
+type ChainReportJSON struct {
+	Valid             bool            `json:"valid"`
+	Checked           int             `json:"checked"`
+	Unchained         int             `json:"unchained"`
+	LastTransactionID int             `json:"lastTransactionId"`
+	BrokenLink        *BrokenLinkJSON `json:"brokenLink,omitempty"`
+}
+
+// BrokenLinkJSON is the first Transaction Log that does not match the chain
+type BrokenLinkJSON struct {
+	TransactionID int    `json:"transactionId"`
+	Reason        string `json:"reason"`
+}
+
+const chainBatchSize = 1000
+
+// The method recomputes the hash of every Transaction Log in the order of the chain
+// and reports the first row whose content, previous hash or position does not match.
+// The head is read first and the rows chained after it are not checked, so concurrent stock changes do not break the report.
+func VerifyTransactions(ctx context.Context, store storage.Store) (ChainReportJSON, error) {
+	report := ChainReportJSON{Valid: true}
+	broken := func(transactionId int, reason string) (ChainReportJSON, error) {
+		report.Valid = false
+		report.BrokenLink = &BrokenLinkJSON{TransactionID: transactionId, Reason: reason}
+		return report, nil
+	}
+
+	headId, headHash, err := store.Transactions().ChainHead(ctx)
+	if err != nil {
+		return ChainReportJSON{}, err
+	}
+
+	prevHash := ""
+walk:
+	for {
+		trxList, err := store.Transactions().Chain(ctx, report.LastTransactionID, chainBatchSize)
+		if err != nil {
+			return ChainReportJSON{}, err
+		}
+
+		for _, trx := range trxList {
+			if trx.Hash != "" && trx.TransactionID > headId {
+				break walk
+			}
+			report.LastTransactionID = trx.TransactionID
+			if trx.Hash == "" {
+				if prevHash != "" {
+					return broken(trx.TransactionID, "The row has no hash, it was added outside of the chain")
+				}
+				report.Unchained++
+				continue
+			}
+			if trx.PrevHash != prevHash {
+				return broken(trx.TransactionID, "The previous hash does not match, a row before it was changed or removed")
+			}
+			if storage.TransactionHash(trx) != trx.Hash {
+				return broken(trx.TransactionID, "The row content does not match its hash")
+			}
+			prevHash = trx.Hash
+			report.Checked++
+		}
+
+		if len(trxList) < chainBatchSize {
+			break
+		}
+	}
+
+	if headHash != prevHash {
+		return broken(headId, "The last chained row is missing")
+	}
+	return report, nil
+}
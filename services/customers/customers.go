@@ -1,7 +1,10 @@
 package customers
 
 import (
-	"database/sql"
+	"context"
+	"inv_app/services/audit"
+	"inv_app/services/users"
+	"inv_app/storage"
 	"log"
 )
 
@@ -10,43 +13,29 @@ type CustomerJSON struct {
 	Code string `json:"customerCode"`
 }
 
-type CustomerDB struct {
-	ID   int    `field:"id"`
-	Name string `field:"name"`
-	Code string `field:"customer_code"`
-}
-
-func CreateCustomer(customer CustomerJSON, db *sql.DB) error {
-	_, err := db.Exec("INSERT INTO customers (name, customer_code) VALUES ($1,$2)",
-		customer.Name, customer.Code)
+func CreateCustomer(ctx context.Context, store storage.Store, customer CustomerJSON) error {
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		customerId, err := tx.Customers().Create(ctx, customer.Name, customer.Code)
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		return err
-	}
-	return nil
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   users.UserID(ctx),
+			Action:   audit.ActionCreate,
+			Entity:   audit.EntityCustomer,
+			EntityID: customerId,
+			After:    customer,
+		})
+	})
 }
 
-func FetchCustomers(db *sql.DB) ([]CustomerDB, error) {
-	rows, err := db.Query("SELECT * FROM customers ORDER BY name ASC;")
+func FetchCustomers(ctx context.Context, store storage.Store, page storage.Page) ([]storage.CustomerDB, int, error) {
+	filter := storage.CustomerFilter{CustomerIDs: users.CustomerScope(ctx)}
+	customers, total, err := store.Customers().List(ctx, filter, page)
 	if err != nil {
-		log.Println("Error fetchCustomers1: ", err)
-		return nil, err
+		log.Println("Error fetchCustomers: ", err)
+		return nil, 0, err
 	}
-	defer rows.Close()
-
-	var customers []CustomerDB
-
-	for rows.Next() {
-		var customer CustomerDB
-		if err := rows.Scan(&customer.ID, &customer.Name, &customer.Code); err != nil {
-			log.Println("Error fetchCustomers2: ", err)
-			return customers, err
-		}
-		customers = append(customers, customer)
-	}
-	if err = rows.Err(); err != nil {
-		return customers, err
-	}
-
-	return customers, nil
+	return customers, total, nil
 }
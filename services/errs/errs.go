@@ -0,0 +1,39 @@
+package errs
+
+import (
+	"errors"
+	"inv_app/storage"
+)
+
+// The package keeps the sentinel errors returned by the Business Logic.
+// Handlers branch on them with errors.Is to choose the HTTP status and the error code,
+// so the messages stay free to change.
+
+var (
+	ErrNotFound             = storage.ErrNotFound
+	ErrConflict             = storage.ErrConflict
+	ErrValidation           = errors.New("Invalid request")
+	ErrInsufficientQuantity = errors.New("Insufficient quantity")
+	ErrUnauthorized         = errors.New("Unauthorized")
+	ErrForbidden            = errors.New("Forbidden")
+	ErrTooManyRequests      = errors.New("Too many requests")
+)
+
+// Error is a sentinel with its own message and optional details for the client.
+type Error struct {
+	Sentinel error
+	Message  string
+	Details  any
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Sentinel
+}
+
+func New(sentinel error, message string, details any) error {
+	return &Error{Sentinel: sentinel, Message: message, Details: details}
+}
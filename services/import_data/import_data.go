@@ -1,25 +1,31 @@
 package import_data
 
 import (
-	"database/sql"
+	"context"
+	"inv_app/services/audit"
+	"inv_app/services/users"
+	"inv_app/storage"
 	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 type ImportDataJSON struct {
-	CustomerName  string  `json:"Customer Name"`
-	CustomerCode  string  `json:"Customer Code"`
-	WarehouseName string  `json:"Warehouse Name"`
-	LocationName  string  `json:"Location Name"`
-	StockID       string  `json:"Stock ID"`
-	MaterialType  string  `json:"Material Type"`
-	Description   string  `json:"Description"`
-	Notes         string  `json:"Notes"`
-	Qty           int     `json:"Qty"`
-	MinQty        int     `json:"Min Qty"`
-	MaxQty        int     `json:"Max Qty"`
-	IsActive      bool    `json:"Is Active"`
-	Owner         string  `json:"Owner"`
-	UnitCost      float64 `json:"Unit Cost"`
+	CustomerName  string          `json:"Customer Name"`
+	CustomerCode  string          `json:"Customer Code"`
+	WarehouseName string          `json:"Warehouse Name"`
+	LocationName  string          `json:"Location Name"`
+	StockID       string          `json:"Stock ID"`
+	MaterialType  string          `json:"Material Type"`
+	Description   string          `json:"Description"`
+	Notes         string          `json:"Notes"`
+	Qty           int             `json:"Qty"`
+	MinQty        int             `json:"Min Qty"`
+	MaxQty        int             `json:"Max Qty"`
+	IsActive      bool            `json:"Is Active"`
+	Owner         string          `json:"Owner"`
+	UnitCost      decimal.Decimal `json:"Unit Cost"`
 }
 
 type ImportJSON struct {
@@ -40,7 +46,7 @@ type ImportData struct {
 	MaxQty        int
 	IsActive      bool
 	Owner         string
-	UnitCost      float64
+	UnitCost      decimal.Decimal
 	ERR_REASON    string
 }
 
@@ -52,7 +58,7 @@ type ImportResponse struct {
 	Not_Imported_Data    []ImportData
 }
 
-func ImportDataToDB(db *sql.DB, data ImportJSON) (ImportResponse, error) {
+func ImportDataToDB(ctx context.Context, store storage.Store, data ImportJSON) (ImportResponse, error) {
 	materialsCounter := 0
 	notImportedData := []ImportData{}
 	locations := []string{}
@@ -101,106 +107,19 @@ func ImportDataToDB(db *sql.DB, data ImportJSON) (ImportResponse, error) {
 			continue
 		}
 
-		// Check for a customer
-		var customerId int
-		err := db.QueryRow(`SELECT customer_id FROM customers
-						WHERE name = $1
-						AND customer_code = $2`,
-			importData.CustomerName, importData.CustomerCode).
-			Scan(&customerId)
-
-		if customerId == 0 {
-			err = db.QueryRow(`
-			INSERT INTO customers(name,customer_code) VALUES($1,$2) RETURNING customer_id`,
-				importData.CustomerName, importData.CustomerCode).
-				Scan(&customerId)
-		}
-		if err != nil {
-			importData.ERR_REASON = err.Error()
-			notImportedData = append(notImportedData, importData)
-			continue
-		}
-
-		// Check for a warehouse
-		var warehouseId int
-		err = db.QueryRow(`SELECT warehouse_id FROM warehouses
-						WHERE name = $1
-						`,
-			importData.WarehouseName).
-			Scan(&warehouseId)
-
-		if warehouseId == 0 {
-			err = db.QueryRow(`
-					INSERT INTO warehouses(name) VALUES($1) RETURNING warehouse_id`,
-				importData.WarehouseName).
-				Scan(&warehouseId)
-		}
-		if err != nil {
-			importData.ERR_REASON = err.Error()
-			notImportedData = append(notImportedData, importData)
-			continue
-		}
-
-		// Check for a location
-		var locationId int
-		err = db.QueryRow(`SELECT location_id FROM locations
-						WHERE name = $1
-						AND warehouse_id = $2
-						`,
-			importData.LocationName, warehouseId).
-			Scan(&locationId)
-
-		if locationId == 0 {
-			err = db.QueryRow(`
-			INSERT INTO locations(name,warehouse_id) VALUES($1,$2) RETURNING location_id`,
-				importData.LocationName, warehouseId).
-				Scan(&locationId)
-		}
-		if err != nil {
-			importData.ERR_REASON = err.Error()
-			notImportedData = append(notImportedData, importData)
-			continue
-		}
-
-		var materialId int
-		err = db.QueryRow(`
-			INSERT INTO materials(
-					stock_id,location_id,customer_id,material_type,
-					description,notes,quantity,min_required_quantity,
-					max_required_quantity,is_active,owner,updated_at,is_primary)
-			VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,NOW(),false)
-			RETURNING material_id`,
-			importData.StockID, locationId, customerId, importData.MaterialType,
-			importData.Description, importData.Notes, importData.Qty, importData.MinQty, importData.MaxQty, importData.IsActive, importData.Owner).
-			Scan(&materialId)
-		if err != nil {
-			importData.ERR_REASON = err.Error()
-			notImportedData = append(notImportedData, importData)
-			locations = append(locations, importData.LocationName)
-			continue
-		}
-
-		var priceId int
-		err = db.QueryRow(`
-		INSERT INTO prices(material_id,quantity,cost)
-		VALUES($1,$2,$3)
-		RETURNING price_id`,
-			materialId, importData.Qty, importData.UnitCost,
-		).Scan(&priceId)
-		if err != nil {
-			importData.ERR_REASON = err.Error()
-			notImportedData = append(notImportedData, importData)
-			continue
-		}
-
-		_, err = db.Query(`
-			INSERT INTO transactions_log(price_id, quantity_change, notes, job_ticket, updated_at)
-			VALUES($1,$2,$3,$4,NOW())`,
-			priceId, importData.Qty, importData.Notes, "Imported",
-		)
+		// Every record is imported in its own Transaction, so a failed record leaves no partial data
+		locationFailed := false
+		err := storage.WithTx(ctx, store, func(tx storage.Tx) error {
+			var err error
+			locationFailed, err = importRecord(ctx, tx, importData)
+			return err
+		})
 		if err != nil {
 			importData.ERR_REASON = err.Error()
 			notImportedData = append(notImportedData, importData)
+			if locationFailed {
+				locations = append(locations, importData.LocationName)
+			}
 			continue
 		}
 
@@ -216,3 +135,91 @@ func ImportDataToDB(db *sql.DB, data ImportJSON) (ImportResponse, error) {
 		Not_Imported_Data:    notImportedData}
 	return res, nil
 }
+
+// The method returns true if the Material could not be placed to the Location
+func importRecord(ctx context.Context, tx storage.Tx, importData ImportData) (bool, error) {
+	if err := tx.Transactions().LockChain(ctx); err != nil {
+		return false, err
+	}
+
+	// Check for a customer
+	customerId, err := tx.Customers().Find(ctx, importData.CustomerName, importData.CustomerCode)
+	if err == nil && customerId == 0 {
+		customerId, err = tx.Customers().Create(ctx, importData.CustomerName, importData.CustomerCode)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Check for a warehouse
+	warehouseId, err := tx.Warehouses().Find(ctx, importData.WarehouseName)
+	if err == nil && warehouseId == 0 {
+		warehouseId, err = tx.Warehouses().Create(ctx, importData.WarehouseName)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Check for a location
+	locationId, err := tx.Locations().Find(ctx, importData.LocationName, warehouseId)
+	if err == nil && locationId == 0 {
+		locationId, err = tx.Locations().Create(ctx, importData.LocationName, warehouseId)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	materialId, err := tx.Materials().Create(ctx, storage.MaterialDB{
+		StockID:      importData.StockID,
+		LocationID:   locationId,
+		CustomerID:   customerId,
+		MaterialType: importData.MaterialType,
+		Description:  importData.Description,
+		Notes:        importData.Notes,
+		Quantity:     importData.Qty,
+		MinQty:       importData.MinQty,
+		MaxQty:       importData.MaxQty,
+		IsActive:     importData.IsActive,
+		Owner:        importData.Owner,
+		UpdatedAt:    time.Now(),
+		IsPrimary:    false,
+	})
+	if err != nil {
+		return true, err
+	}
+
+	priceId, err := tx.Prices().Create(ctx, storage.Price{
+		MaterialID:  materialId,
+		Qty:         importData.Qty,
+		Cost:        storage.UnitCost(importData.UnitCost),
+		Currency:    storage.BaseCurrency(),
+		ReceivedAt:  time.Now(),
+		OriginalQty: importData.Qty,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Transactions().Add(ctx, storage.Transaction{
+		PriceID:       priceId,
+		Qty:           importData.Qty,
+		Notes:         importData.Notes,
+		JobTicket:     "Imported",
+		UpdatedAt:     time.Now(),
+		Type:          storage.TransactionImport,
+		UserID:        users.UserID(ctx),
+		ToLocationID:  locationId,
+		CorrelationID: storage.NewCorrelationID(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return false, audit.Record(ctx, tx, audit.Change{
+		UserID:   users.UserID(ctx),
+		Action:   audit.ActionImport,
+		Entity:   audit.EntityMaterial,
+		EntityID: materialId,
+		After:    importData,
+	})
+}
@@ -0,0 +1,302 @@
+package materials
+
+import (
+	"context"
+	"inv_app/services/audit"
+	"inv_app/services/errs"
+	"inv_app/services/users"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"slices"
+	"time"
+)
+
+// Dual control: moves, removals and reversals of the material types in dual_control_types are created
+// as pending Operations. The stock changes only once a second vault User approves the Operation,
+// and the Transaction Logs keep both the requesting and the approving User.
+
+const (
+	OperationMove    = "move"
+	OperationRemove  = "remove"
+	OperationReverse = "reverse"
+)
+
+// Statuses of a pending Operation
+const (
+	OperationPending  = "pending"
+	OperationApproved = "approved"
+	OperationRejected = "rejected"
+)
+
+var operationStatuses = []string{OperationPending, OperationApproved, OperationRejected}
+
+type OperationJSON struct {
+	OperationID       int        `json:"operationId"`
+	Operation         string     `json:"operation"`
+	MaterialID        int        `json:"materialId"`
+	StockID           string     `json:"stockId"`
+	MaterialType      string     `json:"type"`
+	Qty               int        `json:"quantity"`
+	LocationID        int        `json:"locationId,omitempty"`
+	JobTicket         string     `json:"jobTicket,omitempty"`
+	SerialNumberRange string     `json:"serialNumberRange,omitempty"`
+	RequestID         int        `json:"requestId,omitempty"`
+	Status            string     `json:"status"`
+	RequestedBy       int        `json:"requestedBy"`
+	RequestedAt       time.Time  `json:"requestedAt"`
+	DecidedBy         int        `json:"decidedBy,omitempty"`
+	DecidedAt         *time.Time `json:"decidedAt,omitempty"`
+	// The cost layers of a Material under the specific identification
+	Layers []storage.Layer `json:"layers,omitempty"`
+	// The Transaction Log of a reversal
+	TransactionID int `json:"transactionId,omitempty"`
+}
+
+// DualControlJSON lists the material types whose moves and removals need an approval
+type DualControlJSON struct {
+	MaterialTypes []string `json:"materialTypes"`
+}
+
+// The method creates a pending Operation and returns its ID if the Material type is under dual control,
+// otherwise it returns 0 and the caller makes the change at once.
+// The quantity and the chosen cost layers are checked now as well, so an Operation that cannot succeed is not waiting for an approval.
+func requestApproval(ctx context.Context, tx storage.Tx, operation string, material MaterialJSON) (int, error) {
+	currMaterial, err := tx.Materials().Get(ctx, material.MaterialID)
+	if err != nil {
+		return 0, err
+	}
+	dualControlTypes, err := tx.Operations().DualControlTypes(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(dualControlTypes, currMaterial.MaterialType) {
+		return 0, nil
+	}
+	if err := requireVaultAccess(ctx, currMaterial.MaterialType); err != nil {
+		return 0, err
+	}
+	if currMaterial.Quantity < material.Qty {
+		operationName := "moving"
+		if operation == OperationRemove {
+			operationName = "removing"
+		}
+		return 0, insufficientQuantity(operationName, material.Qty, currMaterial.Quantity)
+	}
+	method, err := costingMethodOf(ctx, tx, currMaterial.Owner, currMaterial.CustomerID)
+	if err != nil {
+		return 0, err
+	}
+	available, err := tx.Prices().ListAvailable(ctx, currMaterial.MaterialID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := method.pick(available, material.Qty, material.Layers); err != nil {
+		return 0, err
+	}
+
+	return createOperation(ctx, tx, storage.PendingOperation{
+		Operation:         operation,
+		MaterialID:        material.MaterialID,
+		StockID:           currMaterial.StockID,
+		MaterialType:      currMaterial.MaterialType,
+		Qty:               material.Qty,
+		LocationID:        material.LocationID,
+		JobTicket:         material.JobTicket,
+		SerialNumberRange: material.SerialNumberRange,
+		RequestID:         material.RequestID,
+		Layers:            material.Layers,
+	})
+}
+
+// The method adds the Operation as pending, requested by the authenticated User, and returns its ID
+func createOperation(ctx context.Context, tx storage.Tx, pending storage.PendingOperation) (int, error) {
+	user, ok := users.FromContext(ctx)
+	if !ok {
+		return 0, errs.New(errs.ErrUnauthorized, "Authentication required", nil)
+	}
+	pending.Status = OperationPending
+	pending.RequestedBy = user.UserID
+	pending.RequestedAt = time.Now()
+
+	var err error
+	pending.OperationID, err = tx.Operations().Create(ctx, pending)
+	if err != nil {
+		return 0, err
+	}
+
+	err = audit.Record(ctx, tx, audit.Change{
+		UserID:   user.UserID,
+		Action:   audit.ActionCreate,
+		Entity:   audit.EntityOperation,
+		EntityID: pending.OperationID,
+		After:    OperationJSON(pending),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return pending.OperationID, nil
+}
+
+func ValidateOperationStatus(status string) error {
+	errList := validation.Errors{}
+	if status != "" {
+		errList.OneOf("status", status, operationStatuses...)
+	}
+	return errList.Err()
+}
+
+func FetchOperations(ctx context.Context, store storage.Store, status string, page storage.Page) ([]OperationJSON, int, error) {
+	list, total, err := store.Operations().List(ctx, storage.OperationFilter{Status: status}, page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	operations := make([]OperationJSON, 0, len(list))
+	for _, operation := range list {
+		operations = append(operations, OperationJSON(operation))
+	}
+	return operations, total, nil
+}
+
+// The method makes the stock change of the pending Operation.
+// The approving User needs vault.manage and must not be the one who requested the Operation.
+func ApproveOperation(ctx context.Context, store storage.Store, operationId int) error {
+	if err := users.Require(ctx, users.PermVaultManage); err != nil {
+		return err
+	}
+	approver, _ := users.FromContext(ctx)
+
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		operation, err := pendingOperation(ctx, tx, operationId)
+		if err != nil {
+			return err
+		}
+		if operation.RequestedBy == approver.UserID {
+			return errs.New(errs.ErrForbidden, "The Operation must be approved by a second User", nil)
+		}
+
+		material := MaterialJSON{
+			MaterialID:        operation.MaterialID,
+			LocationID:        operation.LocationID,
+			Qty:               operation.Qty,
+			JobTicket:         operation.JobTicket,
+			SerialNumberRange: operation.SerialNumberRange,
+			RequestID:         operation.RequestID,
+			Layers:            operation.Layers,
+		}
+		by := stockUsers{userId: operation.RequestedBy, approvedBy: approver.UserID}
+		switch operation.Operation {
+		case OperationMove:
+			err = moveMaterial(ctx, tx, material, by)
+		case OperationReverse:
+			_, err = reverseTransaction(ctx, tx, operation.TransactionID, by)
+		default:
+			err = removeMaterial(ctx, tx, material, by)
+		}
+		if err != nil {
+			return err
+		}
+
+		return decideOperation(ctx, tx, operation, OperationApproved, approver.UserID)
+	})
+}
+
+// The method cancels the pending Operation without changing the stock.
+// Any vault User may reject it, including the one who requested it.
+func RejectOperation(ctx context.Context, store storage.Store, operationId int) error {
+	if err := users.Require(ctx, users.PermVaultManage); err != nil {
+		return err
+	}
+	user, _ := users.FromContext(ctx)
+
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		operation, err := pendingOperation(ctx, tx, operationId)
+		if err != nil {
+			return err
+		}
+		return decideOperation(ctx, tx, operation, OperationRejected, user.UserID)
+	})
+}
+
+func decideOperation(ctx context.Context, tx storage.Tx, operation storage.PendingOperation, status string, decidedBy int) error {
+	decidedAt := time.Now()
+	if err := tx.Operations().Decide(ctx, operation.OperationID, status, decidedBy, decidedAt); err != nil {
+		return err
+	}
+
+	action := audit.ActionApprove
+	if status == OperationRejected {
+		action = audit.ActionReject
+	}
+	before := OperationJSON(operation)
+	after := before
+	after.Status, after.DecidedBy, after.DecidedAt = status, decidedBy, &decidedAt
+	return audit.Record(ctx, tx, audit.Change{
+		UserID:   decidedBy,
+		Action:   action,
+		Entity:   audit.EntityOperation,
+		EntityID: operation.OperationID,
+		Before:   before,
+		After:    after,
+	})
+}
+
+// Returns errs.ErrConflict if the Operation is already approved or rejected
+func pendingOperation(ctx context.Context, tx storage.Tx, operationId int) (storage.PendingOperation, error) {
+	operation, err := tx.Operations().GetForUpdate(ctx, operationId)
+	if err != nil {
+		return storage.PendingOperation{}, err
+	}
+	if operation.Status != OperationPending {
+		return storage.PendingOperation{}, errs.New(errs.ErrConflict,
+			"The Operation is already "+operation.Status,
+			map[string]string{"status": operation.Status},
+		)
+	}
+	return operation, nil
+}
+
+func FetchDualControl(ctx context.Context, store storage.Store) (DualControlJSON, error) {
+	materialTypes, err := store.Operations().DualControlTypes(ctx)
+	if err != nil {
+		return DualControlJSON{}, err
+	}
+	return DualControlJSON{MaterialTypes: materialTypes}, nil
+}
+
+// The method replaces the material types under dual control, unknown types are rejected.
+// Pending Operations of the types removed from the list still need an approval.
+func SetDualControl(ctx context.Context, store storage.Store, dualControl DualControlJSON) error {
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		materialTypes, err := tx.Materials().Types(ctx)
+		if err != nil {
+			return err
+		}
+
+		errList := validation.Errors{}
+		for _, materialType := range dualControl.MaterialTypes {
+			if !slices.Contains(materialTypes, materialType) {
+				errList.Add("materialTypes", "unknown material type: "+materialType)
+			}
+		}
+		if err := errList.Err(); err != nil {
+			return err
+		}
+
+		before, err := tx.Operations().DualControlTypes(ctx)
+		if err != nil {
+			return err
+		}
+		if err := tx.Operations().SetDualControlTypes(ctx, dualControl.MaterialTypes); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.Change{
+			UserID: users.UserID(ctx),
+			Action: audit.ActionUpdate,
+			Entity: audit.EntityDualControl,
+			Before: DualControlJSON{MaterialTypes: before},
+			After:  dualControl,
+		})
+	})
+}
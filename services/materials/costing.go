@@ -0,0 +1,307 @@
+package materials
+
+import (
+	"context"
+	"inv_app/services/audit"
+	"inv_app/services/users"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Costing: the method of the Material decides which cost layers leave the stock on a move or a removal.
+// FIFO takes the oldest receipt first, the specific identification takes the layers chosen by the User
+// and the weighted average keeps a single layer per currency at the average cost (and one unit at the rounding
+// difference, if any), rebalanced whenever stock arrives.
+// The rules in costing_methods select the method by the Owner and/or the Customer, the other Materials use FIFO.
+
+type costingMethod interface {
+	// Returns the available Prices to take the quantity from, each with the quantity to take
+	pick(available []storage.Price, qty int, chosen []storage.Layer) ([]storage.Price, error)
+	// Called once stock is added to the Material, trx is the template of the Transaction Logs
+	rebalance(ctx context.Context, tx storage.Tx, materialId int, trx storage.Transaction) error
+}
+
+var costingMethods = map[string]costingMethod{
+	storage.CostingFIFO:     fifoCosting{},
+	storage.CostingAverage:  averageCosting{},
+	storage.CostingSpecific: specificCosting{},
+}
+
+type fifoCosting struct{}
+
+func (fifoCosting) pick(available []storage.Price, qty int, chosen []storage.Layer) ([]storage.Price, error) {
+	if len(chosen) > 0 {
+		return nil, chosenLayersError("can be chosen for the specific identification only")
+	}
+	picked := []storage.Price{}
+	for _, price := range available {
+		if qty == 0 {
+			break
+		}
+		price.Qty = min(qty, price.Qty)
+		qty -= price.Qty
+		picked = append(picked, price)
+	}
+	return picked, nil
+}
+
+func (fifoCosting) rebalance(ctx context.Context, tx storage.Tx, materialId int, trx storage.Transaction) error {
+	return nil
+}
+
+// The average layer is taken like a FIFO one, there is only one between the receipts
+type averageCosting struct {
+	fifoCosting
+}
+
+// The method replaces the available layers of each currency by one at their weighted average cost.
+// The old layers are emptied by adjustment Transaction Logs, so the reports keep the value of the stock.
+func (averageCosting) rebalance(ctx context.Context, tx storage.Tx, materialId int, trx storage.Transaction) error {
+	layers, err := tx.Prices().ListAvailableForUpdate(ctx, materialId)
+	if err != nil || len(layers) < 2 {
+		return err
+	}
+
+	// Costs in different currencies are not added up, the layers are listed oldest first per currency
+	currencies := []string{}
+	byCurrency := map[string][]storage.Price{}
+	for _, layer := range layers {
+		if _, ok := byCurrency[layer.Currency]; !ok {
+			currencies = append(currencies, layer.Currency)
+		}
+		byCurrency[layer.Currency] = append(byCurrency[layer.Currency], layer)
+	}
+
+	trx.Type = storage.TransactionAdjustment
+	trx.CorrelationID = storage.NewCorrelationID()
+	trx.UpdatedAt = time.Now()
+	for _, currency := range currencies {
+		if err := averageLayers(ctx, tx, materialId, byCurrency[currency], trx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The method merges the layers of one currency into a new one worth the value of the emptied layers,
+// see addLayerOfValue. A single layer is kept as it is.
+func averageLayers(ctx context.Context, tx storage.Tx, materialId int, layers []storage.Price, trx storage.Transaction) error {
+	if len(layers) < 2 {
+		return nil
+	}
+
+	qty, value, landedValue := 0, decimal.Decimal{}, decimal.Decimal{}
+	for _, layer := range layers {
+		if _, err := tx.Prices().AddQuantity(ctx, layer.PriceID, -layer.Qty); err != nil {
+			return err
+		}
+		outTrx := trx
+		outTrx.PriceID = layer.PriceID
+		outTrx.Qty = -layer.Qty
+		outTrx.Notes = "Averaged into a new cost layer"
+		if err := tx.Transactions().Add(ctx, outTrx); err != nil {
+			return err
+		}
+		qty += layer.Qty
+		value = value.Add(storage.ExtendedValue(layer.Qty, layer.Cost))
+		landedValue = landedValue.Add(storage.ExtendedValue(layer.Qty, layer.LandedCost))
+	}
+
+	average := storage.Price{
+		MaterialID: materialId,
+		Qty:        qty,
+		Currency:   layers[0].Currency,
+		ReceivedAt: layers[0].ReceivedAt,
+	}
+	return addLayerOfValue(ctx, tx, average, value, landedValue, trx, "Weighted average cost", "Weighted average cost rounding difference")
+}
+
+// The method adds a layer of the quantity worth the value and the landed value to the cent.
+// The unit costs are rounded, so when the quantity at them is not worth the values, the last unit
+// gets a layer of its own carrying the difference, its Transaction Log has the differenceNotes.
+func addLayerOfValue(ctx context.Context, tx storage.Tx, layer storage.Price, value decimal.Decimal, landedValue decimal.Decimal, trx storage.Transaction, notes string, differenceNotes string) error {
+	qty := layer.Qty
+	layer.OriginalQty = qty
+	layer.Cost = value.DivRound(decimal.New(int64(qty), 0), storage.UnitCostPlaces)
+	layer.LandedCost = landedValue.DivRound(decimal.New(int64(qty), 0), storage.UnitCostPlaces)
+	if storage.ExtendedValue(qty, layer.Cost).Equal(value) && storage.ExtendedValue(qty, layer.LandedCost).Equal(landedValue) {
+		return addLayer(ctx, tx, layer, trx, notes)
+	}
+
+	// Truncating keeps the rest of the value for the last unit from going below zero
+	layer.Qty, layer.OriginalQty = qty-1, qty-1
+	layer.Cost = value.Div(decimal.New(int64(qty), 0)).Truncate(storage.UnitCostPlaces)
+	layer.LandedCost = landedValue.Div(decimal.New(int64(qty), 0)).Truncate(storage.UnitCostPlaces)
+	if err := addLayer(ctx, tx, layer, trx, notes); err != nil {
+		return err
+	}
+	difference := layer
+	difference.Qty, difference.OriginalQty = 1, 1
+	difference.Cost = value.Sub(storage.ExtendedValue(qty-1, layer.Cost))
+	difference.LandedCost = landedValue.Sub(storage.ExtendedValue(qty-1, layer.LandedCost))
+	return addLayer(ctx, tx, difference, trx, differenceNotes)
+}
+
+func addLayer(ctx context.Context, tx storage.Tx, layer storage.Price, trx storage.Transaction, notes string) error {
+	priceId, err := tx.Prices().Create(ctx, layer)
+	if err != nil {
+		return err
+	}
+	trx.PriceID = priceId
+	trx.Qty = layer.Qty
+	trx.Notes = notes
+	return tx.Transactions().Add(ctx, trx)
+}
+
+type specificCosting struct{}
+
+func (specificCosting) pick(available []storage.Price, qty int, chosen []storage.Layer) ([]storage.Price, error) {
+	if len(chosen) == 0 {
+		return nil, chosenLayersError("is required for the specific identification")
+	}
+
+	picked := []storage.Price{}
+	total := 0
+	for _, layer := range chosen {
+		i := -1
+		for j, price := range available {
+			if price.PriceID == layer.PriceID {
+				i = j
+			}
+		}
+		if i < 0 {
+			return nil, chosenLayersError("unknown cost layer: " + strconv.Itoa(layer.PriceID))
+		}
+		if available[i].Qty < layer.Qty {
+			return nil, insufficientQuantity("layer", layer.Qty, available[i].Qty)
+		}
+		price := available[i]
+		price.Qty = layer.Qty
+		available[i].Qty -= layer.Qty
+		total += layer.Qty
+		picked = append(picked, price)
+	}
+	if total != qty {
+		return nil, chosenLayersError("must add up to the quantity " + strconv.Itoa(qty))
+	}
+	return picked, nil
+}
+
+func (specificCosting) rebalance(ctx context.Context, tx storage.Tx, materialId int, trx storage.Transaction) error {
+	return nil
+}
+
+func chosenLayersError(message string) error {
+	errList := validation.Errors{}
+	errList.Add("layers", message)
+	return errList.Err()
+}
+
+// The method returns the costing method of the Material by the most specific rule:
+// the Owner and the Customer, then the Customer, then the Owner.
+func costingMethodOf(ctx context.Context, tx storage.Tx, owner string, customerId int) (costingMethod, error) {
+	rules, err := tx.Costing().Rules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	method, rank := storage.CostingFIFO, 0
+	for _, rule := range rules {
+		ruleRank := 0
+		switch {
+		case rule.Owner == owner && rule.CustomerID == customerId:
+			ruleRank = 3
+		case rule.Owner == "" && rule.CustomerID == customerId:
+			ruleRank = 2
+		case rule.Owner == owner && rule.CustomerID == 0:
+			ruleRank = 1
+		}
+		if ruleRank > rank {
+			method, rank = rule.Method, ruleRank
+		}
+	}
+	return costingMethods[method], nil
+}
+
+type CostingRuleJSON struct {
+	Owner      string `json:"owner,omitempty"`
+	CustomerID int    `json:"customerId,omitempty"`
+	Method     string `json:"method"`
+}
+
+// CostingMethodsJSON lists the rules, the Materials matching none of them use FIFO
+type CostingMethodsJSON struct {
+	Rules []CostingRuleJSON `json:"rules"`
+}
+
+var costingMethodNames = []string{storage.CostingFIFO, storage.CostingAverage, storage.CostingSpecific}
+
+func ValidateCostingMethods(costing CostingMethodsJSON) error {
+	errs := validation.Errors{}
+	for i, rule := range costing.Rules {
+		field := "rules[" + strconv.Itoa(i) + "]."
+		if rule.Owner == "" && rule.CustomerID == 0 {
+			errs.Add(field+"owner", "owner or customerId is required")
+		}
+		if rule.Owner != "" {
+			errs.OneOf(field+"owner", rule.Owner, owners...)
+		}
+		errs.NonNegative(field+"customerId", rule.CustomerID)
+		errs.OneOf(field+"method", rule.Method, costingMethodNames...)
+	}
+	return errs.Err()
+}
+
+// Customer scoped Users get the rules of their Customers and the ones of an Owner only
+func FetchCostingMethods(ctx context.Context, store storage.Store) (CostingMethodsJSON, error) {
+	rules, err := store.Costing().Rules(ctx)
+	if err != nil {
+		return CostingMethodsJSON{}, err
+	}
+	if scope := users.CustomerScope(ctx); scope != nil {
+		rules = slices.DeleteFunc(rules, func(rule storage.CostingRule) bool {
+			return rule.CustomerID != 0 && !slices.Contains(scope, rule.CustomerID)
+		})
+	}
+	return toCostingMethodsJSON(rules), nil
+}
+
+// The method replaces the costing rules. The stock already costed keeps its layers,
+// a weighted average Material is averaged on its next receipt or move in.
+func SetCostingMethods(ctx context.Context, store storage.Store, costing CostingMethodsJSON) error {
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		before, err := tx.Costing().Rules(ctx)
+		if err != nil {
+			return err
+		}
+
+		rules := make([]storage.CostingRule, 0, len(costing.Rules))
+		for _, rule := range costing.Rules {
+			rules = append(rules, storage.CostingRule(rule))
+		}
+		if err := tx.Costing().SetRules(ctx, rules); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.Change{
+			UserID: users.UserID(ctx),
+			Action: audit.ActionUpdate,
+			Entity: audit.EntityCosting,
+			Before: toCostingMethodsJSON(before),
+			After:  costing,
+		})
+	})
+}
+
+func toCostingMethodsJSON(rules []storage.CostingRule) CostingMethodsJSON {
+	costing := CostingMethodsJSON{Rules: make([]CostingRuleJSON, 0, len(rules))}
+	for _, rule := range rules {
+		costing.Rules = append(costing.Rules, CostingRuleJSON(rule))
+	}
+	return costing
+}
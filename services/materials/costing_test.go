@@ -0,0 +1,95 @@
+package materials
+
+import (
+	"inv_app/storage"
+	"slices"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// A layer consumed by a removal, by the order of its receipt
+type consumedLayer struct {
+	receipt int
+	qty     int
+}
+
+// Receipts at equal and differing costs always give the same layers and cost of goods to a removal
+func TestFIFOCostOfGoods(t *testing.T) {
+	receipts := []struct {
+		qty  int
+		cost string
+	}{
+		{10, "1.00"},
+		{10, "1.00"},
+		{5, "2.50"},
+		{10, "1.00"},
+		{8, "0.3333"},
+	}
+	wantConsumed := []consumedLayer{{0, 10}, {1, 10}, {2, 5}, {3, 2}}
+	wantCogs := decimal.RequireFromString("34.50")
+
+	for run := 0; run < 20; run++ {
+		env := newTestEnv(t)
+		materialId := 0
+		priceIds := []int{}
+		for _, receipt := range receipts {
+			materialId = env.receive(t, env.send(t, "P-100", receipt.qty, receipt.cost), env.locations[0], receipt.qty)
+			layers := env.layers(t, materialId)
+			priceIds = append(priceIds, layers[len(layers)-1].PriceID)
+		}
+
+		if _, err := RemoveMaterial(env.ctx, env.store, MaterialJSON{MaterialID: materialId, Qty: 27}); err != nil {
+			t.Fatal(err)
+		}
+		history, _, err := GetMaterialHistory(env.ctx, env.store, materialId, storage.Page{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		issues, err := env.store.Transactions().ListCorrelated(env.ctx, history[len(history)-1].TransactionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		consumed := []consumedLayer{}
+		for _, issue := range issues {
+			consumed = append(consumed, consumedLayer{receipt: slices.Index(priceIds, issue.PriceID), qty: -issue.Qty})
+		}
+		if !slices.Equal(consumed, wantConsumed) {
+			t.Fatalf("run %d: consumed %v, want %v", run, consumed, wantConsumed)
+		}
+
+		cogs := decimal.Zero
+		for _, record := range history[len(receipts):] {
+			cogs = cogs.Sub(record.Value)
+		}
+		if !cogs.Equal(wantCogs) {
+			t.Fatalf("run %d: cost of goods %s, want %s", run, cogs, wantCogs)
+		}
+		assertLayers(t, env.layers(t, materialId), layer(8, "1.00"), layer(8, "0.3333"))
+	}
+}
+
+// The merged layers keep the value of the emptied ones to the cent, also when the average cost is rounded
+func TestAverageCostKeepsValue(t *testing.T) {
+	env := newTestEnv(t)
+	err := SetCostingMethods(env.ctx, env.store, CostingMethodsJSON{Rules: []CostingRuleJSON{{Owner: "Tag", Method: storage.CostingAverage}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	materialId := env.receive(t, env.send(t, "P-100", 100, "1.00"), env.locations[0], 100)
+	env.receive(t, env.send(t, "P-100", 200, "1.0001"), env.locations[0], 200)
+	assertLayers(t, env.layers(t, materialId), layer(299, "1.0000"), layer(1, "1.02"))
+
+	env.receive(t, env.send(t, "P-100", 100, "2.0198"), env.locations[0], 100)
+	assertLayers(t, env.layers(t, materialId), layer(400, "1.255"))
+
+	history, _, err := GetMaterialHistory(env.ctx, env.store, materialId, storage.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := history[len(history)-1].ValueBalance; !balance.Equal(decimal.RequireFromString("502")) {
+		t.Fatalf("value balance: got %s, want 502.00", balance)
+	}
+}
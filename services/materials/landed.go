@@ -0,0 +1,490 @@
+package materials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"inv_app/services/audit"
+	"inv_app/services/errs"
+	"inv_app/services/users"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Landed costs: freight, duties and other charges of Incoming Materials are allocated to the shipments
+// by their quantity, value or weight. The share of a shipment is added per unit to its landed cost,
+// so the cost layers of the receipts include it and the reports value the stock with it.
+// A charge is allocated to the whole quantity of the shipment. The posted Transaction Logs keep their cost:
+// the received stock still on hand, followed through the moves and the weighted average, is revalued
+// by adjustment Transaction Logs and the share of the received units already consumed is the variance.
+
+var allocationMethods = []string{storage.AllocationQuantity, storage.AllocationValue, storage.AllocationWeight}
+
+type LandedChargeJSON struct {
+	ChargeID    int             `json:"chargeId,omitempty"`
+	Description string          `json:"description"`
+	Amount      decimal.Decimal `json:"amount"`
+	// ISO 4217 code of the amount, the base currency if empty
+	Currency string `json:"currency"`
+	// The basis of the allocation: quantity, value or weight
+	Method string `json:"method"`
+	// The date of the exchange rates, today if empty
+	ChargeDate  string                 `json:"chargeDate"`
+	ShippingIDs []int                  `json:"shippingIds"`
+	UserID      int                    `json:"userId,omitempty"`
+	Username    string                 `json:"username,omitempty"`
+	CreatedAt   *time.Time             `json:"createdAt,omitempty"`
+	Allocations []LandedAllocationJSON `json:"allocations,omitempty"`
+}
+
+// LandedAllocationJSON is the share of the charge of one shipment in the currency of the shipment
+type LandedAllocationJSON struct {
+	ShippingID int             `json:"shippingId"`
+	StockID    string          `json:"stockId"`
+	Basis      decimal.Decimal `json:"basis"`
+	Amount     decimal.Decimal `json:"amount"`
+	Currency   string          `json:"currency"`
+	Qty        int             `json:"quantity"`
+	UnitCost   decimal.Decimal `json:"unitCost"`
+	// The share of the received units already consumed, not added to the stock
+	Variance decimal.Decimal `json:"variance"`
+}
+
+func ValidateLandedCharge(charge LandedChargeJSON) error {
+	errList := validation.Errors{}
+	errList.Required("description", charge.Description)
+	if !charge.Amount.IsPositive() {
+		errList.Add("amount", "must be greater than 0")
+	} else {
+		errList.NonNegativeMoney("amount", charge.Amount, storage.ValuePlaces)
+	}
+	if charge.Currency != "" {
+		errList.Currency("currency", charge.Currency)
+	}
+	errList.OneOf("method", charge.Method, allocationMethods...)
+	errList.Date("chargeDate", charge.ChargeDate)
+	if len(charge.ShippingIDs) == 0 {
+		errList.Add("shippingIds", "is required")
+	}
+	for i, shippingId := range charge.ShippingIDs {
+		field := "shippingIds[" + strconv.Itoa(i) + "]"
+		errList.ID(field, shippingId)
+		if slices.Index(charge.ShippingIDs, shippingId) < i {
+			errList.Add(field, "must not be repeated")
+		}
+	}
+	return errList.Err()
+}
+
+// The method allocates the charge to the Incoming Materials and adds the shares per unit to their landed cost.
+// Method's Context: Landed Cost Allocation. All changes are made in one Transaction committed only if no error occurs.
+// Costs in other currencies are converted with the exchange rates effective on the charge date.
+func AllocateLandedCharge(ctx context.Context, store storage.Store, charge LandedChargeJSON) (LandedChargeJSON, error) {
+	charge.Currency = incomingCurrency(charge.Currency)
+	if charge.ChargeDate == "" {
+		charge.ChargeDate = time.Now().Format(time.DateOnly)
+	}
+
+	err := storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		// The received stock is revalued, so the chain is locked first, see LockChain
+		if err := tx.Transactions().LockChain(ctx); err != nil {
+			return err
+		}
+		shipments := make([]shipment, 0, len(charge.ShippingIDs))
+		for _, shippingId := range charge.ShippingIDs {
+			incoming, err := tx.Incoming().GetForUpdate(ctx, shippingId)
+			if err != nil {
+				return fmt.Errorf("Incoming Material %d: %w", shippingId, err)
+			}
+			receipts, err := tx.Transactions().ListReceipts(ctx, shippingId)
+			if err != nil {
+				return err
+			}
+			shipments = append(shipments, shipment{incoming: incoming, receipts: receipts})
+		}
+
+		allocations, err := allocateCharge(ctx, tx, charge, shipments)
+		if err != nil {
+			return err
+		}
+		charge.UserID = users.UserID(ctx)
+		trx := storage.Transaction{
+			UserID:        charge.UserID,
+			Type:          storage.TransactionAdjustment,
+			CorrelationID: storage.NewCorrelationID(),
+			UpdatedAt:     time.Now(),
+		}
+		for i := range allocations {
+			if err := landShipment(ctx, tx, &allocations[i], shipments[i], trx); err != nil {
+				return err
+			}
+		}
+
+		charge.ChargeID, err = tx.LandedCosts().Create(ctx, storage.LandedCharge{
+			Description: charge.Description,
+			Amount:      charge.Amount,
+			Currency:    charge.Currency,
+			Method:      charge.Method,
+			ChargeDate:  charge.ChargeDate,
+			UserID:      charge.UserID,
+			Allocations: allocations,
+		})
+		if err != nil {
+			return err
+		}
+		charge.Allocations = toLandedAllocationsJSON(allocations)
+
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   charge.UserID,
+			Action:   audit.ActionCreate,
+			Entity:   audit.EntityLandedCharge,
+			EntityID: charge.ChargeID,
+			After:    charge,
+		})
+	})
+	if err != nil {
+		return LandedChargeJSON{}, err
+	}
+	return charge, nil
+}
+
+// A shipment with the receipts of its quantity already received
+type shipment struct {
+	incoming storage.IncomingMaterialDB
+	receipts []storage.Transaction
+}
+
+// Returns the quantity still incoming and the received one
+func (s shipment) qty() int {
+	qty := s.incoming.Quantity
+	for _, receipt := range s.receipts {
+		qty += receipt.Qty
+	}
+	return qty
+}
+
+// The method splits the amount by the bases of the shipments rounded to cents, the rounding difference
+// goes to the shipment with the largest basis. Each share is converted to the currency of its shipment.
+func allocateCharge(ctx context.Context, tx storage.Tx, charge LandedChargeJSON, shipments []shipment) ([]storage.LandedAllocation, error) {
+	allocations := make([]storage.LandedAllocation, 0, len(shipments))
+	total, largest := decimal.Decimal{}, 0
+	for i, s := range shipments {
+		incoming, qty := s.incoming, s.qty()
+		if qty <= 0 {
+			return nil, errs.New(errs.ErrConflict,
+				fmt.Sprintf("The Incoming Material %s has no quantity to allocate the charge to", incoming.ShippingID),
+				map[string]string{"shippingId": incoming.ShippingID},
+			)
+		}
+
+		var basis decimal.Decimal
+		switch charge.Method {
+		case storage.AllocationQuantity:
+			basis = decimal.New(int64(qty), 0)
+		case storage.AllocationWeight:
+			basis = incoming.UnitWeight.Mul(decimal.New(int64(qty), 0))
+		case storage.AllocationValue:
+			value := storage.ExtendedValue(qty, incoming.Cost)
+			var err error
+			if basis, err = convertMoney(ctx, tx, value, incoming.Currency, charge.Currency, charge.ChargeDate); err != nil {
+				return nil, err
+			}
+		}
+		basis = basis.Round(storage.UnitCostPlaces)
+
+		shippingId, _ := strconv.Atoi(incoming.ShippingID)
+		allocations = append(allocations, storage.LandedAllocation{
+			ShippingID: shippingId,
+			StockID:    incoming.StockID,
+			Basis:      basis,
+			Currency:   incoming.Currency,
+			Qty:        qty,
+		})
+		total = total.Add(basis)
+		if basis.GreaterThan(allocations[largest].Basis) {
+			largest = i
+		}
+	}
+	if !total.IsPositive() {
+		errList := validation.Errors{}
+		errList.Add("shippingIds", "have no "+charge.Method+" to allocate the charge by")
+		return nil, errList.Err()
+	}
+
+	shares := make([]decimal.Decimal, len(allocations))
+	remainder := charge.Amount
+	for i, allocation := range allocations {
+		if i == largest {
+			continue
+		}
+		shares[i] = charge.Amount.Mul(allocation.Basis).DivRound(total, storage.ValuePlaces)
+		remainder = remainder.Sub(shares[i])
+	}
+	shares[largest] = remainder
+
+	for i := range allocations {
+		amount, err := convertMoney(ctx, tx, shares[i], charge.Currency, allocations[i].Currency, charge.ChargeDate)
+		if err != nil {
+			return nil, err
+		}
+		allocations[i].Amount = amount
+		allocations[i].UnitCost = amount.DivRound(decimal.New(int64(allocations[i].Qty), 0), storage.UnitCostPlaces)
+	}
+	return allocations, nil
+}
+
+// The method adds the share of the allocation of the quantity still incoming to its landed cost and value
+// and revalues the received stock on hand by its share. The share of the received units no longer on hand
+// is the variance. The shares are rounded to cents, the rounding difference goes to the last revalued layer,
+// or to the quantity still incoming if there is none, so they add up to the amount of the allocation.
+// trx is the template of the adjustment Transaction Logs.
+func landShipment(ctx context.Context, tx storage.Tx, allocation *storage.LandedAllocation, s shipment, trx storage.Transaction) error {
+	shares, err := receivedShares(ctx, tx, s.receipts)
+	if err != nil {
+		return err
+	}
+	priceIds := make([]int, 0, len(shares))
+	for priceId := range shares {
+		priceIds = append(priceIds, priceId)
+	}
+	slices.Sort(priceIds)
+
+	onHand, held := []storage.Price{}, []decimal.Decimal{}
+	heldQty := decimal.Decimal{}
+	for _, priceId := range priceIds {
+		layer, err := tx.Prices().GetForUpdate(ctx, priceId)
+		if err != nil {
+			return err
+		}
+		if layer.Qty == 0 {
+			continue
+		}
+		qty := shares[priceId].Mul(decimal.New(int64(layer.Qty), 0))
+		onHand, held = append(onHand, layer), append(held, qty)
+		heldQty = heldQty.Add(qty)
+	}
+
+	qty := decimal.New(int64(allocation.Qty), 0)
+	received := decimal.New(int64(allocation.Qty-s.incoming.Quantity), 0)
+	allocation.Variance = allocation.Amount.Mul(received.Sub(heldQty)).DivRound(qty, storage.ValuePlaces)
+	incomingValue := allocation.Amount.Mul(decimal.New(int64(s.incoming.Quantity), 0)).DivRound(qty, storage.ValuePlaces)
+	rest := allocation.Amount.Sub(incomingValue).Sub(allocation.Variance)
+	if len(onHand) == 0 {
+		if s.incoming.Quantity > 0 {
+			incomingValue = incomingValue.Add(rest)
+		} else {
+			allocation.Variance = allocation.Variance.Add(rest)
+		}
+	}
+	if err := tx.Incoming().AddLandedCost(ctx, allocation.ShippingID, allocation.UnitCost, incomingValue); err != nil {
+		return err
+	}
+
+	for i, layer := range onHand {
+		value := rest
+		if i < len(onHand)-1 {
+			value = allocation.Amount.Mul(held[i]).DivRound(qty, storage.ValuePlaces)
+			rest = rest.Sub(value)
+		}
+		if err := revalueLayer(ctx, tx, layer, value, trx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The method returns the share of the units of each layer the receipts account for, by the Price ID:
+// the layers of the receipts, the layers the moves took their stock to and the layers it was merged
+// into by the weighted average or revalued to by a landed charge.
+func receivedShares(ctx context.Context, tx storage.Tx, receipts []storage.Transaction) (map[int]decimal.Decimal, error) {
+	shares := map[int]decimal.Decimal{}
+	pending := []int{}
+	add := func(priceId int, share decimal.Decimal) {
+		if _, ok := shares[priceId]; !ok {
+			pending = append(pending, priceId)
+		}
+		shares[priceId] = shares[priceId].Add(share)
+	}
+	for _, receipt := range receipts {
+		add(receipt.PriceID, decimal.New(1, 0))
+	}
+
+	// The stock of a layer goes to layers created after it, so the share of the lowest pending Price ID is complete
+	for len(pending) > 0 {
+		slices.Sort(pending)
+		priceId := pending[0]
+		pending = pending[1:]
+
+		trxList, err := tx.Transactions().ListByPrice(ctx, priceId)
+		if err != nil {
+			return nil, err
+		}
+		for _, trx := range trxList {
+			if trx.Qty >= 0 || (trx.Type != storage.TransactionMoveOut && trx.Type != storage.TransactionAdjustment) {
+				continue
+			}
+			correlated, err := tx.Transactions().ListCorrelated(ctx, trx.TransactionID)
+			if err != nil {
+				return nil, err
+			}
+
+			if trx.Type == storage.TransactionMoveOut {
+				// The halves of a move are added in the same order, the k-th move out is the k-th move in
+				outs, ins := []int{}, []int{}
+				for _, row := range correlated {
+					switch row.Type {
+					case storage.TransactionMoveOut:
+						outs = append(outs, row.TransactionID)
+					case storage.TransactionMoveIn:
+						ins = append(ins, row.PriceID)
+					}
+				}
+				if k := slices.Index(outs, trx.TransactionID); k >= 0 && k < len(ins) {
+					add(ins[k], shares[priceId])
+				}
+				continue
+			}
+
+			// The layers added by an adjustment follow the emptied ones, see addLayerOfValue
+			i := slices.IndexFunc(correlated, func(row storage.Transaction) bool { return row.TransactionID == trx.TransactionID })
+			for i < len(correlated) && correlated[i].Qty < 0 {
+				i++
+			}
+			added, qty := []storage.Transaction{}, 0
+			for ; i < len(correlated) && correlated[i].Qty > 0; i++ {
+				added = append(added, correlated[i])
+				qty += correlated[i].Qty
+			}
+			if qty == 0 {
+				continue
+			}
+			share := shares[priceId].Mul(decimal.New(int64(-trx.Qty), 0)).Div(decimal.New(int64(qty), 0))
+			for _, row := range added {
+				add(row.PriceID, share)
+			}
+		}
+	}
+	return shares, nil
+}
+
+// The method empties the layer by an adjustment and adds its stock back in a layer worth the value more,
+// with the same receipt date. The landed cost goes up by the same value.
+func revalueLayer(ctx context.Context, tx storage.Tx, layer storage.Price, value decimal.Decimal, trx storage.Transaction) error {
+	if value.IsZero() {
+		return nil
+	}
+	material, err := tx.Materials().Get(ctx, layer.MaterialID)
+	if err != nil {
+		return err
+	}
+	trx.ToLocationID = material.LocationID
+
+	if _, err := tx.Prices().AddQuantity(ctx, layer.PriceID, -layer.Qty); err != nil {
+		return err
+	}
+	outTrx := trx
+	outTrx.PriceID = layer.PriceID
+	outTrx.Qty = -layer.Qty
+	outTrx.Notes = "Revalued by a landed charge"
+	if err := tx.Transactions().Add(ctx, outTrx); err != nil {
+		return err
+	}
+
+	revalued := storage.Price{
+		MaterialID: layer.MaterialID,
+		Qty:        layer.Qty,
+		Currency:   layer.Currency,
+		ReceivedAt: layer.ReceivedAt,
+	}
+	return addLayerOfValue(ctx, tx, revalued,
+		storage.ExtendedValue(layer.Qty, layer.Cost).Add(value),
+		storage.ExtendedValue(layer.Qty, layer.LandedCost).Add(value),
+		trx, "Landed cost", "Landed cost rounding difference")
+}
+
+// The method converts the amount between the currencies through the base one and rounds it to cents.
+// It returns a validation error if a currency has no exchange rate effective on the date.
+func convertMoney(ctx context.Context, tx storage.Tx, amount decimal.Decimal, from string, to string, date string) (decimal.Decimal, error) {
+	if from == to {
+		return amount, nil
+	}
+	fromRate, err := baseRate(ctx, tx, from, date)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	toRate, err := baseRate(ctx, tx, to, date)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	return amount.Mul(fromRate).DivRound(toRate, storage.ValuePlaces), nil
+}
+
+// Returns the value of one unit of the currency in the base one
+func baseRate(ctx context.Context, tx storage.Tx, currency string, date string) (decimal.Decimal, error) {
+	if currency == storage.BaseCurrency() {
+		return decimal.New(1, 0), nil
+	}
+	rate, err := tx.ExchangeRates().Effective(ctx, currency, date)
+	if errors.Is(err, storage.ErrNotFound) {
+		errList := validation.Errors{}
+		errList.Add("chargeDate", "no exchange rate of "+currency+" effective on "+date)
+		return decimal.Decimal{}, errList.Err()
+	}
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	return rate.Rate, nil
+}
+
+// The method returns the Page of the charges, the latest first, only the ones of the shipment if shippingId is not 0.
+func FetchLandedCharges(ctx context.Context, store storage.Store, shippingId int, page storage.Page) ([]LandedChargeJSON, int, error) {
+	charges, total, err := store.LandedCosts().List(ctx, shippingId, page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	chargesJSON := make([]LandedChargeJSON, 0, len(charges))
+	for _, charge := range charges {
+		shippingIds := make([]int, 0, len(charge.Allocations))
+		for _, allocation := range charge.Allocations {
+			shippingIds = append(shippingIds, allocation.ShippingID)
+		}
+		createdAt := charge.CreatedAt
+		chargesJSON = append(chargesJSON, LandedChargeJSON{
+			ChargeID:    charge.ChargeID,
+			Description: charge.Description,
+			Amount:      charge.Amount,
+			Currency:    charge.Currency,
+			Method:      charge.Method,
+			ChargeDate:  charge.ChargeDate,
+			ShippingIDs: shippingIds,
+			UserID:      charge.UserID,
+			Username:    charge.Username,
+			CreatedAt:   &createdAt,
+			Allocations: toLandedAllocationsJSON(charge.Allocations),
+		})
+	}
+	return chargesJSON, total, nil
+}
+
+func toLandedAllocationsJSON(allocations []storage.LandedAllocation) []LandedAllocationJSON {
+	allocationsJSON := make([]LandedAllocationJSON, 0, len(allocations))
+	for _, allocation := range allocations {
+		allocationsJSON = append(allocationsJSON, LandedAllocationJSON{
+			ShippingID: allocation.ShippingID,
+			StockID:    allocation.StockID,
+			Basis:      allocation.Basis,
+			Amount:     allocation.Amount,
+			Currency:   allocation.Currency,
+			Qty:        allocation.Qty,
+			UnitCost:   allocation.UnitCost,
+			Variance:   allocation.Variance,
+		})
+	}
+	return allocationsJSON
+}
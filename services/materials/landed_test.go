@@ -0,0 +1,210 @@
+package materials
+
+import (
+	"errors"
+	"inv_app/services/errs"
+	"inv_app/storage"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func allocate(t *testing.T, env testEnv, amount string, shippingId int) LandedChargeJSON {
+	t.Helper()
+	charge, err := AllocateLandedCharge(env.ctx, env.store, LandedChargeJSON{
+		Description: "Freight",
+		Amount:      decimal.RequireFromString(amount),
+		Method:      storage.AllocationQuantity,
+		ShippingIDs: []int{shippingId},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return charge
+}
+
+// A charge is spread over the whole shipment, the received share goes to the layers of its receipts and where they were moved
+func TestAllocateLandedChargeToReceivedLayers(t *testing.T) {
+	env := newTestEnv(t)
+	shippingId := env.send(t, "P-100", 10, "1.00")
+	materialId := env.receive(t, shippingId, env.locations[0], 4)
+	if _, err := MoveMaterial(env.ctx, env.store, MaterialJSON{MaterialID: materialId, LocationID: env.locations[1], Qty: 2}); err != nil {
+		t.Fatal(err)
+	}
+	movedId, err := env.store.Materials().FindAtLocation(env.ctx, "P-100", env.locations[1], "Tag")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	charge := allocate(t, env, "20.00", shippingId)
+	if allocation := charge.Allocations[0]; allocation.Qty != 10 || !allocation.UnitCost.Equal(decimal.RequireFromString("2")) {
+		t.Fatalf("got %d at %s, want 10 at 2.00", allocation.Qty, allocation.UnitCost)
+	}
+	assertLayers(t, env.layers(t, materialId), layer(2, "3.00"))
+	assertLayers(t, env.layers(t, movedId), layer(2, "3.00"))
+	if variance := charge.Allocations[0].Variance; !variance.IsZero() {
+		t.Fatalf("variance: got %s, want 0", variance)
+	}
+
+	// The receipt keeps its cost, the stock on hand is revalued from now on
+	history, _, err := GetMaterialHistory(env.ctx, env.store, materialId, storage.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt := history[0]; receipt.Qty != 4 || !receipt.UnitCost.Equal(decimal.RequireFromString("1")) {
+		t.Fatalf("receipt: got %d at %s, want 4 at 1.00", receipt.Qty, receipt.UnitCost)
+	}
+	if balance := history[len(history)-1].ValueBalance; !balance.Equal(decimal.RequireFromString("6")) {
+		t.Fatalf("value balance: got %s, want 6.00", balance)
+	}
+
+	// The rest is received at the landed cost, the received shipment is no longer listed but takes charges
+	env.receive(t, shippingId, env.locations[0], 6)
+	assertLayers(t, env.layers(t, materialId), layer(2, "3.00"), layer(6, "3.00"))
+	if _, total, err := GetIncomingMaterials(env.ctx, env.store, shippingId, storage.Page{}); err != nil || total != 0 {
+		t.Fatalf("got %d Incoming Materials (%v), want none", total, err)
+	}
+	if _, err := CreateMaterial(env.ctx, env.store, MaterialJSON{MaterialID: shippingId, LocationID: env.locations[0], Qty: 1}); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("got %v, want not found", err)
+	}
+
+	allocate(t, env, "8.00", shippingId)
+	assertLayers(t, env.layers(t, materialId), layer(2, "3.80"), layer(6, "3.80"))
+	assertLayers(t, env.layers(t, movedId), layer(2, "3.80"))
+	for _, layer := range env.layers(t, materialId) {
+		if !layer.LandedCost.Equal(decimal.RequireFromString("2.80")) {
+			t.Errorf("landed cost: got %s, want 2.80", layer.LandedCost)
+		}
+	}
+}
+
+// The share of the units already issued is the variance, the issue keeps the cost it was posted at
+func TestAllocateLandedChargeToConsumedUnits(t *testing.T) {
+	env := newTestEnv(t)
+	shippingId := env.send(t, "P-100", 10, "1.00")
+	materialId := env.receive(t, shippingId, env.locations[0], 10)
+	if _, err := RemoveMaterial(env.ctx, env.store, MaterialJSON{MaterialID: materialId, Qty: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	charge := allocate(t, env, "20.00", shippingId)
+	if variance := charge.Allocations[0].Variance; !variance.Equal(decimal.RequireFromString("10")) {
+		t.Fatalf("variance: got %s, want 10.00", variance)
+	}
+	assertLayers(t, env.layers(t, materialId), layer(5, "3.00"))
+
+	history, _, err := GetMaterialHistory(env.ctx, env.store, materialId, storage.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		qty   int
+		value string
+	}{{10, "10.00"}, {-5, "-5.00"}, {-5, "-5.00"}, {5, "15.00"}}
+	if len(history) != len(want) {
+		t.Fatalf("got %d Transaction Logs, want %d", len(history), len(want))
+	}
+	for i, record := range history {
+		if record.Qty != want[i].qty || !record.Value.Equal(decimal.RequireFromString(want[i].value)) {
+			t.Errorf("row %d: got %d worth %s, want %d worth %s", i, record.Qty, record.Value, want[i].qty, want[i].value)
+		}
+	}
+	if balance := history[len(history)-1].ValueBalance; !balance.Equal(decimal.RequireFromString("15")) {
+		t.Fatalf("value balance: got %s, want 15.00", balance)
+	}
+}
+
+// The landed values of the layers and the variance add up to the amount exactly, whatever the rounding of the unit cost
+func TestAllocatedLandedValuesAddUpToAmount(t *testing.T) {
+	tests := []struct {
+		name   string
+		before []int
+		issued int
+		after  []int
+	}{
+		{"before the receipts", nil, 0, []int{1, 1, 1}},
+		{"between the receipts", []int{1}, 0, []int{2}},
+		{"after an issue", []int{1}, 1, []int{1, 1}},
+		{"after the receipts", []int{2, 1}, 0, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			shippingId := env.send(t, "P-100", 3, "1.00")
+			materialId := 0
+			for _, qty := range test.before {
+				materialId = env.receive(t, shippingId, env.locations[0], qty)
+			}
+			if test.issued > 0 {
+				if _, err := RemoveMaterial(env.ctx, env.store, MaterialJSON{MaterialID: materialId, Qty: test.issued}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			charge := allocate(t, env, "10.00", shippingId)
+			for _, qty := range test.after {
+				materialId = env.receive(t, shippingId, env.locations[0], qty)
+			}
+
+			total := charge.Allocations[0].Variance
+			for _, layer := range env.layers(t, materialId) {
+				total = total.Add(storage.ExtendedValue(layer.Qty, layer.LandedCost))
+			}
+			if !total.Equal(decimal.RequireFromString("10")) {
+				t.Fatalf("landed values and variance: got %s, want 10.00", total)
+			}
+		})
+	}
+}
+
+// The share of the stock merged into the weighted average layer is spread over its quantity
+func TestAllocateLandedChargeToAverageLayer(t *testing.T) {
+	env := newTestEnv(t)
+	err := SetCostingMethods(env.ctx, env.store, CostingMethodsJSON{Rules: []CostingRuleJSON{{Owner: "Tag", Method: storage.CostingAverage}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shippingId := env.send(t, "P-100", 10, "1.00")
+	materialId := env.receive(t, shippingId, env.locations[0], 10)
+	env.receive(t, env.send(t, "P-100", 10, "3.00"), env.locations[0], 10)
+	assertLayers(t, env.layers(t, materialId), layer(20, "2.00"))
+
+	allocate(t, env, "10.00", shippingId)
+	assertLayers(t, env.layers(t, materialId), layer(20, "2.50"))
+}
+
+// The quantity of a shipment with landed charges is kept, the rest of it can still be changed
+func TestUpdateIncomingMaterialWithLandedCharges(t *testing.T) {
+	env := newTestEnv(t)
+	shippingId := env.send(t, "P-100", 10, "1.00")
+	allocate(t, env, "20.00", shippingId)
+
+	incoming, err := env.store.Incoming().Get(env.ctx, shippingId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	material := toIncomingJSON(incoming)
+	material.Qty = 12
+	if err := UpdateIncomingMaterial(env.ctx, env.store, material); !errors.Is(err, errs.ErrConflict) {
+		t.Fatalf("got %v, want a conflict", err)
+	}
+
+	material.Qty = 10
+	material.Description = "Paper"
+	if err := UpdateIncomingMaterial(env.ctx, env.store, material); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Receiving more than the incoming quantity would add the landed charges to the extra units
+func TestReceiveMoreThanIncomingWithLandedCharges(t *testing.T) {
+	env := newTestEnv(t)
+	shippingId := env.send(t, "P-100", 10, "1.00")
+	allocate(t, env, "20.00", shippingId)
+
+	_, err := CreateMaterial(env.ctx, env.store, MaterialJSON{MaterialID: shippingId, LocationID: env.locations[0], Qty: 11})
+	if !errors.Is(err, errs.ErrConflict) {
+		t.Fatalf("got %v, want a conflict", err)
+	}
+	materialId := env.receive(t, shippingId, env.locations[0], 10)
+	assertLayers(t, env.layers(t, materialId), layer(10, "3.00"))
+}
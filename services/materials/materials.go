@@ -2,779 +2,689 @@ package materials
 
 import (
 	"context"
-	"database/sql"
-	"errors"
 	"fmt"
+	"inv_app/services/audit"
+	"inv_app/services/errs"
+	"inv_app/services/users"
+	"inv_app/storage"
+	"slices"
 	"strconv"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
-func FetchMaterialTypes(db *sql.DB) ([]string, error) {
-	rows, err := db.Query(`
-		SELECT enumlabel FROM pg_enum pe
-		LEFT JOIN pg_type pt ON pt.oid = pe.enumtypid
-		WHERE pt.typname = 'material_type';
-	`)
-	if err != nil {
-		return []string{}, err
-	}
+func FetchMaterialTypes(ctx context.Context, store storage.Store) ([]string, error) {
+	return store.Materials().Types(ctx)
+}
 
-	var materialTypes []string
-	for rows.Next() {
-		var materialType string
-		if err := rows.Scan(&materialType); err != nil {
-			return nil, fmt.Errorf("Error scanning row: %w", err)
+func SendMaterial(ctx context.Context, store storage.Store, material IncomingMaterialJSON) error {
+	material.Currency = incomingCurrency(material.Currency)
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		shippingId, err := tx.Incoming().Create(ctx, storage.IncomingMaterialDB{
+			CustomerID:   material.CustomerID,
+			StockID:      material.StockID,
+			Cost:         material.Cost,
+			Currency:     material.Currency,
+			UnitWeight:   material.UnitWeight,
+			Quantity:     material.Qty,
+			MinQty:       material.MinQty,
+			MaxQty:       material.MaxQty,
+			Description:  material.Description,
+			IsActive:     material.IsActive,
+			MaterialType: material.MaterialType,
+			Owner:        material.Owner,
+			UserID:       material.UserID,
+		})
+		if err != nil {
+			return err
 		}
-		materialTypes = append(materialTypes, materialType)
-	}
+		material.ShippingId = shippingId
+
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   users.UserID(ctx),
+			Action:   audit.ActionCreate,
+			Entity:   audit.EntityIncoming,
+			EntityID: shippingId,
+			After:    material,
+		})
+	})
+}
 
-	return materialTypes, nil
+func GetIncomingMaterials(ctx context.Context, store storage.Store, materialId int, page storage.Page) ([]storage.IncomingMaterialDB, int, error) {
+	filter := storage.IncomingFilter{ShippingID: materialId, CustomerIDs: users.CustomerScope(ctx)}
+	return store.Incoming().List(ctx, filter, page)
 }
 
-func SendMaterial(material IncomingMaterialJSON, db *sql.DB) error {
-	qty, _ := strconv.Atoi(material.Qty)
-	minQty, _ := strconv.Atoi(material.MinQty)
-	maxQty, _ := strconv.Atoi(material.MaxQty)
-
-	_, err := db.Query(`
-				INSERT INTO incoming_materials
-					(customer_id, stock_id, cost, quantity,
-					max_required_quantity, min_required_quantity,
-					description, is_active, type, owner, user_id)
-				VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)`,
-		material.CustomerID, material.StockID, material.Cost,
-		qty, maxQty, minQty,
-		material.Description, material.IsActive, material.MaterialType,
-		material.Owner,
-		material.UserID,
-	)
+func GetMaterials(ctx context.Context, store storage.Store, opts *storage.MaterialFilter, page storage.Page) ([]storage.MaterialDB, int, error) {
+	filter := *opts
+	filter.CustomerIDs = users.CustomerScope(ctx)
+	return store.Materials().List(ctx, filter, page)
+}
 
+// The method returns the Page of the Transaction Logs of the Material with running balances.
+// Materials of other Customers are not found for customer scoped Users.
+func GetMaterialHistory(ctx context.Context, store storage.Store, materialId int, page storage.Page) ([]HistoryJSON, int, error) {
+	material, err := store.Materials().Get(ctx, materialId)
 	if err != nil {
-		return err
+		return nil, 0, err
+	}
+	if scope := users.CustomerScope(ctx); scope != nil && !slices.Contains(scope, material.CustomerID) {
+		return nil, 0, storage.ErrNotFound
 	}
-	return nil
-}
 
-func GetIncomingMaterials(db *sql.DB, materialId int) ([]IncomingMaterialDB, error) {
-	rows, err := db.Query(`
-		SELECT shipping_id, c.name, c.customer_id, stock_id, cost, quantity,
-		min_required_quantity, max_required_quantity, description, is_active, type, owner,
-		u.user_id, u.username
-		FROM incoming_materials im
-		LEFT JOIN customers c ON c.customer_id = im.customer_id
-		LEFT JOIN users u ON u.user_id = im.user_id
-		WHERE $1 = 0 OR im.shipping_id = $1;
-		`, materialId)
+	records, total, err := store.Transactions().History(ctx, materialId, page)
 	if err != nil {
-		return nil, fmt.Errorf("Error querying incoming materials: %w", err)
-	}
-	defer rows.Close()
-
-	var materials []IncomingMaterialDB
-	for rows.Next() {
-		var material IncomingMaterialDB
-		if err := rows.Scan(
-			&material.ShippingID,
-			&material.CustomerName,
-			&material.CustomerID,
-			&material.StockID,
-			&material.Cost,
-			&material.Quantity,
-			&material.MinQty,
-			&material.MaxQty,
-			&material.Description,
-			&material.IsActive,
-			&material.MaterialType,
-			&material.Owner,
-			&material.UserID,
-			&material.UserName,
-		); err != nil {
-			return nil, fmt.Errorf("Error scanning row: %w", err)
-		}
-		materials = append(materials, material)
-	}
-	return materials, nil
+		return nil, 0, err
+	}
+
+	history := make([]HistoryJSON, 0, len(records))
+	for _, record := range records {
+		history = append(history, HistoryJSON{
+			TransactionID:     record.TransactionID,
+			Type:              record.Type,
+			Qty:               record.Qty,
+			UnitCost:          record.UnitCost,
+			Value:             record.Value,
+			LandedCost:        record.LandedCost,
+			Currency:          record.Currency,
+			QtyBalance:        record.QtyBalance,
+			ValueBalance:      record.ValueBalance,
+			Notes:             record.Notes,
+			JobTicket:         record.JobTicket,
+			SerialNumberRange: record.SerialNumberRange,
+			Date:              record.UpdatedAt,
+			UserID:            record.UserID,
+			Username:          record.Username,
+			ApprovedBy:        record.ApprovedBy,
+			FromLocation:      record.FromLocation,
+			ToLocation:        record.ToLocation,
+			ShippingID:        record.ShippingID,
+			RequestID:         record.RequestID,
+			CorrelationID:     record.CorrelationID,
+		})
+	}
+	return history, total, nil
 }
 
-func GetMaterials(db *sql.DB, opts *MaterialFilter) ([]MaterialDB, error) {
-	rows, err := db.Query(`
-		SELECT material_id,
-		COALESCE(w.name,'None') as "warehouse_name",
-		c.name as "customer_name", c.customer_id,
-		COALESCE(l.location_id, 0) as "location_id",
-		COALESCE(l.name, 'None') as "location_name",
-		stock_id, quantity, min_required_quantity, max_required_quantity,
-		m.description, COALESCE(notes,'None') as "notes",
-		is_active, material_type, owner,
-		COALESCE(is_primary, false),
-		COALESCE(serial_number_range, '')
-		FROM materials m
-		LEFT JOIN customers c ON c.customer_id = m.customer_id
-		LEFT JOIN locations l ON l.location_id = m.location_id
-		LEFT JOIN warehouses w ON w.warehouse_id = l.warehouse_id
-		WHERE
-			($1 = 0 OR m.material_id = $1) AND
-			($2 = '' OR m.stock_id ILIKE '%' || $2 || '%') AND
-			($3 = '' OR c.name ILIKE '%' || $3 || '%') AND
-			($4 = '' OR m.description ILIKE '%' || $4 || '%') AND
-			($5 = '' OR l.name ILIKE '%' || $5 || '%')
-		ORDER BY m.is_primary DESC NULLS LAST, m.stock_id ASC;
-		`,
-		opts.MaterialId,
-		opts.StockId,
-		opts.CustomerName,
-		opts.Description,
-		opts.LocationName,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("Error querying incoming materials: %w", err)
-	}
-	defer rows.Close()
-
-	var materials []MaterialDB
-	for rows.Next() {
-		var material MaterialDB
-		if err := rows.Scan(
-			&material.MaterialID,
-			&material.WarehouseName,
-			&material.CustomerName,
-			&material.CustomerID,
-			&material.LocationID,
-			&material.LocationName,
-			&material.StockID,
-			&material.Quantity,
-			&material.MinQty,
-			&material.MaxQty,
-			&material.Description,
-			&material.Notes,
-			&material.IsActive,
-			&material.MaterialType,
-			&material.Owner,
-			&material.IsPrimary,
-			&material.SerialNumberRange,
-		); err != nil {
-			return nil, fmt.Errorf("Error scanning row: %w", err)
+// The method creates/updates a Material, its Prices, adds a Transaction Log, and deletes the Material from Incoming.
+// Method's Context: Material Creation. All changes are made in one Transaction committed only if no error occurs.
+func CreateMaterial(ctx context.Context, store storage.Store, material MaterialJSON) (int, error) {
+	var materialId int
+	err := storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		var err error
+		materialId, err = createMaterial(ctx, tx, material)
+		if err != nil {
+			return err
 		}
-		materials = append(materials, material)
+
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   users.UserID(ctx),
+			Action:   audit.ActionReceive,
+			Entity:   audit.EntityMaterial,
+			EntityID: materialId,
+			After: map[string]any{
+				"shippingId": material.MaterialID,
+				"locationId": material.LocationID,
+				"quantity":   material.Qty,
+				"notes":      material.Notes,
+			},
+		})
+	})
+	if err != nil {
+		return 0, err
 	}
-	return materials, nil
+	return materialId, nil
 }
 
-// The method creates/updates a Material, its Prices, adds a Transaction Log, and deletes the Material from Incoming.
-// Method's Context: Material Creation. The Transaction Rollback is executed once an error occurs.
-func CreateMaterial(ctx context.Context, db *sql.DB, material MaterialJSON) (int, error) {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
+func createMaterial(ctx context.Context, tx storage.Tx, material MaterialJSON) (int, error) {
+	if err := tx.Transactions().LockChain(ctx); err != nil {
 		return 0, err
 	}
-	defer tx.Commit() // commit only if the method is done
-
-	var incomingMaterial IncomingMaterialDB
-	err = tx.QueryRow(`
-		SELECT customer_id, stock_id, quantity, cost, min_required_quantity,
-		max_required_quantity, description, is_active, type, owner
-		FROM incoming_materials
-		WHERE shipping_id = $1`, material.MaterialID).
-		Scan(
-			&incomingMaterial.CustomerID,
-			&incomingMaterial.StockID,
-			&incomingMaterial.Quantity,
-			&incomingMaterial.Cost,
-			&incomingMaterial.MinQty,
-			&incomingMaterial.MaxQty,
-			&incomingMaterial.Description,
-			&incomingMaterial.IsActive,
-			&incomingMaterial.MaterialType,
-			&incomingMaterial.Owner,
-		)
+	shippingId := material.MaterialID
+	incomingMaterial, err := getIncomingForUpdate(ctx, tx, shippingId)
 	if err != nil {
-		tx.Rollback()
+		return 0, err
+	}
+	if err := requireVaultAccess(ctx, incomingMaterial.MaterialType); err != nil {
 		return 0, err
 	}
 
+	qty := material.Qty
+	locationId := material.LocationID
+	// The landed charges are allocated to the quantity of the shipment, more units would carry them twice
+	if !incomingMaterial.LandedCost.IsZero() && qty > incomingMaterial.Quantity {
+		return 0, errs.New(errs.ErrConflict,
+			"More than the incoming quantity of an Incoming Material with landed charges cannot be received",
+			map[string]any{"shippingId": shippingId, "quantity": incomingMaterial.Quantity},
+		)
+	}
+
 	// Update material in the current location if location exists
-	var materialId int
-	rows, err := tx.Query(`
-					UPDATE materials
-					SET quantity = (quantity + $1),
-						notes = $2
-					WHERE stock_id = $3
-						AND location_id = $4
-						AND owner = $5
-					RETURNING material_id;
-					`, material.Qty, material.Notes, incomingMaterial.StockID, material.LocationID, incomingMaterial.Owner,
-	)
+	materialId, err := tx.Materials().FindAtLocation(ctx, incomingMaterial.StockID, locationId, incomingMaterial.Owner)
 	if err != nil {
-		tx.Rollback()
 		return 0, err
 	}
-	for rows.Next() {
-		err := rows.Scan(&materialId)
-		if err != nil {
-			tx.Rollback()
-			return 0, err
-		}
-	}
-	rows.Close()
-
-	// Upsert Prices
-	var priceId int
-	qty, _ := strconv.Atoi(material.Qty)
 
 	if materialId != 0 {
-		priceInfo := Price{materialId: materialId, qty: qty, cost: incomingMaterial.Cost}
-		priceId, err = upsertPrice(tx, priceInfo)
-		if err != nil {
-			tx.Rollback()
+		if err = tx.Materials().AddQuantity(ctx, materialId, qty); err != nil {
+			return 0, err
+		}
+		if err = tx.Materials().UpdateNotes(ctx, materialId, material.Notes); err != nil {
 			return 0, err
 		}
 	} else {
 		// If there is no a Material in the chosen Location:
 		// 1. Check for a NULL location and if it exists then assign the new location and qty
-		rows, err := tx.Query(`
-			SELECT material_id FROM materials
-			WHERE location_id is NULL
-				AND stock_id = $1
-				AND owner = $2;
-		`, incomingMaterial.StockID, incomingMaterial.Owner)
+		materialId, err = tx.Materials().FindUnplaced(ctx, incomingMaterial.StockID, incomingMaterial.Owner)
 		if err != nil {
-			tx.Rollback()
 			return 0, err
 		}
 
-		for rows.Next() {
-			err := rows.Scan(&materialId)
-			if err != nil {
-				tx.Rollback()
-				return 0, err
-			}
-		}
-
 		if materialId != 0 {
-			rows, err := tx.Query(`
-					UPDATE materials
-					SET quantity = $1,
-						notes = $2,
-						location_id = $3
-					WHERE material_id = $4;
-					`, material.Qty, material.Notes, material.LocationID, materialId,
-			)
+			err = tx.Materials().Place(ctx, materialId, locationId, qty, material.Notes)
 			if err != nil {
-				tx.Rollback()
 				return 0, err
 			}
-			rows.Close()
 		} else {
 			// 2. If there is no a NULL Location, then add the material to the new location
-			// Insert a new Material
-			err = tx.QueryRow(`
-						INSERT INTO materials
-						(
-							stock_id,
-							location_id,
-							customer_id,
-							material_type,
-							description,
-							notes,
-							quantity,
-							updated_at,
-							min_required_quantity,
-							max_required_quantity,
-							is_active,
-							owner,
-							is_primary,
-							serial_number_range
-						)
-						VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14) RETURNING material_id;`,
-				incomingMaterial.StockID,
-				material.LocationID,
-				incomingMaterial.CustomerID,
-				incomingMaterial.MaterialType,
-				incomingMaterial.Description,
-				material.Notes,
-				material.Qty,
-				time.Now(),
-				incomingMaterial.MinQty,
-				incomingMaterial.MaxQty,
-				incomingMaterial.IsActive,
-				incomingMaterial.Owner,
-				material.IsPrimary,
-				material.SerialNumberRange,
-			).Scan(&materialId)
+			materialId, err = tx.Materials().Create(ctx, storage.MaterialDB{
+				StockID:           incomingMaterial.StockID,
+				LocationID:        locationId,
+				CustomerID:        incomingMaterial.CustomerID,
+				MaterialType:      incomingMaterial.MaterialType,
+				Description:       incomingMaterial.Description,
+				Notes:             material.Notes,
+				Quantity:          qty,
+				UpdatedAt:         time.Now(),
+				MinQty:            incomingMaterial.MinQty,
+				MaxQty:            incomingMaterial.MaxQty,
+				IsActive:          incomingMaterial.IsActive,
+				Owner:             incomingMaterial.Owner,
+				IsPrimary:         material.IsPrimary,
+				SerialNumberRange: material.SerialNumberRange,
+			})
 			if err != nil {
-				tx.Rollback()
 				return 0, err
 			}
 		}
+	}
 
-		// Upsert Prices
-		priceInfo := Price{materialId: materialId, qty: qty, cost: incomingMaterial.Cost}
-		priceId, err = upsertPrice(tx, priceInfo)
-		if err != nil {
-			tx.Rollback()
-			return 0, err
-		}
+	// Update the Material from Incoming, a received one is kept with no quantity for its landed charges
+	if err = tx.Incoming().AddQuantity(ctx, shippingId, -min(qty, incomingMaterial.Quantity)); err != nil {
+		return 0, err
 	}
 
-	// Delete/Update the Material from Incoming
-	shippingId, _ := strconv.Atoi(material.MaterialID)
-	if (incomingMaterial.Quantity == qty) || (incomingMaterial.Quantity < qty) {
-		err = deleteIncomingMaterial(tx, shippingId)
+	// Every receipt is a new cost layer, the landed charges of the shipment are included in its cost
+	priceInfo := storage.Price{
+		MaterialID:  materialId,
+		Qty:         qty,
+		Cost:        incomingMaterial.Cost,
+		Currency:    incomingMaterial.Currency,
+		ReceivedAt:  time.Now(),
+		OriginalQty: qty,
+	}
+	receiptTrx := storage.Transaction{
+		Notes:             material.Notes,
+		UpdatedAt:         time.Now(),
+		SerialNumberRange: material.SerialNumberRange,
+		Type:              storage.TransactionReceipt,
+		UserID:            users.UserID(ctx),
+		ToLocationID:      locationId,
+		ShippingID:        shippingId,
+		CorrelationID:     storage.NewCorrelationID(),
+	}
+	if incomingMaterial.LandedCost.IsZero() {
+		priceId, err := tx.Prices().Create(ctx, priceInfo)
 		if err != nil {
-			tx.Rollback()
+			return 0, err
+		}
+		receiptTrx.PriceID, receiptTrx.Qty = priceId, qty
+		if err = tx.Transactions().Add(ctx, receiptTrx); err != nil {
 			return 0, err
 		}
 	} else {
-		material := &IncomingMaterial{shippingId: shippingId, qty: -qty}
-		_, err := tx.Exec(`
-					UPDATE incoming_materials
-					SET quantity = (quantity + $2)
-					WHERE shipping_id = $1
-					`, material.shippingId, material.qty,
-		)
+		// The last receipt takes what is left of the landed value, so the receipts add up to the allocated amounts
+		landedValue := storage.ExtendedValue(qty, incomingMaterial.LandedCost)
+		if qty == incomingMaterial.Quantity {
+			landedValue = incomingMaterial.LandedValue
+		}
+		if err = tx.Incoming().AddLandedCost(ctx, shippingId, decimal.Decimal{}, landedValue.Neg()); err != nil {
+			return 0, err
+		}
+		value := storage.ExtendedValue(qty, incomingMaterial.Cost).Add(landedValue)
+		err = addLayerOfValue(ctx, tx, priceInfo, value, landedValue, receiptTrx, material.Notes, "Landed cost rounding difference")
 		if err != nil {
-			tx.Rollback()
 			return 0, err
 		}
 	}
 
-	// Add a Transaction
-	trxInfo := &TransactionInfo{
-		priceId:           priceId,
-		qty:               qty,
-		notes:             material.Notes,
-		updatedAt:         time.Now(),
-		serialNumberRange: material.SerialNumberRange,
+	method, err := costingMethodOf(ctx, tx, incomingMaterial.Owner, incomingMaterial.CustomerID)
+	if err != nil {
+		return 0, err
 	}
-	err = addTranscation(trxInfo, tx)
+	err = method.rebalance(ctx, tx, materialId, storage.Transaction{UserID: users.UserID(ctx), ToLocationID: locationId})
 	if err != nil {
-		tx.Rollback()
 		return 0, err
 	}
 
 	return materialId, nil
 }
 
-func UpdateIncomingMaterial(db *sql.DB, material IncomingMaterialJSON) error {
-	_, err := db.Exec(`
-		UPDATE incoming_materials
-		SET customer_id = $2,
-			stock_id = $3,
-			cost = $4,
-			quantity = $5,
-			max_required_quantity = $6,
-			min_required_quantity = $7,
-			description = $8,
-			is_active = $9,
-			type = $10,
-			owner = $11
-		WHERE shipping_id = $1;
-	`,
-		material.ShippingId,
-		material.CustomerID,
-		material.StockID,
-		material.Cost,
-		material.Qty,
-		material.MaxQty,
-		material.MinQty,
-		material.Description,
-		material.IsActive,
-		material.MaterialType,
-		material.Owner,
-	)
-
-	if err != nil {
-		return err
+// Returns ErrNotFound if the Incoming Material is already received, the row stays locked until the end of the Transaction
+func getIncomingForUpdate(ctx context.Context, tx storage.Tx, shippingId int) (storage.IncomingMaterialDB, error) {
+	material, err := tx.Incoming().GetForUpdate(ctx, shippingId)
+	if err == nil && material.Quantity <= 0 {
+		return storage.IncomingMaterialDB{}, storage.ErrNotFound
 	}
-	return nil
+	return material, err
+}
+
+// The method rewrites the Incoming Material, the previous values are kept in the audit log.
+func UpdateIncomingMaterial(ctx context.Context, store storage.Store, material IncomingMaterialJSON) error {
+	material.Currency = incomingCurrency(material.Currency)
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		before, err := getIncomingForUpdate(ctx, tx, material.ShippingId)
+		if err != nil {
+			return err
+		}
+		// The landed cost is allocated in the currency of the shipment
+		if !before.LandedCost.IsZero() && before.Currency != material.Currency {
+			return errs.New(errs.ErrConflict,
+				"The currency of an Incoming Material with landed charges cannot be changed",
+				map[string]any{"shippingId": material.ShippingId, "currency": before.Currency},
+			)
+		}
+		// and spread over its quantity, the charges would not add up over another one
+		if !before.LandedCost.IsZero() && before.Quantity != material.Qty {
+			return errs.New(errs.ErrConflict,
+				"The quantity of an Incoming Material with landed charges cannot be changed",
+				map[string]any{"shippingId": material.ShippingId, "quantity": before.Quantity},
+			)
+		}
+
+		err = tx.Incoming().Update(ctx, storage.IncomingMaterialDB{
+			ShippingID:   strconv.Itoa(material.ShippingId),
+			CustomerID:   material.CustomerID,
+			StockID:      material.StockID,
+			Cost:         material.Cost,
+			Currency:     material.Currency,
+			UnitWeight:   material.UnitWeight,
+			Quantity:     material.Qty,
+			MinQty:       material.MinQty,
+			MaxQty:       material.MaxQty,
+			Description:  material.Description,
+			IsActive:     material.IsActive,
+			MaterialType: material.MaterialType,
+			Owner:        material.Owner,
+		})
+		if err != nil {
+			return err
+		}
+
+		// The sender is not changed by the update
+		material.UserID = before.UserID
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   users.UserID(ctx),
+			Action:   audit.ActionUpdate,
+			Entity:   audit.EntityIncoming,
+			EntityID: material.ShippingId,
+			Before:   toIncomingJSON(before),
+			After:    material,
+		})
+	})
 }
 
 // The method changes the Material quantity at the current and new Location, its Prices, and adds Transaction Logs.
-// Method's Context: Material Moving. The Transaction Rollback is executed once an error occurs.
-func MoveMaterial(ctx context.Context, db *sql.DB, material MaterialJSON) error {
-	tx, err := db.BeginTx(ctx, nil)
+// Method's Context: Material Moving. All changes are made in one Transaction committed only if no error occurs.
+// Materials under dual control are not moved, the method returns the ID of the pending Operation instead.
+func MoveMaterial(ctx context.Context, store storage.Store, material MaterialJSON) (int, error) {
+	var operationId int
+	err := storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		var err error
+		operationId, err = requestApproval(ctx, tx, OperationMove, material)
+		if err != nil || operationId != 0 {
+			return err
+		}
+		return moveMaterial(ctx, tx, material, currentUser(ctx))
+	})
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer tx.Commit() // commit only if the method is done
+	return operationId, nil
+}
 
-	materialId, _ := strconv.Atoi(material.MaterialID)
-	currMaterial, err := getMaterialById(materialId, tx)
+func moveMaterial(ctx context.Context, tx storage.Tx, material MaterialJSON, by stockUsers) error {
+	// Both Materials are locked after the chain, see LockChain
+	if err := tx.Transactions().LockChain(ctx); err != nil {
+		return err
+	}
+	currMaterial, err := tx.Materials().GetForUpdate(ctx, material.MaterialID)
 	if err != nil {
 		return err
 	}
+	if err := requireVaultAccess(ctx, currMaterial.MaterialType); err != nil {
+		return err
+	}
 
 	newLocationId := material.LocationID
-	quantity, _ := strconv.Atoi(material.Qty)
+	quantity := material.Qty
 	actualQuantity := currMaterial.Quantity
 	currMaterialId := currMaterial.MaterialID
-	currentLocationId := currMaterial.LocationID
 	stockId := currMaterial.StockID
 	owner := currMaterial.Owner
-	currNotes := currMaterial.Notes
 
 	// 1. Update the Material in the current Location
 
 	// Check whether remaining quantity exists
 	if actualQuantity < quantity {
-		return errors.New(
-			`The moving quantity (` +
-				strconv.Itoa(quantity) + `) is more than the actual one (` +
-				strconv.Itoa(actualQuantity) + `)`)
+		return insufficientQuantity("moving", quantity, actualQuantity)
 	} else if actualQuantity > quantity {
 		// Update material in the current location
-		err = tx.QueryRow(`
-			UPDATE materials
-			SET quantity = (quantity - $1),
-				notes = $2
-			WHERE material_id = $3 AND location_id = $4
-			RETURNING material_id, stock_id, location_id, customer_id, material_type,
-					description, notes, quantity, updated_at, is_active,
-					min_required_quantity, max_required_quantity, owner,
-					is_primary, serial_number_range;
-			`, quantity, currNotes, currMaterialId, currentLocationId,
-		).Scan(
-			&currMaterial.MaterialID,
-			&currMaterial.StockID,
-			&currMaterial.LocationID,
-			&currMaterial.CustomerID,
-			&currMaterial.MaterialType,
-			&currMaterial.Description,
-			&currMaterial.Notes,
-			&currMaterial.Quantity,
-			&currMaterial.UpdatedAt,
-			&currMaterial.IsActive,
-			&currMaterial.MinQty,
-			&currMaterial.MaxQty,
-			&currMaterial.Owner,
-			&currMaterial.IsPrimary,
-			&currMaterial.SerialNumberRange,
-		)
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
-	} else if actualQuantity == quantity {
-		defer func() error {
-			_, err = tx.Exec(`
-				UPDATE materials
-				SET location_id = NULL,
-					quantity = 0
-				WHERE material_id = $1`,
-				currMaterialId,
-			)
-			if err != nil {
-				tx.Rollback()
-				return err
-			}
-			return nil
-		}()
+		err = tx.Materials().AddQuantity(ctx, currMaterialId, -quantity)
+	} else {
+		err = tx.Materials().Unplace(ctx, currMaterialId)
+	}
+	if err != nil {
+		return err
 	}
 
 	// 1.1. Update Prices for the current Location
 
 	// Remove Prices for the current Material ID
+	// Both halves of the move share the correlation ID
+	moveTrx := storage.Transaction{
+		JobTicket:      "Auto-Ticket: " + time.Now().Local().String(),
+		UserID:         by.userId,
+		ApprovedBy:     by.approvedBy,
+		FromLocationID: currMaterial.LocationID,
+		ToLocationID:   newLocationId,
+		CorrelationID:  storage.NewCorrelationID(),
+	}
+	method, err := costingMethodOf(ctx, tx, owner, currMaterial.CustomerID)
+	if err != nil {
+		return err
+	}
 	priceToRemove := PriceToRemove{
 		materialId: currMaterialId,
 		qty:        quantity,
-		notes:      "Moved TO a Location",
-		jobTicket:  "Auto-Ticket: " + time.Now().Local().String(),
+		method:     method,
+		layers:     material.Layers,
+		trx:        moveTrx,
 	}
-	removedPrices, err := removePricesFIFO(tx, priceToRemove)
+	priceToRemove.trx.Type = storage.TransactionMoveOut
+	priceToRemove.trx.Notes = "Moved TO a Location"
+	removedPrices, err := removePrices(ctx, tx, priceToRemove)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
 	// 2. Update a Material in the new Location
-	var newMaterialId int
 
 	// Find an existing Material in the Location
-	rows, err := db.Query(`
-			UPDATE materials
-			SET quantity = (quantity + $1)
-			WHERE
-				stock_id = $2 AND
-				location_id = $3 AND
-				owner = $4
-			RETURNING material_id;
-				`, quantity, stockId, newLocationId, owner,
-	)
+	newMaterialId, err := tx.Materials().FindAtLocation(ctx, stockId, newLocationId, owner)
 	if err != nil {
 		return err
 	}
-	for rows.Next() {
-		err := rows.Scan(&newMaterialId)
-		if err != nil {
-			return err
-		}
+
+	if newMaterialId != 0 {
+		err = tx.Materials().AddQuantity(ctx, newMaterialId, quantity)
+	} else {
+		// If there is no a Material in the new Location, then create it
+		newMaterialId, err = tx.Materials().Create(ctx, storage.MaterialDB{
+			StockID:           stockId,
+			LocationID:        newLocationId,
+			CustomerID:        currMaterial.CustomerID,
+			MaterialType:      currMaterial.MaterialType,
+			Description:       currMaterial.Description,
+			Notes:             currMaterial.Notes,
+			Quantity:          quantity,
+			UpdatedAt:         time.Now(),
+			IsActive:          currMaterial.IsActive,
+			MinQty:            currMaterial.MinQty,
+			MaxQty:            currMaterial.MaxQty,
+			Owner:             currMaterial.Owner,
+			IsPrimary:         currMaterial.IsPrimary,
+			SerialNumberRange: currMaterial.SerialNumberRange,
+		})
+	}
+	if err != nil {
+		return err
 	}
 
-	// If there is no a Material in the new Location, then create it
-	if newMaterialId == 0 {
-		err = db.QueryRow(`
-				INSERT INTO materials
-					(
-						stock_id, location_id,
-						customer_id, material_type, description, notes,
-						quantity, updated_at,
-						is_active, min_required_quantity, max_required_quantity,
-						owner, is_primary, serial_number_range
-					)
-					VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)
-					RETURNING material_id;`,
-			stockId, newLocationId,
-			currMaterial.CustomerID, currMaterial.MaterialType, currMaterial.Description,
-			currNotes, quantity, time.Now(), currMaterial.IsActive,
-			currMaterial.MinQty, currMaterial.MaxQty, currMaterial.Owner,
-			currMaterial.IsPrimary, currMaterial.SerialNumberRange).
-			Scan(&newMaterialId)
-		if err != nil {
-			return err
-		}
+	err = audit.Record(ctx, tx, audit.Change{
+		UserID:   by.userId,
+		Action:   audit.ActionMove,
+		Entity:   audit.EntityMaterial,
+		EntityID: currMaterialId,
+		Before:   map[string]int{"locationId": currMaterial.LocationID, "quantity": actualQuantity},
+		After: map[string]int{
+			"locationId":   currMaterial.LocationID,
+			"quantity":     actualQuantity - quantity,
+			"toLocationId": newLocationId,
+			"toMaterialId": newMaterialId,
+			"approvedBy":   by.approvedBy,
+		},
+	})
+	if err != nil {
+		return err
 	}
 
 	// 2.2. Update Prices for the new Location and Material ID
 
 	for i := 0; i < len(removedPrices); i++ {
-		qty := removedPrices[i].qty
-		cost := removedPrices[i].cost
-		priceInfo := Price{materialId: newMaterialId, qty: qty, cost: cost}
+		qty := removedPrices[i].Qty
+		cost := removedPrices[i].Cost
+		// The moved layer keeps its receipt time, currency and landed cost
+		priceInfo := storage.Price{
+			MaterialID:  newMaterialId,
+			Qty:         qty,
+			Cost:        cost,
+			Currency:    removedPrices[i].Currency,
+			ReceivedAt:  removedPrices[i].ReceivedAt,
+			OriginalQty: qty,
+			LandedCost:  removedPrices[i].LandedCost,
+		}
 
-		priceId, err := upsertPrice(tx, priceInfo)
+		priceId, err := tx.Prices().Create(ctx, priceInfo)
 		if err != nil {
-			tx.Rollback()
 			return err
 		}
 
-		err = addTranscation(&TransactionInfo{
-			priceId:           priceId,
-			qty:               qty,
-			notes:             "Moved FROM a Location",
-			jobTicket:         "Auto-Ticket: " + time.Now().Local().String(),
-			updatedAt:         time.Now(),
-			serialNumberRange: material.SerialNumberRange,
-		}, tx)
-		if err != nil {
-			tx.Rollback()
+		moveInTrx := moveTrx
+		moveInTrx.PriceID = priceId
+		moveInTrx.Qty = qty
+		moveInTrx.Type = storage.TransactionMoveIn
+		moveInTrx.Notes = "Moved FROM a Location"
+		moveInTrx.UpdatedAt = time.Now()
+		moveInTrx.SerialNumberRange = material.SerialNumberRange
+		if err := tx.Transactions().Add(ctx, moveInTrx); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return method.rebalance(ctx, tx, newMaterialId, storage.Transaction{
+		UserID:       by.userId,
+		ApprovedBy:   by.approvedBy,
+		ToLocationID: newLocationId,
+	})
 }
 
 // The method removes a specific Material quantity, its Prices, adds a Transaction Log.
-// Method's Context: Material Removing. The Transaction Rollback is executed once an error occurs.
-func RemoveMaterial(ctx context.Context, db *sql.DB, material MaterialJSON) error {
-	tx, err := db.BeginTx(ctx, nil)
+// Method's Context: Material Removing. All changes are made in one Transaction committed only if no error occurs.
+// Materials under dual control are not removed, the method returns the ID of the pending Operation instead.
+func RemoveMaterial(ctx context.Context, store storage.Store, material MaterialJSON) (int, error) {
+	var operationId int
+	err := storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		var err error
+		operationId, err = requestApproval(ctx, tx, OperationRemove, material)
+		if err != nil || operationId != 0 {
+			return err
+		}
+		return removeMaterial(ctx, tx, material, currentUser(ctx))
+	})
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer tx.Commit() // commit only if the method is done
+	return operationId, nil
+}
 
-	materialId, _ := strconv.Atoi(material.MaterialID)
-	currMaterial, err := getMaterialById(materialId, tx)
+func removeMaterial(ctx context.Context, tx storage.Tx, material MaterialJSON, by stockUsers) error {
+	if err := tx.Transactions().LockChain(ctx); err != nil {
+		return err
+	}
+	materialId := material.MaterialID
+	currMaterial, err := tx.Materials().GetForUpdate(ctx, materialId)
 	if err != nil {
-		tx.Rollback()
-		return errors.New("Unable to get the current material info: " + err.Error())
+		return fmt.Errorf("Unable to get the current material info: %w", err)
+	}
+	if err := requireVaultAccess(ctx, currMaterial.MaterialType); err != nil {
+		return err
 	}
 
-	quantity, _ := strconv.Atoi(material.Qty)
+	quantity := material.Qty
 	actualQuantity := currMaterial.Quantity
 	jobTicket := material.JobTicket
 
 	if actualQuantity < quantity {
-		return errors.New(`The removing quantity (` + strconv.Itoa(quantity) + `) is more than the actual one (` + strconv.Itoa(actualQuantity) + `)`)
+		return insufficientQuantity("removing", quantity, actualQuantity)
 	} else if actualQuantity == quantity {
-		_, err = tx.Exec(`
-			UPDATE materials
-			SET location_id = NULL,
-				quantity = 0
-			WHERE material_id = $1
-		`, materialId)
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
+		err = tx.Materials().Unplace(ctx, materialId)
 	} else {
 		// Update the material quantity
-		_, err = tx.Exec(`
-				UPDATE materials
-				SET quantity = (quantity - $1)
-				WHERE material_id = $2;
-		`, quantity, materialId,
-		)
+		err = tx.Materials().AddQuantity(ctx, materialId, -quantity)
 	}
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	priceToRemove := PriceToRemove{
-		materialId:        materialId,
-		qty:               quantity,
-		notes:             "Removed FROM a Location",
-		jobTicket:         jobTicket,
-		serialNumberRange: material.SerialNumberRange,
-	}
-	_, err = removePricesFIFO(tx, priceToRemove)
+	method, err := costingMethodOf(ctx, tx, currMaterial.Owner, currMaterial.CustomerID)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
-
-	return nil
-}
-
-func UpdateMaterial(db *sql.DB, material MaterialJSON) error {
-	materialId, _ := strconv.Atoi(material.MaterialID)
-	_, err := db.Exec(`
-		UPDATE materials
-		SET is_primary = $2
-		WHERE material_id = $1
-	`, materialId, material.IsPrimary)
+	priceToRemove := PriceToRemove{
+		materialId: materialId,
+		qty:        quantity,
+		method:     method,
+		layers:     material.Layers,
+		trx: storage.Transaction{
+			Type:              storage.TransactionIssue,
+			Notes:             "Removed FROM a Location",
+			JobTicket:         jobTicket,
+			SerialNumberRange: material.SerialNumberRange,
+			UserID:            by.userId,
+			ApprovedBy:        by.approvedBy,
+			FromLocationID:    currMaterial.LocationID,
+			RequestID:         material.RequestID,
+			CorrelationID:     storage.NewCorrelationID(),
+		},
+	}
+	_, err = removePrices(ctx, tx, priceToRemove)
 	if err != nil {
 		return err
 	}
-	return nil
-}
 
-func RequestMaterials(ctx context.Context, db *sql.DB, materials RequestedMaterialsJSON) error {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Commit()
+	return audit.Record(ctx, tx, audit.Change{
+		UserID:   by.userId,
+		Action:   audit.ActionRemove,
+		Entity:   audit.EntityMaterial,
+		EntityID: materialId,
+		Before:   map[string]any{"quantity": actualQuantity},
+		After: map[string]any{
+			"quantity":          actualQuantity - quantity,
+			"jobTicket":         jobTicket,
+			"serialNumberRange": material.SerialNumberRange,
+			"approvedBy":        by.approvedBy,
+		},
+	})
+}
 
-	var userId sql.NullInt64
-	if materials.UserID == 0 {
-		userId = sql.NullInt64{Valid: false}
-	} else {
-		userId = sql.NullInt64{Int64: int64(materials.UserID), Valid: true}
-	}
+func UpdateMaterial(ctx context.Context, store storage.Store, material MaterialJSON) error {
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		before, err := tx.Materials().Get(ctx, material.MaterialID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Materials().SetPrimary(ctx, material.MaterialID, material.IsPrimary); err != nil {
+			return err
+		}
 
-	query := `
-	INSERT INTO requested_materials
-		(stock_id, description, quantity_requested, quantity_used, status, notes, updated_at, requested_at, user_id) VALUES `
-	args := []interface{}{}
-	placeholderCount := 1
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   users.UserID(ctx),
+			Action:   audit.ActionUpdate,
+			Entity:   audit.EntityMaterial,
+			EntityID: material.MaterialID,
+			Before:   map[string]bool{"isPrimary": before.IsPrimary},
+			After:    map[string]bool{"isPrimary": material.IsPrimary},
+		})
+	})
+}
 
-	for i, m := range materials.Materials {
-		qty, err := strconv.Atoi(m.Qty)
-		if qty == 0 || err != nil {
+func RequestMaterials(ctx context.Context, store storage.Store, materials RequestedMaterialsJSON) error {
+	requests := []storage.RequestedMaterial{}
+	for _, m := range materials.Materials {
+		if m.Qty == 0 {
 			continue
 		}
 
-		if i > 0 {
-			query += ", "
-		}
-		query += fmt.Sprintf(
-			"($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
-			placeholderCount, placeholderCount+1,
-			placeholderCount+2, placeholderCount+3,
-			placeholderCount+4, placeholderCount+5,
-			placeholderCount+6, placeholderCount+7,
-			placeholderCount+8,
-		)
-
-		args = append(args, m.StockID, m.Description, m.Qty, 0, "pending", "Requested", time.Now(), time.Now(), userId)
-		placeholderCount += 9
+		requests = append(requests, storage.RequestedMaterial{
+			UserID:      materials.UserID,
+			StockID:     m.StockID,
+			Description: m.Description,
+			Qty:         m.Qty,
+			Status:      "pending",
+			Notes:       "Requested",
+			RequestedAt: time.Now(),
+		})
 	}
 
-	_, err = tx.Exec(query, args...)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-	return nil
-}
-
-func GetRequestedMaterials(db *sql.DB, filterOpts MaterialFilter) ([]MaterialDB, error) {
-	rows, err := db.Query(`
-		SELECT
-			request_id,
-			COALESCE(u.username, '') AS "username",
-			stock_id,
-			description,
-			quantity_requested,
-			quantity_used,
-			status,
-			notes,
-			updated_at,
-			requested_at
-		FROM requested_materials rm
-		LEFT JOIN users u ON u.user_id = rm.user_id
-		WHERE ($1 = 0 OR rm.request_id = $1) AND
-		      ($2 = '' OR rm.stock_id ILIKE '%' || $2 || '%') AND
-			  ($3 = '' OR rm.status::TEXT = $3) AND
-			  ($4 = '' OR rm.requested_at::TEXT <= $4)
-		ORDER BY rm.requested_at;
-			  `,
-		filterOpts.RequestId,
-		filterOpts.StockId,
-		filterOpts.Status,
-		filterOpts.RequestedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var materials []MaterialDB
-	for rows.Next() {
-		var material MaterialDB
-
-		if err := rows.Scan(
-			&material.RequestID,
-			&material.UserName,
-			&material.StockID,
-			&material.Description,
-			&material.QtyRequested,
-			&material.QtyUsed,
-			&material.Status,
-			&material.Notes,
-			&material.UpdatedAt,
-			&material.RequestedAt,
-		); err != nil {
-			return nil, fmt.Errorf("Error scanning row: %w", err)
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		if err := tx.Requests().Create(ctx, requests); err != nil {
+			return err
 		}
-		materials = append(materials, material)
-	}
-	return materials, nil
+
+		return audit.Record(ctx, tx, audit.Change{
+			UserID: users.UserID(ctx),
+			Action: audit.ActionRequest,
+			Entity: audit.EntityRequest,
+			After:  materials,
+		})
+	})
 }
 
-func UpdateRequestedMaterial(db *sql.DB, material MaterialJSON) error {
-	requestId, _ := strconv.Atoi(material.MaterialID)
-	quantity, _ := strconv.Atoi(material.Qty)
+func GetRequestedMaterials(ctx context.Context, store storage.Store, filterOpts storage.MaterialFilter, page storage.Page) ([]storage.MaterialDB, int, error) {
+	filterOpts.CustomerIDs = users.CustomerScope(ctx)
+	return store.Requests().List(ctx, filterOpts, page)
+}
 
-	_, err := db.Exec(`
-		UPDATE requested_materials
-		SET quantity_used = quantity_used + $2,
-			status = $3,
-			notes = $4,
-			updated_at = $5
-		WHERE request_id = $1;
-	`, requestId, quantity, material.Status, material.Notes, time.Now())
+func UpdateRequestedMaterial(ctx context.Context, store storage.Store, material MaterialJSON) error {
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		err := tx.Requests().Update(ctx, storage.RequestUpdate{
+			RequestID: material.MaterialID,
+			QtyUsed:   material.Qty,
+			Status:    material.Status,
+			Notes:     material.Notes,
+			UpdatedAt: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		return err
-	}
-	return nil
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   users.UserID(ctx),
+			Action:   audit.ActionUpdate,
+			Entity:   audit.EntityRequest,
+			EntityID: material.MaterialID,
+			After: map[string]any{
+				"quantityUsed": material.Qty,
+				"status":       material.Status,
+				"notes":        material.Notes,
+			},
+		})
+	})
 }
 
-func GetMaterialDescription(db *sql.DB, stockId string) (string, error) {
-	var description string
-	err := db.QueryRow(`
-		SELECT description
-		FROM materials
-		WHERE LOWER(stock_id) = LOWER($1)
-		LIMIT 1;
-	`,
-		stockId,
-	).Scan(&description)
-	if err != nil {
-		return "", err
-	}
-	return description, nil
+// Stock IDs of other Customers are not found for customer scoped Users
+func GetMaterialDescription(ctx context.Context, store storage.Store, stockId string) (string, error) {
+	return store.Materials().Description(ctx, stockId, users.CustomerScope(ctx))
 }
@@ -0,0 +1,448 @@
+package materials
+
+import (
+	"context"
+	"errors"
+	"inv_app/services/errs"
+	"inv_app/services/users"
+	"inv_app/storage"
+	"inv_app/storage/memory"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// The tests run the Business Logic against the in-memory Storage with a User allowed to do everything
+
+type testEnv struct {
+	ctx        context.Context
+	store      storage.Store
+	customerId int
+	locations  []int
+	// Keeps the names and Stock IDs of the test apart from the other data in a shared database
+	suffix string
+}
+
+func newTestEnv(t *testing.T) testEnv {
+	t.Helper()
+	store := memory.New()
+	userId := store.AddUser(storage.UserDB{Username: "tester", Role: "admin", IsActive: true})
+	return setupTestEnv(t, store, userId, "")
+}
+
+// The method adds a Customer, a Warehouse and its Locations, their names end with the suffix
+func setupTestEnv(t *testing.T, store storage.Store, userId int, suffix string) testEnv {
+	t.Helper()
+	ctx := users.WithUser(context.Background(), users.UserJSON{
+		UserID:      userId,
+		Username:    "tester",
+		Role:        "admin",
+		Permissions: memory.RolePermissions["admin"],
+	})
+
+	customerId, err := store.Customers().Create(ctx, "Customer"+suffix, "CUST"+suffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	warehouseId, err := store.Warehouses().Create(ctx, "Warehouse"+suffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := testEnv{ctx: ctx, store: store, customerId: customerId, suffix: suffix}
+	for _, name := range []string{"A-1", "A-2", "A-3"} {
+		locationId, err := store.Locations().Create(ctx, name, warehouseId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.locations = append(env.locations, locationId)
+	}
+	return env
+}
+
+// The method sends a shipment of the Stock ID at the unit cost and returns its Shipping ID
+func (e testEnv) send(t *testing.T, stockId string, qty int, cost string) int {
+	t.Helper()
+	err := SendMaterial(e.ctx, e.store, IncomingMaterialJSON{
+		CustomerID:   e.customerId,
+		StockID:      stockId + e.suffix,
+		MaterialType: "PAPER",
+		Qty:          qty,
+		Cost:         decimal.RequireFromString(cost),
+		Description:  "Paper " + stockId,
+		Owner:        "Tag",
+		IsActive:     true,
+		UserID:       users.UserID(e.ctx),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	incoming, _, err := e.store.Incoming().List(e.ctx, storage.IncomingFilter{CustomerIDs: []int{e.customerId}}, storage.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return shippingIdOf(t, incoming[len(incoming)-1])
+}
+
+// The method receives the quantity of the shipment at the Location and returns the Material ID
+func (e testEnv) receive(t *testing.T, shippingId int, locationId int, qty int) int {
+	t.Helper()
+	materialId, err := CreateMaterial(e.ctx, e.store, MaterialJSON{MaterialID: shippingId, LocationID: locationId, Qty: qty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return materialId
+}
+
+func (e testEnv) material(t *testing.T, materialId int) storage.MaterialDB {
+	t.Helper()
+	material, err := e.store.Materials().Get(e.ctx, materialId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return material
+}
+
+func (e testEnv) layers(t *testing.T, materialId int) []storage.Price {
+	t.Helper()
+	layers, err := e.store.Prices().ListAvailable(e.ctx, materialId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return layers
+}
+
+func shippingIdOf(t *testing.T, material storage.IncomingMaterialDB) int {
+	t.Helper()
+	return toIncomingJSON(material).ShippingId
+}
+
+// Compares the quantities and costs of the layers, oldest first
+func assertLayers(t *testing.T, layers []storage.Price, want ...storage.Price) {
+	t.Helper()
+	if len(layers) != len(want) {
+		t.Fatalf("got %d layers %v, want %d", len(layers), layers, len(want))
+	}
+	for i := range want {
+		if layers[i].Qty != want[i].Qty || !layers[i].Cost.Equal(want[i].Cost) {
+			t.Errorf("layer %d: got %d at %s, want %d at %s", i, layers[i].Qty, layers[i].Cost, want[i].Qty, want[i].Cost)
+		}
+	}
+}
+
+func layer(qty int, cost string) storage.Price {
+	return storage.Price{Qty: qty, Cost: decimal.RequireFromString(cost)}
+}
+
+func TestCreateMaterial(t *testing.T) {
+	env := newTestEnv(t)
+	shippingId := env.send(t, "P-100", 100, "1.25")
+
+	materialId := env.receive(t, shippingId, env.locations[0], 60)
+	if material := env.material(t, materialId); material.Quantity != 60 || material.LocationID != env.locations[0] {
+		t.Fatalf("got %d at Location %d, want 60 at %d", material.Quantity, material.LocationID, env.locations[0])
+	}
+	incoming, err := env.store.Incoming().Get(env.ctx, shippingId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if incoming.Quantity != 40 {
+		t.Fatalf("incoming quantity: got %d, want 40", incoming.Quantity)
+	}
+
+	// The rest of the shipment goes to the same Material as a new cost layer
+	if id := env.receive(t, shippingId, env.locations[0], 40); id != materialId {
+		t.Fatalf("got Material %d, want %d", id, materialId)
+	}
+	if material := env.material(t, materialId); material.Quantity != 100 {
+		t.Fatalf("got %d, want 100", material.Quantity)
+	}
+	assertLayers(t, env.layers(t, materialId), layer(60, "1.25"), layer(40, "1.25"))
+
+	if _, total, err := GetIncomingMaterials(env.ctx, env.store, shippingId, storage.Page{}); err != nil || total != 0 {
+		t.Fatalf("the received shipment is still incoming: %d (%v)", total, err)
+	}
+
+	history, _, err := GetMaterialHistory(env.ctx, env.store, materialId, storage.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[1].QtyBalance != 100 || !history[1].ValueBalance.Equal(decimal.RequireFromString("125")) {
+		t.Fatalf("unexpected history %+v", history)
+	}
+	for _, trx := range history {
+		if trx.Type != storage.TransactionReceipt || trx.ShippingID != shippingId {
+			t.Errorf("got a %q Transaction Log of shipment %d, want a receipt of %d", trx.Type, trx.ShippingID, shippingId)
+		}
+	}
+}
+
+func TestCreateMaterialInOccupiedLocation(t *testing.T) {
+	env := newTestEnv(t)
+	env.receive(t, env.send(t, "P-100", 10, "1"), env.locations[0], 10)
+
+	shippingId := env.send(t, "P-200", 10, "1")
+	_, err := CreateMaterial(env.ctx, env.store, MaterialJSON{MaterialID: shippingId, LocationID: env.locations[0], Qty: 10})
+	if !errors.Is(err, errs.ErrConflict) {
+		t.Fatalf("got %v, want a conflict", err)
+	}
+	// Nothing of the failed receipt is kept
+	incoming, err := env.store.Incoming().Get(env.ctx, shippingId)
+	if err != nil || incoming.Quantity != 10 {
+		t.Fatalf("got %d (%v), want the shipment of 10 still incoming", incoming.Quantity, err)
+	}
+}
+
+func TestMoveMaterial(t *testing.T) {
+	env := newTestEnv(t)
+	materialId := env.receive(t, env.send(t, "P-100", 10, "1.00"), env.locations[0], 10)
+	env.receive(t, env.send(t, "P-100", 10, "2.00"), env.locations[0], 10)
+
+	// The oldest layer is moved first, the rest of the quantity comes from the next one
+	_, err := MoveMaterial(env.ctx, env.store, MaterialJSON{MaterialID: materialId, LocationID: env.locations[1], Qty: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if material := env.material(t, materialId); material.Quantity != 5 || material.LocationID != env.locations[0] {
+		t.Fatalf("got %d at Location %d, want 5 at %d", material.Quantity, material.LocationID, env.locations[0])
+	}
+	assertLayers(t, env.layers(t, materialId), layer(5, "2.00"))
+
+	newMaterialId, err := env.store.Materials().FindAtLocation(env.ctx, "P-100", env.locations[1], "Tag")
+	if err != nil || newMaterialId == 0 {
+		t.Fatalf("no Material at the new Location (%v)", err)
+	}
+	if material := env.material(t, newMaterialId); material.Quantity != 15 {
+		t.Fatalf("got %d, want 15", material.Quantity)
+	}
+	assertLayers(t, env.layers(t, newMaterialId), layer(10, "1.00"), layer(5, "2.00"))
+
+	// Moving the whole quantity releases the Location
+	if _, err := MoveMaterial(env.ctx, env.store, MaterialJSON{MaterialID: materialId, LocationID: env.locations[1], Qty: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if material := env.material(t, materialId); material.Quantity != 0 || material.LocationID != 0 {
+		t.Fatalf("got %d at Location %d, want the Material unplaced", material.Quantity, material.LocationID)
+	}
+	assertLayers(t, env.layers(t, newMaterialId), layer(10, "1.00"), layer(5, "2.00"), layer(5, "2.00"))
+}
+
+func TestMoveMaterialInsufficientQuantity(t *testing.T) {
+	env := newTestEnv(t)
+	materialId := env.receive(t, env.send(t, "P-100", 10, "1"), env.locations[0], 10)
+
+	_, err := MoveMaterial(env.ctx, env.store, MaterialJSON{MaterialID: materialId, LocationID: env.locations[1], Qty: 11})
+	if !errors.Is(err, errs.ErrInsufficientQuantity) {
+		t.Fatalf("got %v, want insufficient quantity", err)
+	}
+	if material := env.material(t, materialId); material.Quantity != 10 {
+		t.Fatalf("got %d, want 10", material.Quantity)
+	}
+}
+
+func TestRemoveMaterial(t *testing.T) {
+	env := newTestEnv(t)
+	materialId := env.receive(t, env.send(t, "P-100", 10, "1.00"), env.locations[0], 10)
+	env.receive(t, env.send(t, "P-100", 10, "3.00"), env.locations[0], 10)
+
+	_, err := RemoveMaterial(env.ctx, env.store, MaterialJSON{MaterialID: materialId, Qty: 12, JobTicket: "JOB-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if material := env.material(t, materialId); material.Quantity != 8 {
+		t.Fatalf("got %d, want 8", material.Quantity)
+	}
+	assertLayers(t, env.layers(t, materialId), layer(8, "3.00"))
+
+	history, _, err := GetMaterialHistory(env.ctx, env.store, materialId, storage.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues := history[2:]
+	if len(issues) != 2 || issues[0].Qty != -10 || issues[1].Qty != -2 || issues[1].JobTicket != "JOB-1" {
+		t.Fatalf("unexpected issues %+v", issues)
+	}
+	if balance := issues[1].ValueBalance; !balance.Equal(decimal.RequireFromString("24")) {
+		t.Fatalf("value balance: got %s, want 24", balance)
+	}
+
+	// Removing the rest releases the Location, more than the rest is refused
+	_, err = RemoveMaterial(env.ctx, env.store, MaterialJSON{MaterialID: materialId, Qty: 9})
+	if !errors.Is(err, errs.ErrInsufficientQuantity) {
+		t.Fatalf("got %v, want insufficient quantity", err)
+	}
+	if _, err := RemoveMaterial(env.ctx, env.store, MaterialJSON{MaterialID: materialId, Qty: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if material := env.material(t, materialId); material.Quantity != 0 || material.LocationID != 0 {
+		t.Fatalf("got %d at Location %d, want the Material unplaced", material.Quantity, material.LocationID)
+	}
+	assertLayers(t, env.layers(t, materialId))
+}
+
+func TestRemoveMaterialUnderDualControl(t *testing.T) {
+	env := newTestEnv(t)
+	shippingId := env.send(t, "C-100", 10, "1")
+	if err := env.store.Operations().SetDualControlTypes(env.ctx, []string{"PAPER"}); err != nil {
+		t.Fatal(err)
+	}
+	materialId := env.receive(t, shippingId, env.locations[0], 10)
+
+	operationId, err := RemoveMaterial(env.ctx, env.store, MaterialJSON{MaterialID: materialId, Qty: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if operationId == 0 {
+		t.Fatal("the removal was made without an approval")
+	}
+	if material := env.material(t, materialId); material.Quantity != 10 {
+		t.Fatalf("got %d, want 10 until the approval", material.Quantity)
+	}
+}
+
+// The in-memory Storage serializes whole Transactions, so the test checks the bookkeeping of the losing removals.
+// The row locks of Postgres are covered by TestRemoveMaterialConcurrentlyInPostgres.
+func TestRemoveMaterialConcurrently(t *testing.T) {
+	testRemoveMaterialConcurrently(t, newTestEnv(t))
+}
+
+// Parallel removals of one Material never take more than its stock, the losers fail without changes
+func testRemoveMaterialConcurrently(t *testing.T, env testEnv) {
+	const removals, qty, stock = 20, 3, 25
+
+	materialId := env.receive(t, env.send(t, "P-100", stock, "1"), env.locations[0], stock)
+
+	var wg sync.WaitGroup
+	results := make(chan error, removals)
+	for i := 0; i < removals; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := RemoveMaterial(env.ctx, env.store, MaterialJSON{MaterialID: materialId, Qty: qty})
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	removed := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			removed += qty
+		case !errors.Is(err, errs.ErrInsufficientQuantity):
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if removed > stock {
+		t.Fatalf("removed %d of the stock of %d", removed, stock)
+	}
+	if removed != stock/qty*qty {
+		t.Errorf("removed %d, want %d", removed, stock/qty*qty)
+	}
+
+	material := env.material(t, materialId)
+	if material.Quantity < 0 || material.Quantity != stock-removed {
+		t.Fatalf("got %d left, want %d", material.Quantity, stock-removed)
+	}
+	left := 0
+	for _, layer := range env.layers(t, materialId) {
+		left += layer.Qty
+	}
+	if left != material.Quantity {
+		t.Fatalf("the layers keep %d, the Material %d", left, material.Quantity)
+	}
+}
+
+// A reversal of a Material under dual control waits for a second User like the removal itself
+func TestReverseTransactionUnderDualControl(t *testing.T) {
+	env := newTestEnv(t)
+	materialId := env.receive(t, env.send(t, "P-100", 10, "1"), env.locations[0], 10)
+	if _, err := RemoveMaterial(env.ctx, env.store, MaterialJSON{MaterialID: materialId, Qty: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.store.Operations().SetDualControlTypes(env.ctx, []string{"PAPER"}); err != nil {
+		t.Fatal(err)
+	}
+	history, _, err := GetMaterialHistory(env.ctx, env.store, materialId, storage.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issueId := history[len(history)-1].TransactionID
+
+	_, operationId, err := ReverseTransaction(env.ctx, env.store, issueId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if operationId == 0 {
+		t.Fatal("the reversal was made without an approval")
+	}
+	if material := env.material(t, materialId); material.Quantity != 0 {
+		t.Fatalf("got %d, want 0 until the approval", material.Quantity)
+	}
+	if err := ApproveOperation(env.ctx, env.store, operationId); !errors.Is(err, errs.ErrForbidden) {
+		t.Fatalf("got %v, want the requesting User refused", err)
+	}
+
+	approverId, err := env.store.Users().Create(env.ctx, storage.UserDB{Username: "approver", Role: "vault", IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	approverCtx := users.WithUser(context.Background(), users.UserJSON{
+		UserID:      approverId,
+		Username:    "approver",
+		Role:        "vault",
+		Permissions: memory.RolePermissions["vault"],
+	})
+	if err := ApproveOperation(approverCtx, env.store, operationId); err != nil {
+		t.Fatal(err)
+	}
+	if material := env.material(t, materialId); material.Quantity != 10 || material.LocationID != env.locations[0] {
+		t.Fatalf("got %d at Location %d, want 10 back at %d", material.Quantity, material.LocationID, env.locations[0])
+	}
+	history, _, err = GetMaterialHistory(env.ctx, env.store, materialId, storage.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reversal := history[len(history)-1]; reversal.Type != storage.TransactionReversal || reversal.ApprovedBy != approverId {
+		t.Fatalf("got a %q Transaction Log approved by %d, want a reversal approved by %d", reversal.Type, reversal.ApprovedBy, approverId)
+	}
+}
+
+// A move into a weighted average Material is reversed from its current layers, the stock returns at their cost
+func TestReverseMoveIntoAverageMaterial(t *testing.T) {
+	env := newTestEnv(t)
+	err := SetCostingMethods(env.ctx, env.store, CostingMethodsJSON{Rules: []CostingRuleJSON{{Owner: "Tag", Method: storage.CostingAverage}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	materialId := env.receive(t, env.send(t, "P-100", 10, "1.00"), env.locations[0], 10)
+	if _, err := MoveMaterial(env.ctx, env.store, MaterialJSON{MaterialID: materialId, LocationID: env.locations[1], Qty: 5}); err != nil {
+		t.Fatal(err)
+	}
+	history, _, err := GetMaterialHistory(env.ctx, env.store, materialId, storage.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	moveId := history[len(history)-1].TransactionID
+
+	movedId := env.receive(t, env.send(t, "P-100", 10, "3.00"), env.locations[1], 10)
+	assertLayers(t, env.layers(t, movedId), layer(15, "2.3333"))
+
+	reversal, _, err := ReverseTransaction(env.ctx, env.store, moveId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reversal.ReversalIDs) != 2 || slices.Contains(reversal.ReversalIDs, 0) {
+		t.Fatalf("got the reversals %v of %v", reversal.ReversalIDs, reversal.ReversedIDs)
+	}
+	assertLayers(t, env.layers(t, movedId), layer(10, "2.3333"))
+	assertLayers(t, env.layers(t, materialId), layer(10, "1.667"))
+	if material := env.material(t, movedId); material.Quantity != 10 {
+		t.Fatalf("got %d, want 10", material.Quantity)
+	}
+	if material := env.material(t, materialId); material.Quantity != 10 {
+		t.Fatalf("got %d, want 10", material.Quantity)
+	}
+}
@@ -1,48 +1,35 @@
 package materials
 
-import "time"
+import (
+	"inv_app/storage"
+	"time"
 
-type IncomingMaterialJSON struct {
-	ShippingId   string `json:"shippingId"`
-	CustomerID   string `json:"customerId"`
-	StockID      string `json:"stockId"`
-	MaterialType string `json:"type"`
-	Qty          string `json:"quantity"`
-	Cost         string `json:"cost"`
-	MinQty       string `json:"minQuantity"`
-	MaxQty       string `json:"maxQuantity"`
-	Description  string `json:"description"`
-	Owner        string `json:"owner"`
-	IsActive     bool   `json:"isActive"`
-	UserID       string `json:"userId"`
-}
-
-type IncomingMaterialDB struct {
-	ShippingID   string  `field:"shipping_id"`
-	CustomerName string  `field:"customer_name"`
-	CustomerID   int     `field:"customer_id"`
-	StockID      string  `field:"stock_id"`
-	Cost         float64 `field:"cost"`
-	Quantity     int     `field:"quantity"`
-	MinQty       int     `field:"min_required_quantity"`
-	MaxQty       int     `field:"max_required_quantity"`
-	Description  string  `field:"description"`
-	IsActive     bool    `field:"is_active"`
-	MaterialType string  `field:"material_type"`
-	Owner        string  `field:"owner"`
-	UserID       int     `field:"user_id"`
-	UserName     string  `field:"username"`
-}
+	"github.com/shopspring/decimal"
+)
 
-type IncomingMaterial struct {
-	shippingId int
-	qty        int
+// IncomingMaterialJSON is a shipment on its way. The Currency of the Cost is the base one if empty,
+// the UnitWeight is the basis of the landed charges allocated by weight.
+type IncomingMaterialJSON struct {
+	ShippingId   int             `json:"shippingId"`
+	CustomerID   int             `json:"customerId"`
+	StockID      string          `json:"stockId"`
+	MaterialType string          `json:"type"`
+	Qty          int             `json:"quantity"`
+	Cost         decimal.Decimal `json:"cost"`
+	Currency     string          `json:"currency"`
+	UnitWeight   decimal.Decimal `json:"unitWeight"`
+	MinQty       int             `json:"minQuantity"`
+	MaxQty       int             `json:"maxQuantity"`
+	Description  string          `json:"description"`
+	Owner        string          `json:"owner"`
+	IsActive     bool            `json:"isActive"`
+	UserID       int             `json:"userId"`
 }
 
 type MaterialJSON struct {
-	MaterialID        string `json:"materialId"`
-	LocationID        string `json:"locationId"`
-	Qty               string `json:"quantity"`
+	MaterialID        int    `json:"materialId"`
+	LocationID        int    `json:"locationId"`
+	Qty               int    `json:"quantity"`
 	Notes             string `json:"notes"`
 	IsPrimary         bool   `json:"isPrimary"`
 	SerialNumberRange string `json:"serialNumberRange"`
@@ -50,6 +37,10 @@ type MaterialJSON struct {
 	StockID           string `json:"stockId"`
 	Description       string `json:"description"`
 	Status            string `json:"status"`
+	// The Request a removal fulfils, recorded on its Transaction Logs
+	RequestID int `json:"requestId,omitempty"`
+	// The cost layers to move or remove, required by the specific identification only
+	Layers []storage.Layer `json:"layers,omitempty"`
 }
 
 type RequestedMaterialsJSON struct {
@@ -57,71 +48,43 @@ type RequestedMaterialsJSON struct {
 	UserID    int            `json:"userId"`
 }
 
-type MaterialDB struct {
-	MaterialID        int       `field:"material_id"`
-	WarehouseName     string    `field:"warehouse_name"`
-	StockID           string    `field:"stock_id"`
-	CustomerID        int       `field:"customer_id"`
-	CustomerName      string    `field:"customer_name"`
-	LocationID        int       `field:"location_id"`
-	LocationName      string    `field:"location_name"`
-	MaterialType      string    `field:"material_type"`
-	Description       string    `field:"description"`
-	Notes             string    `field:"notes"`
-	Quantity          int       `field:"quantity"`
-	UpdatedAt         time.Time `field:"updated_at"`
-	IsActive          bool      `field:"is_active"`
-	MinQty            int       `field:"min_required_quantity"`
-	MaxQty            int       `field:"max_required_quantity"`
-	Owner             string    `field:"onwer"`
-	IsPrimary         bool      `field:"is_primary"`
-	SerialNumberRange string    `field:"serial_number_range"`
-	RequestID         int       `field:"request_id"`
-	UserName          string    `field:"username"`
-	Status            string    `field:"status"`
-	QtyRequested      int       `field:"quantity_requested"`
-	QtyUsed           int       `field:"quantity_used"`
-	RequestedAt       time.Time `field:"requested_at"`
-}
-
-type MaterialFilter struct {
-	MaterialId   int
-	StockId      string
-	CustomerName string
-	Description  string
-	LocationName string
-	Status       string
-	RequestId    int
-	RequestedAt  string
-}
-
-type Price struct {
-	priceId    int
+type PriceToRemove struct {
 	materialId int
 	qty        int
-	cost       float64
-}
-
-type PriceToRemove struct {
-	materialId        int
-	qty               int
-	notes             string
-	jobTicket         string
-	serialNumberRange string
+	// The costing method of the Material picks the Prices, the layers are the ones chosen by the User
+	method costingMethod
+	layers []storage.Layer
+	// Template of the Transaction Logs, the Price and the quantity are set per removed Price
+	trx storage.Transaction
 }
 
-type PriceDB struct {
-	PriceID    int     `field:"price_id"`
-	MaterialID int     `field:"material_id"`
-	Qty        int     `field:"quantity"`
-	Cost       float64 `field:"cost"`
+// stockUsers are recorded on the Transaction Logs of a stock change
+type stockUsers struct {
+	userId     int
+	approvedBy int
 }
 
-type TransactionInfo struct {
-	priceId           int       `field:"price_id"`
-	qty               int       `field:"quantity_change"`
-	notes             string    `field:"notes"`
-	jobTicket         string    `field:"job_ticket"`
-	updatedAt         time.Time `field:"updated_at"`
-	serialNumberRange string    `field:"serial_number_range"`
+// HistoryJSON is a Transaction Log of the Material with the quantity and value balances after it
+type HistoryJSON struct {
+	TransactionID     int             `json:"transactionId"`
+	Type              string          `json:"type"`
+	Qty               int             `json:"quantity"`
+	UnitCost          decimal.Decimal `json:"unitCost"`
+	Value             decimal.Decimal `json:"value"`
+	LandedCost        decimal.Decimal `json:"landedCost"`
+	Currency          string          `json:"currency"`
+	QtyBalance        int             `json:"quantityBalance"`
+	ValueBalance      decimal.Decimal `json:"valueBalance"`
+	Notes             string          `json:"notes"`
+	JobTicket         string          `json:"jobTicket"`
+	SerialNumberRange string          `json:"serialNumberRange"`
+	Date              time.Time       `json:"date"`
+	UserID            int             `json:"userId,omitempty"`
+	Username          string          `json:"username,omitempty"`
+	ApprovedBy        int             `json:"approvedBy,omitempty"`
+	FromLocation      string          `json:"fromLocation,omitempty"`
+	ToLocation        string          `json:"toLocation,omitempty"`
+	ShippingID        int             `json:"shippingId,omitempty"`
+	RequestID         int             `json:"requestId,omitempty"`
+	CorrelationID     string          `json:"correlationId,omitempty"`
 }
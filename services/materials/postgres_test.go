@@ -0,0 +1,43 @@
+package materials
+
+import (
+	"context"
+	"inv_app/database"
+	"inv_app/storage"
+	"inv_app/storage/postgres"
+	"os"
+	"testing"
+)
+
+// The Postgres tests run only with TEST_DB_NAME set, the name of a scratch database reached with the DB_* settings.
+// The Migrations are applied to it and the test data is left there under unique names.
+func newPostgresTestEnv(t *testing.T) testEnv {
+	t.Helper()
+	name := os.Getenv("TEST_DB_NAME")
+	if name == "" {
+		t.Skip("TEST_DB_NAME is not set")
+	}
+	t.Setenv("DB_NAME", name)
+
+	db, err := database.ConnectToDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+
+	store := postgres.New(db)
+	suffix := "-" + storage.NewCorrelationID()[:8]
+	userId, err := store.Users().Create(context.Background(), storage.UserDB{Username: "tester" + suffix, Role: "admin", IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return setupTestEnv(t, store, userId, suffix)
+}
+
+// Parallel removals wait for the row locks of each other, none of them takes stock another one took
+func TestRemoveMaterialConcurrentlyInPostgres(t *testing.T) {
+	testRemoveMaterialConcurrently(t, newPostgresTestEnv(t))
+}
@@ -0,0 +1,405 @@
+package materials
+
+import (
+	"context"
+	"fmt"
+	"inv_app/services/audit"
+	"inv_app/services/errs"
+	"inv_app/storage"
+	"slices"
+	"time"
+)
+
+// Reversal: a mistaken removal or move is undone by compensating Transaction Logs, the original ones stay untouched.
+// Every row of the stock change is reversed against the same Price it changed, so the quantity
+// returns to the exact cost layers removePrices took it from. A weighted average Material merges the layers
+// moved into it, so the moved quantity is taken back from its current layers and returns at their cost.
+
+// Types of the Transaction Logs that can be reversed
+var reversibleTypes = []string{storage.TransactionIssue, storage.TransactionMoveOut, storage.TransactionMoveIn}
+
+type ReversalJSON struct {
+	TransactionID int    `json:"transactionId"`
+	ReversedIDs   []int  `json:"reversedIds"`
+	ReversalIDs   []int  `json:"reversalIds"`
+	CorrelationID string `json:"correlationId"`
+}
+
+// The method reverses the stock change the Transaction Log belongs to, for a move both halves are reversed together.
+// Method's Context: Transaction Reversal. All changes are made in one Transaction committed only if no error occurs.
+// It returns errs.ErrConflict if the change cannot or has already been reversed and
+// errs.ErrInsufficientQuantity if the moved stock has since been consumed.
+// Materials under dual control are not restored, the method returns the ID of the pending Operation instead.
+func ReverseTransaction(ctx context.Context, store storage.Store, transactionId int) (ReversalJSON, int, error) {
+	var reversal ReversalJSON
+	var operationId int
+	err := storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		var err error
+		operationId, err = requestReversalApproval(ctx, tx, transactionId)
+		if err != nil || operationId != 0 {
+			return err
+		}
+		reversal, err = reverseTransaction(ctx, tx, transactionId, currentUser(ctx))
+		return err
+	})
+	if err != nil {
+		return ReversalJSON{}, 0, err
+	}
+	return reversal, operationId, nil
+}
+
+// The method creates a pending Operation and returns its ID if the reversed Material type is under dual control,
+// otherwise it returns 0 and the caller reverses at once. A change that cannot be reversed is refused now.
+func requestReversalApproval(ctx context.Context, tx storage.Tx, transactionId int) (int, error) {
+	// The Price is locked after the chain, as by reverseTransaction
+	if err := tx.Transactions().LockChain(ctx); err != nil {
+		return 0, err
+	}
+	trxList, err := reversibleLogs(ctx, tx, transactionId)
+	if err != nil {
+		return 0, err
+	}
+	// The first Transaction Log is the one that took the stock out, its Material gets it back
+	first := trxList[0]
+	price, err := tx.Prices().GetForUpdate(ctx, first.PriceID)
+	if err != nil {
+		return 0, err
+	}
+	material, err := tx.Materials().Get(ctx, price.MaterialID)
+	if err != nil {
+		return 0, err
+	}
+	dualControlTypes, err := tx.Operations().DualControlTypes(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(dualControlTypes, material.MaterialType) {
+		return 0, nil
+	}
+	if err := requireVaultAccess(ctx, material.MaterialType); err != nil {
+		return 0, err
+	}
+
+	qty := 0
+	for _, trx := range trxList {
+		if trx.Qty < 0 {
+			qty -= trx.Qty
+		}
+	}
+	return createOperation(ctx, tx, storage.PendingOperation{
+		Operation:         OperationReverse,
+		MaterialID:        material.MaterialID,
+		StockID:           material.StockID,
+		MaterialType:      material.MaterialType,
+		Qty:               qty,
+		LocationID:        first.FromLocationID,
+		JobTicket:         first.JobTicket,
+		SerialNumberRange: first.SerialNumberRange,
+		RequestID:         first.RequestID,
+		TransactionID:     transactionId,
+	})
+}
+
+// The method returns the Transaction Logs of the stock change,
+// errs.ErrConflict if one of them cannot or has already been reversed.
+func reversibleLogs(ctx context.Context, tx storage.Tx, transactionId int) ([]storage.Transaction, error) {
+	trxList, err := tx.Transactions().ListCorrelated(ctx, transactionId)
+	if err != nil {
+		return nil, err
+	}
+	for _, trx := range trxList {
+		if !slices.Contains(reversibleTypes, trx.Type) {
+			return nil, errs.New(errs.ErrConflict,
+				fmt.Sprintf("A Transaction Log of the %q type cannot be reversed", trx.Type),
+				map[string]int{"transactionId": trx.TransactionID},
+			)
+		}
+		reversalId, err := tx.Transactions().ReversalOf(ctx, trx.TransactionID)
+		if err != nil {
+			return nil, err
+		}
+		if reversalId != 0 {
+			return nil, errs.New(errs.ErrConflict,
+				fmt.Sprintf("The Transaction Log %d is already reversed", trx.TransactionID),
+				map[string]int{"transactionId": trx.TransactionID, "reversalId": reversalId},
+			)
+		}
+	}
+	return trxList, nil
+}
+
+func reverseTransaction(ctx context.Context, tx storage.Tx, transactionId int, by stockUsers) (ReversalJSON, error) {
+	if err := tx.Transactions().LockChain(ctx); err != nil {
+		return ReversalJSON{}, err
+	}
+	trxList, err := reversibleLogs(ctx, tx, transactionId)
+	if err != nil {
+		return ReversalJSON{}, err
+	}
+
+	// 1. Plan the quantity given back to the Prices, the Materials follow in the order of the rows
+	rows, err := planReversal(ctx, tx, trxList)
+	if err != nil {
+		return ReversalJSON{}, err
+	}
+	materialIds := []int{}
+	restoreLocations := make(map[int]int)
+	deltas := make(map[int]int)
+	for _, row := range rows {
+		materialId := row.layer.MaterialID
+		if !slices.Contains(materialIds, materialId) {
+			materialIds = append(materialIds, materialId)
+		}
+		if row.qty > 0 {
+			restoreLocations[materialId] = row.reverses.FromLocationID
+		}
+		deltas[materialId] += row.qty
+	}
+
+	materialsBefore := make(map[int]int)
+	restored := []storage.MaterialDB{}
+	for _, materialId := range materialIds {
+		material, err := tx.Materials().GetForUpdate(ctx, materialId)
+		if err != nil {
+			return ReversalJSON{}, err
+		}
+		if err := requireVaultAccess(ctx, material.MaterialType); err != nil {
+			return ReversalJSON{}, err
+		}
+		materialsBefore[materialId] = material.Quantity
+
+		delta := deltas[materialId]
+		if delta > 0 {
+			restored = append(restored, material)
+		}
+		switch {
+		case delta < 0 && material.Quantity < -delta:
+			return ReversalJSON{}, consumedSince(transactionId, -delta, material.Quantity)
+		case delta < 0 && material.Quantity == -delta:
+			err = tx.Materials().Unplace(ctx, materialId)
+		case delta > 0 && material.LocationID == 0:
+			// The Material was fully removed, so it goes back to the Location it was taken from
+			err = tx.Materials().Place(ctx, materialId, restoreLocations[materialId], delta, material.Notes)
+		case delta > 0 && material.LocationID != restoreLocations[materialId]:
+			return ReversalJSON{}, errs.New(errs.ErrConflict,
+				"The Material has since been placed in another Location",
+				map[string]int{"materialId": materialId, "locationId": material.LocationID},
+			)
+		case delta != 0:
+			err = tx.Materials().AddQuantity(ctx, materialId, delta)
+		}
+		if err != nil {
+			return ReversalJSON{}, err
+		}
+	}
+
+	// 2. Add the compensating Transaction Logs linked to the reversed ones
+	reversal := ReversalJSON{
+		TransactionID: transactionId,
+		ReversedIDs:   []int{},
+		ReversalIDs:   []int{},
+		CorrelationID: storage.NewCorrelationID(),
+	}
+	for _, row := range rows {
+		trx := row.reverses
+		priceId := row.layer.PriceID
+		if priceId == 0 {
+			layer := row.layer
+			layer.Qty, layer.OriginalQty = row.qty, row.qty
+			if priceId, err = tx.Prices().Create(ctx, layer); err != nil {
+				return ReversalJSON{}, err
+			}
+		} else if _, err := tx.Prices().AddQuantity(ctx, priceId, row.qty); err != nil {
+			return ReversalJSON{}, err
+		}
+		// A reversed row may be split over several layers, the first one links it
+		reversesId := 0
+		if !slices.Contains(reversal.ReversedIDs, trx.TransactionID) {
+			reversesId = trx.TransactionID
+			reversal.ReversedIDs = append(reversal.ReversedIDs, trx.TransactionID)
+		}
+		err := tx.Transactions().Add(ctx, storage.Transaction{
+			PriceID:           priceId,
+			Qty:               row.qty,
+			Notes:             fmt.Sprintf("Reversal of Transaction %d", trx.TransactionID),
+			JobTicket:         trx.JobTicket,
+			UpdatedAt:         time.Now(),
+			SerialNumberRange: trx.SerialNumberRange,
+			UserID:            by.userId,
+			ApprovedBy:        by.approvedBy,
+			Type:              storage.TransactionReversal,
+			FromLocationID:    trx.ToLocationID,
+			ToLocationID:      trx.FromLocationID,
+			RequestID:         trx.RequestID,
+			CorrelationID:     reversal.CorrelationID,
+			ReversesID:        reversesId,
+		})
+		if err != nil {
+			return ReversalJSON{}, err
+		}
+	}
+
+	// A weighted average Material gets its restored layers averaged again
+	for _, material := range restored {
+		method, err := costingMethodOf(ctx, tx, material.Owner, material.CustomerID)
+		if err != nil {
+			return ReversalJSON{}, err
+		}
+		err = method.rebalance(ctx, tx, material.MaterialID, storage.Transaction{
+			UserID:       by.userId,
+			ApprovedBy:   by.approvedBy,
+			ToLocationID: restoreLocations[material.MaterialID],
+		})
+		if err != nil {
+			return ReversalJSON{}, err
+		}
+	}
+
+	// The Transaction Log IDs are known once the rows are chained
+	for _, reversedId := range reversal.ReversedIDs {
+		reversalId, err := tx.Transactions().ReversalOf(ctx, reversedId)
+		if err != nil {
+			return ReversalJSON{}, err
+		}
+		reversal.ReversalIDs = append(reversal.ReversalIDs, reversalId)
+	}
+
+	materialsAfter := make(map[int]int)
+	for _, materialId := range materialIds {
+		materialsAfter[materialId] = materialsBefore[materialId] + deltas[materialId]
+	}
+	err = audit.Record(ctx, tx, audit.Change{
+		UserID:   by.userId,
+		Action:   audit.ActionReverse,
+		Entity:   audit.EntityTransaction,
+		EntityID: transactionId,
+		Before:   map[string]any{"transactionIds": reversal.ReversedIDs, "quantities": materialsBefore},
+		After:    map[string]any{"reversalIds": reversal.ReversalIDs, "quantities": materialsAfter, "approvedBy": by.approvedBy},
+	})
+	if err != nil {
+		return ReversalJSON{}, err
+	}
+	return reversal, nil
+}
+
+// A row of the reversal: the quantity given back to (or taken from) the layer, a new layer if its PriceID is 0
+type reversalRow struct {
+	reverses storage.Transaction
+	layer    storage.Price
+	qty      int
+}
+
+// The method plans the reversal rows of the Transaction Logs. The moved-in quantity is taken back first and
+// has to be still there: from the same Price, or through the pick of a weighted average Material from its
+// current layers. The quantity taken from those returns at their cost in new layers keeping the receipt time.
+func planReversal(ctx context.Context, tx storage.Tx, trxList []storage.Transaction) ([]reversalRow, error) {
+	// The locked Prices with the quantity left by the planned rows
+	prices := make(map[int]storage.Price)
+	getPrice := func(priceId int) (storage.Price, error) {
+		if price, ok := prices[priceId]; ok {
+			return price, nil
+		}
+		price, err := tx.Prices().GetForUpdate(ctx, priceId)
+		prices[priceId] = price
+		return price, err
+	}
+
+	rows := []reversalRow{}
+	taken := []storage.Price{}
+	for _, trx := range trxList {
+		if trx.Qty <= 0 {
+			continue
+		}
+		price, err := getPrice(trx.PriceID)
+		if err != nil {
+			return nil, err
+		}
+		material, err := tx.Materials().Get(ctx, price.MaterialID)
+		if err != nil {
+			return nil, err
+		}
+		method, err := costingMethodOf(ctx, tx, material.Owner, material.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		if _, averaged := method.(averageCosting); !averaged {
+			if price.Qty < trx.Qty {
+				return nil, consumedSince(trx.TransactionID, trx.Qty, price.Qty)
+			}
+			price.Qty -= trx.Qty
+			prices[price.PriceID] = price
+			rows = append(rows, reversalRow{reverses: trx, layer: price, qty: -trx.Qty})
+			continue
+		}
+
+		available, err := tx.Prices().ListAvailableForUpdate(ctx, price.MaterialID)
+		if err != nil {
+			return nil, err
+		}
+		left := 0
+		for i := range available {
+			if planned, ok := prices[available[i].PriceID]; ok {
+				available[i].Qty = planned.Qty
+			}
+			left += available[i].Qty
+		}
+		if left < trx.Qty {
+			return nil, consumedSince(trx.TransactionID, trx.Qty, left)
+		}
+		picked, err := method.pick(available, trx.Qty, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, layer := range picked {
+			current, err := getPrice(layer.PriceID)
+			if err != nil {
+				return nil, err
+			}
+			current.Qty -= layer.Qty
+			prices[layer.PriceID] = current
+			rows = append(rows, reversalRow{reverses: trx, layer: layer, qty: -layer.Qty})
+			taken = append(taken, layer)
+		}
+	}
+
+	for _, trx := range trxList {
+		if trx.Qty >= 0 {
+			continue
+		}
+		price, err := getPrice(trx.PriceID)
+		if err != nil {
+			return nil, err
+		}
+		if len(taken) == 0 {
+			rows = append(rows, reversalRow{reverses: trx, layer: price, qty: -trx.Qty})
+			continue
+		}
+		for qty := -trx.Qty; qty > 0 && len(taken) > 0; {
+			n := min(qty, taken[0].Qty)
+			rows = append(rows, reversalRow{
+				reverses: trx,
+				layer: storage.Price{
+					MaterialID: price.MaterialID,
+					Cost:       taken[0].Cost,
+					Currency:   taken[0].Currency,
+					ReceivedAt: price.ReceivedAt,
+					LandedCost: taken[0].LandedCost,
+				},
+				qty: n,
+			})
+			if taken[0].Qty -= n; taken[0].Qty == 0 {
+				taken = taken[1:]
+			}
+			qty -= n
+		}
+	}
+	return rows, nil
+}
+
+// The method returns errs.ErrInsufficientQuantity for a moved quantity that is no longer in the new Location.
+func consumedSince(transactionId int, quantity int, actualQuantity int) error {
+	return errs.New(errs.ErrInsufficientQuantity,
+		fmt.Sprintf("The stock of the Transaction Log %d has since been consumed (%d of %d left)", transactionId, actualQuantity, quantity),
+		map[string]int{"transactionId": transactionId, "requested": quantity, "available": actualQuantity},
+	)
+}
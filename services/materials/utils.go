@@ -1,209 +1,105 @@
 package materials
 
 import (
-	"database/sql"
+	"context"
+	"fmt"
+	"inv_app/services/errs"
+	"inv_app/services/users"
+	"inv_app/storage"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Internal Methods that helps to implement the basic Business Logic.
 
-func getMaterialPrices(tx *sql.Tx, materialId int) (map[int]Price, error) {
-	rows, err := tx.Query(`
-		SELECT * FROM prices
-		WHERE material_id = $1
-		AND quantity > 0
-		ORDER BY price_id ASC;
-	`, materialId)
+// The method takes the quantity out of the Material Prices picked by its costing method,
+// adds a Transaction Log per each changed Price and returns the removed quantities with their cost, currency, receipt time and landed cost.
+func removePrices(ctx context.Context, tx storage.Tx, priceToRemove PriceToRemove) ([]storage.Price, error) {
+	materialPrices, err := tx.Prices().ListAvailableForUpdate(ctx, priceToRemove.materialId)
+	if err != nil {
+		return nil, err
+	}
+	picked, err := priceToRemove.method.pick(materialPrices, priceToRemove.qty, priceToRemove.layers)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	prices := make(map[int]Price)
 
-	for rows.Next() {
-		var price PriceDB
-		err := rows.Scan(&price.PriceID, &price.MaterialID, &price.Qty, &price.Cost)
+	removedPrices := []storage.Price{}
+	for _, priceInfo := range picked {
+		qtyToRemove := priceInfo.Qty
+		cost, err := tx.Prices().AddQuantity(ctx, priceInfo.PriceID, -qtyToRemove)
 		if err != nil {
 			return nil, err
 		}
 
-		prices[price.PriceID] = Price{qty: price.Qty, cost: price.Cost}
-	}
-	return prices, nil
-}
+		trx := priceToRemove.trx
+		trx.PriceID = priceInfo.PriceID
+		trx.Qty = -qtyToRemove
+		trx.UpdatedAt = time.Now()
+		if err := tx.Transactions().Add(ctx, trx); err != nil {
+			return nil, err
+		}
 
-// The internal method changes the Prices quantity and returns updated Prices
-func updatePriceQty(tx *sql.Tx, priceInfo *Price) (float64, error) {
-	var updatedCost float64
-	err := tx.QueryRow(`
-					UPDATE prices
-					SET quantity = (quantity + $2)
-					WHERE price_id = $1
-					RETURNING cost;
-					`, priceInfo.priceId, priceInfo.qty,
-	).Scan(&updatedCost)
-	if err != nil {
-		return 0, err
+		removedPrices = append(removedPrices, storage.Price{
+			Qty:        qtyToRemove,
+			Cost:       cost,
+			Currency:   priceInfo.Currency,
+			ReceivedAt: priceInfo.ReceivedAt,
+			LandedCost: priceInfo.LandedCost,
+		})
 	}
-
-	return updatedCost, nil
+	return removedPrices, nil
 }
 
-func upsertPrice(tx *sql.Tx, priceInfo Price) (int, error) {
-	var priceId int
-	rows, err := tx.Query(`
-					INSERT INTO prices (material_id, quantity, cost)
-						VALUES ($1, $2, $3)
-					ON CONFLICT (material_id, cost)
-						DO UPDATE
-							SET quantity = (prices.quantity + EXCLUDED.quantity)
-					RETURNING price_id;
-					`, priceInfo.materialId, priceInfo.qty, priceInfo.cost,
+// The method returns errs.ErrInsufficientQuantity with the requested and the actual quantity as details.
+func insufficientQuantity(operation string, quantity int, actualQuantity int) error {
+	return errs.New(errs.ErrInsufficientQuantity,
+		fmt.Sprintf("The %s quantity (%d) is more than the actual one (%d)", operation, quantity, actualQuantity),
+		map[string]int{"requested": quantity, "available": actualQuantity},
 	)
-	if err != nil {
-		return 0, err
-	}
-
-	for rows.Next() {
-		err := rows.Scan(&priceId)
-		if err != nil {
-			return 0, err
-		}
-	}
-	return priceId, nil
 }
 
-func addTranscation(trx *TransactionInfo, tx *sql.Tx) error {
-	rows, err := tx.Query(`
-			INSERT INTO transactions_log (
-					price_id, quantity_change, notes, job_ticket, updated_at,
-					serial_number_range
-				)
-			VALUES($1, $2, $3, $4, $5, $6);
-		`, trx.priceId, trx.qty, trx.notes, trx.jobTicket, trx.updatedAt, trx.serialNumberRange)
-	if err != nil {
-		return err
-	}
-	rows.Close()
-	return nil
-}
+// Materials kept in the vault need the vault.manage Permission to be received, moved or removed
+var vaultMaterialTypes = []string{"CARDS", "CHIPS"}
 
-func removePricesFIFO(tx *sql.Tx, priceToRemove PriceToRemove) ([]Price, error) {
-	materialId := priceToRemove.materialId
-	qty := priceToRemove.qty
-	notes := priceToRemove.notes
-	jobTicket := priceToRemove.jobTicket
-
-	materialPrices, err := getMaterialPrices(tx, materialId)
-	if err != nil {
-		return nil, err
-	}
-
-	removedPrices := []Price{}
-
-	remainingQty := qty
-	for priceId, priceInfo := range materialPrices {
-		if remainingQty <= priceInfo.qty {
-			qtyToRemove := remainingQty
-			priceInfo := &Price{
-				priceId: priceId,
-				qty:     -qtyToRemove,
-			}
-
-			cost, err := updatePriceQty(tx, priceInfo)
-			if err != nil {
-				return nil, err
-			}
-
-			err = addTranscation(&TransactionInfo{
-				priceId:           priceId,
-				qty:               -qtyToRemove,
-				notes:             notes,
-				jobTicket:         jobTicket,
-				updatedAt:         time.Now(),
-				serialNumberRange: priceToRemove.serialNumberRange,
-			}, tx)
-			if err != nil {
-				return nil, err
-			}
-
-			remainingQty = 0
-			removedPrices = append(removedPrices, Price{qty: qtyToRemove, cost: cost})
-			break
-		} else {
-			qtyToRemove := priceInfo.qty
-			priceInfo := &Price{
-				priceId: priceId,
-				qty:     -qtyToRemove,
-			}
-
-			cost, err := updatePriceQty(tx, priceInfo)
-			if err != nil {
-				return nil, err
-			}
-
-			err = addTranscation(&TransactionInfo{
-				priceId:           priceId,
-				qty:               -qtyToRemove,
-				notes:             notes,
-				jobTicket:         jobTicket,
-				updatedAt:         time.Now(),
-				serialNumberRange: priceToRemove.serialNumberRange,
-			}, tx)
-			if err != nil {
-				return nil, err
-			}
+// The authenticated User makes the change without an approval
+func currentUser(ctx context.Context) stockUsers {
+	return stockUsers{userId: users.UserID(ctx)}
+}
 
-			remainingQty -= qtyToRemove
-			removedPrices = append(removedPrices, Price{qty: qtyToRemove, cost: cost})
-		}
+func toIncomingJSON(material storage.IncomingMaterialDB) IncomingMaterialJSON {
+	shippingId, _ := strconv.Atoi(material.ShippingID)
+	return IncomingMaterialJSON{
+		ShippingId:   shippingId,
+		CustomerID:   material.CustomerID,
+		StockID:      material.StockID,
+		MaterialType: material.MaterialType,
+		Qty:          material.Quantity,
+		Cost:         material.Cost,
+		Currency:     material.Currency,
+		UnitWeight:   material.UnitWeight,
+		MinQty:       material.MinQty,
+		MaxQty:       material.MaxQty,
+		Description:  material.Description,
+		Owner:        material.Owner,
+		IsActive:     material.IsActive,
+		UserID:       material.UserID,
 	}
-	return removedPrices, nil
 }
 
-// Delete an Incoming Material once it's accepted
-func deleteIncomingMaterial(tx *sql.Tx, shippingId int) error {
-	if _, err := tx.Exec(`
-			DELETE FROM incoming_materials WHERE shipping_id = $1;`,
-		shippingId); err != nil {
-		return err
+// The cost of an Incoming Material without a currency is in the base one
+func incomingCurrency(currency string) string {
+	if currency == "" {
+		return storage.BaseCurrency()
 	}
-
-	return nil
+	return strings.ToUpper(currency)
 }
 
-func getMaterialById(materialId int, tx *sql.Tx) (MaterialDB, error) {
-	var currMaterial MaterialDB
-	err := tx.QueryRow(`SELECT
-							material_id, stock_id, location_id,
-							customer_id, material_type, description, notes,
-							quantity, updated_at,
-							is_active, min_required_quantity, max_required_quantity,
-							owner, is_primary, COALESCE(serial_number_range, '')
-						FROM materials
-						WHERE material_id = $1`,
-		materialId,
-	).Scan(
-		&currMaterial.MaterialID,
-		&currMaterial.StockID,
-		&currMaterial.LocationID,
-		&currMaterial.CustomerID,
-		&currMaterial.MaterialType,
-		&currMaterial.Description,
-		&currMaterial.Notes,
-		&currMaterial.Quantity,
-		&currMaterial.UpdatedAt,
-		&currMaterial.IsActive,
-		&currMaterial.MinQty,
-		&currMaterial.MaxQty,
-		&currMaterial.Owner,
-		&currMaterial.IsPrimary,
-		&currMaterial.SerialNumberRange,
-	)
-	if err != nil {
-		return MaterialDB{}, err
+func requireVaultAccess(ctx context.Context, materialType string) error {
+	if slices.Contains(vaultMaterialTypes, materialType) {
+		return users.Require(ctx, users.PermVaultManage)
 	}
-
-	return currMaterial, nil
+	return nil
 }
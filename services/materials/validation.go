@@ -0,0 +1,108 @@
+package materials
+
+import (
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"strconv"
+)
+
+// Payload checks run by the handlers before the Business Logic is called.
+// Each method returns validation.Errors with all invalid fields or nil.
+
+var owners = []string{"Tag", "Customer"}
+var requestStatuses = []string{"pending", "sent", "declined"}
+
+func ValidateIncoming(material IncomingMaterialJSON) error {
+	errs := validation.Errors{}
+	validateIncomingFields(&errs, material)
+	errs.ID("userId", material.UserID)
+	return errs.Err()
+}
+
+func ValidateIncomingUpdate(material IncomingMaterialJSON) error {
+	errs := validation.Errors{}
+	errs.ID("shippingId", material.ShippingId)
+	validateIncomingFields(&errs, material)
+	return errs.Err()
+}
+
+func validateIncomingFields(errs *validation.Errors, material IncomingMaterialJSON) {
+	errs.ID("customerId", material.CustomerID)
+	errs.Required("stockId", material.StockID)
+	errs.Required("type", material.MaterialType)
+	errs.Positive("quantity", material.Qty)
+	errs.NonNegativeMoney("cost", material.Cost, storage.UnitCostPlaces)
+	if material.Currency != "" {
+		errs.Currency("currency", material.Currency)
+	}
+	errs.NonNegativeMoney("unitWeight", material.UnitWeight, storage.UnitCostPlaces)
+	errs.NonNegative("minQuantity", material.MinQty)
+	errs.NonNegative("maxQuantity", material.MaxQty)
+	errs.Required("description", material.Description)
+	errs.OneOf("owner", material.Owner, owners...)
+}
+
+// The Material ID of a new Material is the Shipping ID of the Incoming one
+func ValidateCreate(material MaterialJSON) error {
+	errs := validation.Errors{}
+	errs.ID("materialId", material.MaterialID)
+	errs.ID("locationId", material.LocationID)
+	errs.Positive("quantity", material.Qty)
+	return errs.Err()
+}
+
+func ValidateMove(material MaterialJSON) error {
+	errs := validation.Errors{}
+	errs.ID("materialId", material.MaterialID)
+	errs.ID("locationId", material.LocationID)
+	errs.Positive("quantity", material.Qty)
+	validateLayers(&errs, material.Layers)
+	return errs.Err()
+}
+
+func ValidateRemove(material MaterialJSON) error {
+	errs := validation.Errors{}
+	errs.ID("materialId", material.MaterialID)
+	errs.Positive("quantity", material.Qty)
+	errs.NonNegative("requestId", material.RequestID)
+	validateLayers(&errs, material.Layers)
+	return errs.Err()
+}
+
+func validateLayers(errs *validation.Errors, layers []storage.Layer) {
+	for i, layer := range layers {
+		field := "layers[" + strconv.Itoa(i) + "]."
+		errs.ID(field+"priceId", layer.PriceID)
+		errs.Positive(field+"quantity", layer.Qty)
+	}
+}
+
+func ValidateUpdate(material MaterialJSON) error {
+	errs := validation.Errors{}
+	errs.ID("materialId", material.MaterialID)
+	return errs.Err()
+}
+
+// Materials with 0 quantity are skipped by RequestMaterials
+func ValidateRequest(materials RequestedMaterialsJSON) error {
+	errs := validation.Errors{}
+	errs.ID("userId", materials.UserID)
+	if len(materials.Materials) == 0 {
+		errs.Add("materials", "is required")
+	}
+	for i, material := range materials.Materials {
+		field := "materials[" + strconv.Itoa(i) + "]."
+		errs.Required(field+"stockId", material.StockID)
+		errs.NonNegative(field+"quantity", material.Qty)
+	}
+	return errs.Err()
+}
+
+// The Material ID of a Requested Material is its Request ID
+func ValidateRequestUpdate(material MaterialJSON) error {
+	errs := validation.Errors{}
+	errs.ID("materialId", material.MaterialID)
+	errs.NonNegative("quantity", material.Qty)
+	errs.OneOf("status", material.Status, requestStatuses...)
+	return errs.Err()
+}
@@ -0,0 +1,88 @@
+package reports
+
+import (
+	"context"
+	"inv_app/services/audit"
+	"inv_app/services/users"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Exchange rates are maintained locally: a rate is the value of one unit of the currency
+// in the base currency, valid from its effective date until the next rate of the currency.
+
+// Decimal places kept of a rate, as NUMERIC(18, 8) in the database
+const ratePlaces = 8
+
+type ExchangeRateJSON struct {
+	Currency      string          `json:"currency"`
+	EffectiveDate string          `json:"effectiveDate"`
+	Rate          decimal.Decimal `json:"rate"`
+}
+
+func ValidateExchangeRate(rate ExchangeRateJSON) error {
+	errs := validation.Errors{}
+	errs.Currency("currency", rate.Currency)
+	if strings.EqualFold(rate.Currency, storage.BaseCurrency()) {
+		errs.Add("currency", "must not be the base currency "+storage.BaseCurrency())
+	}
+	errs.Required("effectiveDate", rate.EffectiveDate)
+	errs.Date("effectiveDate", rate.EffectiveDate)
+	if !rate.Rate.IsPositive() {
+		errs.Add("rate", "must be greater than 0")
+	} else if !rate.Rate.Equal(rate.Rate.Round(ratePlaces)) {
+		errs.Add("rate", "must have at most 8 decimal places")
+	}
+	return errs.Err()
+}
+
+// The method returns the Page of the rates of the currency (all if empty), the latest first, and the total number of them.
+func FetchExchangeRates(ctx context.Context, store storage.Store, currency string, page storage.Page) ([]ExchangeRateJSON, int, error) {
+	rates, total, err := store.ExchangeRates().List(ctx, strings.ToUpper(currency), page)
+	if err != nil {
+		return nil, 0, err
+	}
+	ratesJSON := make([]ExchangeRateJSON, 0, len(rates))
+	for _, rate := range rates {
+		ratesJSON = append(ratesJSON, ExchangeRateJSON(rate))
+	}
+	return ratesJSON, total, nil
+}
+
+// The method adds the rate of the currency from the effective date or replaces the one of the same date.
+// The reports use the new rate at once, also for the dates already reported.
+func SetExchangeRate(ctx context.Context, store storage.Store, rate ExchangeRateJSON) error {
+	rate.Currency = strings.ToUpper(rate.Currency)
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		rates, _, err := tx.ExchangeRates().List(ctx, rate.Currency, storage.Page{})
+		if err != nil {
+			return err
+		}
+		var before any
+		for _, other := range rates {
+			if other.EffectiveDate == rate.EffectiveDate {
+				before = ExchangeRateJSON(other)
+			}
+		}
+
+		if err := tx.ExchangeRates().Upsert(ctx, storage.ExchangeRate(rate)); err != nil {
+			return err
+		}
+
+		action := audit.ActionCreate
+		if before != nil {
+			action = audit.ActionUpdate
+		}
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   users.UserID(ctx),
+			Action:   action,
+			Entity:   audit.EntityRate,
+			EntityID: rate.Currency + "/" + rate.EffectiveDate,
+			Before:   before,
+			After:    rate,
+		})
+	})
+}
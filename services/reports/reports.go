@@ -1,55 +1,42 @@
 package reports
 
 import (
-	"database/sql"
+	"context"
+	"inv_app/services/users"
+	"inv_app/services/validation"
+	"inv_app/storage"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/leekchan/accounting"
+	"github.com/shopspring/decimal"
 )
 
-type Transaction struct {
-	StockID           string    `field:"stock_id"`
-	Description       string    `field:"description"`
-	LocationName      string    `field:"location_name"`
-	MaterialType      string    `field:"material_type"`
-	Qty               int       `field:"quantity"`
-	UnitCost          float64   `field:"unit_cost"`
-	Cost              float64   `field:"cost"`
-	UpdatedAt         time.Time `field:"updated_at"`
-	TotalValue        float64   `field:"total_value"`
-	SerialNumberRange string    `field:"serial_number_range"`
-}
-
-type SearchQuery struct {
-	CustomerId   int
-	Owner        string
-	MaterialType string
-	DateFrom     string
-	DateTo       string
-	DateAsOf     string
-}
-
 type Report struct {
-	DB *sql.DB
+	Store storage.Store
+	Page  storage.Page
 }
 
 type TransactionReport struct {
 	Report
-	TrxFilter SearchQuery
+	TrxFilter storage.ReportFilter
 }
 
 type BalanceReport struct {
 	Report
-	BlcFilter SearchQuery
+	BlcFilter storage.ReportFilter
 }
 
 type TransactionRep struct {
 	StockID           string
 	MaterialType      string
+	Type              string
 	Qty               string
 	UnitCost          string
 	Cost              string
+	LandedCost        string
+	Currency          string
 	Date              string
 	SerialNumberRange string
 }
@@ -60,125 +47,113 @@ type BalanceRep struct {
 	MaterialType string
 	Qty          string
 	TotalValue   string
+	LandedValue  string
+	Currency     string
+}
+
+// Symbols of the common currencies, the other ones are prefixed with their code
+var currencySymbols = map[string]string{"USD": "$", "EUR": "€", "GBP": "£"}
+
+// Unit costs are formatted with storage.UnitCostPlaces, the values with storage.ValuePlaces they are rounded to
+func formatMoney(value decimal.Decimal, currency string, places int) string {
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		symbol = currency + " "
+	}
+	accLib := accounting.Accounting{Symbol: symbol, Precision: places}
+	return accLib.FormatMoneyDecimal(value)
+}
+
+// The method sets the rate date of a converted report, the given date or today,
+// and fails if a currency in stock has no rate effective on it.
+func convertedFilter(ctx context.Context, store storage.Store, filter storage.ReportFilter, date string) (storage.ReportFilter, error) {
+	if filter.Currency == "" {
+		return filter, nil
+	}
+	if filter.RateDate == "" {
+		filter.RateDate = date
+	}
+	if filter.RateDate == "" {
+		filter.RateDate = time.Now().Format(time.DateOnly)
+	}
+
+	missing, err := store.ExchangeRates().Missing(ctx, filter.Currency, filter.RateDate)
+	if err != nil {
+		return filter, err
+	}
+	if len(missing) > 0 {
+		errList := validation.Errors{}
+		errList.Add("rateDate", "no exchange rate of "+strings.Join(missing, ", ")+" effective on "+filter.RateDate)
+		return filter, errList.Err()
+	}
+	return filter, nil
 }
 
-var accLib accounting.Accounting = accounting.Accounting{Symbol: "$", Precision: 4}
-
-func (t TransactionReport) GetReportList() ([]TransactionRep, error) {
-	rows, err := t.DB.Query(`SELECT
-								m.stock_id,
-								m.material_type,
-								tl.quantity_change as "quantity",
-								p.cost as "unit_cost",
-								(tl.quantity_change * p.cost) as "cost",
-								tl.updated_at,
-								COALESCE(tl.serial_number_range, '')
-							 FROM transactions_log tl
-							 LEFT JOIN prices p ON p.price_id = tl.price_id
-							 LEFT JOIN materials m ON m.material_id = p.material_id
-							 LEFT JOIN customers c ON m.customer_id = c.customer_id
-							 WHERE 
-								($1 = 0 OR m.customer_id = $1) AND
-								($2 = '' OR m.material_type::TEXT = $2) AND
-								($3 = '' OR tl.updated_at::TEXT >= $3) AND
-								($4 = '' OR tl.updated_at::TEXT <= $4) AND
-								($5 = '' OR m.owner::TEXT = $5)
-							 ORDER BY transaction_id;`,
-		t.TrxFilter.CustomerId, t.TrxFilter.MaterialType, t.TrxFilter.DateFrom, t.TrxFilter.DateTo, t.TrxFilter.Owner)
+// The method returns the Page of the report rows and the total number of rows.
+func (t TransactionReport) GetReportList(ctx context.Context) ([]TransactionRep, int, error) {
+	filter, err := convertedFilter(ctx, t.Store, t.TrxFilter, t.TrxFilter.DateTo)
 	if err != nil {
-		return []TransactionRep{}, err
+		return []TransactionRep{}, 0, err
+	}
+	filter.CustomerIDs = users.CustomerScope(ctx)
+	transactions, total, err := t.Store.Transactions().Report(ctx, filter, t.Page)
+	if err != nil {
+		return []TransactionRep{}, 0, err
 	}
 
 	trxList := []TransactionRep{}
 
-	for rows.Next() {
-		trx := Transaction{}
-
-		err := rows.Scan(
-			&trx.StockID,
-			&trx.MaterialType,
-			&trx.Qty,
-			&trx.UnitCost,
-			&trx.Cost,
-			&trx.UpdatedAt,
-			&trx.SerialNumberRange,
-		)
-		if err != nil {
-			return []TransactionRep{}, err
-		}
-
+	for _, trx := range transactions {
 		year, month, day := trx.UpdatedAt.Date()
 		strDate := strconv.Itoa(int(month)) + "/" +
 			strconv.Itoa(day) + "/" +
 			strconv.Itoa(year)
-		unitCost := accLib.FormatMoney(trx.UnitCost)
-		cost := accLib.FormatMoney(trx.Cost)
+		unitCost := formatMoney(trx.UnitCost, trx.Currency, storage.UnitCostPlaces)
+		cost := formatMoney(trx.Cost, trx.Currency, storage.ValuePlaces)
 
 		trxList = append(trxList, TransactionRep{
 			StockID:           trx.StockID,
 			MaterialType:      trx.MaterialType,
+			Type:              trx.Type,
 			Qty:               strconv.Itoa(trx.Qty),
 			UnitCost:          unitCost,
 			Cost:              cost,
+			LandedCost:        formatMoney(trx.LandedCost, trx.Currency, storage.ValuePlaces),
+			Currency:          trx.Currency,
 			Date:              strDate,
 			SerialNumberRange: trx.SerialNumberRange,
 		})
 	}
 
-	return trxList, nil
+	return trxList, total, nil
 }
 
-func (b BalanceReport) GetReportList() ([]BalanceRep, error) {
-	rows, err := b.DB.Query(`
-		SELECT m.stock_id,
-			m.description,
-			m.material_type,
-			SUM(tl.quantity_change) AS "quantity",
-			SUM(tl.quantity_change * p.cost) AS "total_value"
-		FROM transactions_log tl
-		LEFT JOIN prices p ON p.price_id = tl.price_id
-		LEFT JOIN materials m ON m.material_id = p.material_id
-		WHERE
-			($1 = 0 OR m.customer_id = $1) AND
-			($2 = '' OR m.material_type::TEXT = $2) AND
-			($3 = '' OR tl.updated_at::TEXT <= $3) AND
-			($4 = '' OR m.owner::TEXT = $4) AND
-			m.location_id IS NOT NULL
-		GROUP BY m.stock_id, m.description, m.material_type
-		ORDER BY m.material_type ASC, m.description ASC;
-`,
-		b.BlcFilter.CustomerId, b.BlcFilter.MaterialType, b.BlcFilter.DateAsOf, b.BlcFilter.Owner,
-	)
+// The method returns the Page of the report rows and the total number of rows.
+func (b BalanceReport) GetReportList(ctx context.Context) ([]BalanceRep, int, error) {
+	filter, err := convertedFilter(ctx, b.Store, b.BlcFilter, b.BlcFilter.DateAsOf)
 	if err != nil {
-		return []BalanceRep{}, err
+		return []BalanceRep{}, 0, err
+	}
+	filter.CustomerIDs = users.CustomerScope(ctx)
+	balances, total, err := b.Store.Transactions().Balance(ctx, filter, b.Page)
+	if err != nil {
+		return []BalanceRep{}, 0, err
 	}
 
 	blcList := []BalanceRep{}
 
-	for rows.Next() {
-		balance := Transaction{}
-
-		err := rows.Scan(
-			&balance.StockID,
-			&balance.Description,
-			&balance.MaterialType,
-			&balance.Qty,
-			&balance.TotalValue,
-		)
-		if err != nil {
-			return []BalanceRep{}, err
-		}
-
-		totalValue := accLib.FormatMoney(balance.TotalValue)
+	for _, balance := range balances {
+		totalValue := formatMoney(balance.TotalValue, balance.Currency, storage.ValuePlaces)
 		blcList = append(blcList, BalanceRep{
 			StockID:      balance.StockID,
 			Description:  balance.Description,
 			MaterialType: balance.MaterialType,
 			Qty:          strconv.Itoa(balance.Qty),
 			TotalValue:   totalValue,
+			LandedValue:  formatMoney(balance.LandedValue, balance.Currency, storage.ValuePlaces),
+			Currency:     balance.Currency,
 		})
 	}
 
-	return blcList, err
-
+	return blcList, total, nil
 }
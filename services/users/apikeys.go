@@ -0,0 +1,171 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"inv_app/services/audit"
+	"inv_app/services/errs"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"log"
+	"time"
+)
+
+// API keys let service accounts (scanners, scripts) call the API without a login.
+// A key is sent as "Authorization: ApiKey <key>" and is shown only once, when it is created.
+
+const apiKeyPrefix = "ik_"
+
+// The last use of a key is written at most once per interval, not on every request
+const lastUsedInterval = time.Minute
+
+type APIKeyJSON struct {
+	KeyID      int        `json:"keyId"`
+	UserID     int        `json:"userId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	CreatedBy  int        `json:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	// The key itself, returned by CreateAPIKey only
+	Key string `json:"key,omitempty"`
+}
+
+// NewAPIKeyJSON names a new key, a key without ExpiresAt does not expire
+type NewAPIKeyJSON struct {
+	Name      string     `json:"name"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+func ValidateAPIKey(key NewAPIKeyJSON) error {
+	errs := validation.Errors{}
+	errs.Required("name", key.Name)
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now()) {
+		errs.Add("expiresAt", "must be in the future")
+	}
+	return errs.Err()
+}
+
+// The method creates a key of the service account and returns it with the key itself.
+// Only its hash is stored, so a lost key cannot be shown again and has to be replaced.
+func CreateAPIKey(ctx context.Context, store storage.Store, userId int, newKey NewAPIKeyJSON) (APIKeyJSON, error) {
+	creator, ok := FromContext(ctx)
+	if !ok {
+		return APIKeyJSON{}, errs.New(errs.ErrUnauthorized, "Authentication required", nil)
+	}
+
+	user, err := store.Users().Get(ctx, userId)
+	if err != nil {
+		return APIKeyJSON{}, err
+	}
+	if !user.IsService {
+		return APIKeyJSON{}, validation.Errors{{Field: "userId", Message: "must be a service account"}}
+	}
+
+	keyBytes := make([]byte, 32)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return APIKeyJSON{}, err
+	}
+	secret := apiKeyPrefix + hex.EncodeToString(keyBytes)
+
+	key := storage.APIKey{
+		UserID:    userId,
+		Name:      newKey.Name,
+		Prefix:    secret[:len(apiKeyPrefix)+8],
+		KeyHash:   hashToken(secret),
+		CreatedBy: creator.UserID,
+		CreatedAt: time.Now(),
+		ExpiresAt: newKey.ExpiresAt,
+	}
+	err = storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		var err error
+		key.KeyID, err = tx.APIKeys().Create(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   creator.UserID,
+			Action:   audit.ActionCreate,
+			Entity:   audit.EntityAPIKey,
+			EntityID: key.KeyID,
+			After:    toAPIKeyJSON(key),
+		})
+	})
+	if err != nil {
+		return APIKeyJSON{}, err
+	}
+
+	keyJSON := toAPIKeyJSON(key)
+	keyJSON.Key = secret
+	return keyJSON, nil
+}
+
+func FetchAPIKeys(ctx context.Context, store storage.Store, userId int) ([]APIKeyJSON, error) {
+	if _, err := store.Users().Get(ctx, userId); err != nil {
+		return nil, err
+	}
+	list, err := store.APIKeys().List(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]APIKeyJSON, 0, len(list))
+	for _, key := range list {
+		keys = append(keys, toAPIKeyJSON(key))
+	}
+	return keys, nil
+}
+
+func RevokeAPIKey(ctx context.Context, store storage.Store, keyId int) error {
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		revokedAt := time.Now()
+		if err := tx.APIKeys().Revoke(ctx, keyId, revokedAt); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   UserID(ctx),
+			Action:   audit.ActionRevoke,
+			Entity:   audit.EntityAPIKey,
+			EntityID: keyId,
+			After:    map[string]time.Time{"revokedAt": revokedAt},
+		})
+	})
+}
+
+// The method returns the service account of the key with the Permissions of its Role.
+func AuthenticateAPIKey(ctx context.Context, store storage.Store, secret string) (UserJSON, error) {
+	now := time.Now()
+	key, user, err := store.APIKeys().GetUser(ctx, hashToken(secret), now)
+	if err == storage.ErrNotFound {
+		return UserJSON{}, errs.New(errs.ErrUnauthorized, "Invalid, expired or revoked API key", nil)
+	}
+	if err != nil {
+		return UserJSON{}, err
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedInterval {
+		if err := store.APIKeys().SetLastUsed(ctx, key.KeyID, now); err != nil {
+			log.Println("Error updating the API key last use: ", err)
+		}
+	}
+	return withPermissions(ctx, store, user)
+}
+
+func toAPIKeyJSON(key storage.APIKey) APIKeyJSON {
+	return APIKeyJSON{
+		KeyID:      key.KeyID,
+		UserID:     key.UserID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		CreatedBy:  key.CreatedBy,
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
@@ -0,0 +1,22 @@
+package users
+
+import "context"
+
+type userKey struct{}
+
+// The method returns a Context keeping the authenticated User of the request.
+func WithUser(ctx context.Context, user UserJSON) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// The method returns the authenticated User of the request, false if there is none.
+func FromContext(ctx context.Context) (UserJSON, bool) {
+	user, ok := ctx.Value(userKey{}).(UserJSON)
+	return user, ok
+}
+
+// The method returns the ID of the authenticated User, 0 if there is none.
+func UserID(ctx context.Context) int {
+	user, _ := FromContext(ctx)
+	return user.UserID
+}
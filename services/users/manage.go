@@ -0,0 +1,215 @@
+package users
+
+import (
+	"context"
+	"inv_app/services/audit"
+	"inv_app/services/errs"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"slices"
+)
+
+// UpdateUserJSON changes the Role and/or the active state of a User, omitted fields are kept
+type UpdateUserJSON struct {
+	Role     *string `json:"role,omitempty"`
+	IsActive *bool   `json:"isActive,omitempty"`
+}
+
+// PasswordJSON sets a new password, the current one is required when Users change their own
+type PasswordJSON struct {
+	CurrentPassword string `json:"currentPassword,omitempty"`
+	Password        string `json:"password"`
+}
+
+func FetchUsers(ctx context.Context, store storage.Store, page storage.Page) ([]UserJSON, int, error) {
+	list, total, err := store.Users().List(ctx, page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users := make([]UserJSON, 0, len(list))
+	for _, user := range list {
+		users = append(users, toUserJSON(user))
+	}
+	return users, total, nil
+}
+
+// The method creates an active User with the hash of the initial password and returns its ID.
+// Service accounts get no password.
+func CreateUser(ctx context.Context, store storage.Store, user UserJSON) (int, error) {
+	var passwordHash string
+	var err error
+	if !user.IsService {
+		passwordHash, err = HashPassword(user.Password)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var userId int
+	err = storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		_, err := tx.Users().FindByUsername(ctx, user.Username)
+		if err == nil {
+			return errs.New(errs.ErrConflict, "Username already exists", map[string]string{"username": user.Username})
+		}
+		if err != storage.ErrNotFound {
+			return err
+		}
+		if err := checkRole(ctx, tx, user.Role); err != nil {
+			return err
+		}
+
+		newUser := storage.UserDB{
+			Username:  user.Username,
+			Password:  passwordHash,
+			Role:      user.Role,
+			IsActive:  true,
+			IsService: user.IsService,
+		}
+		userId, err = tx.Users().Create(ctx, newUser)
+		if err != nil {
+			return err
+		}
+		newUser.UserID = userId
+
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   UserID(ctx),
+			Action:   audit.ActionCreate,
+			Entity:   audit.EntityUser,
+			EntityID: userId,
+			After:    toUserJSON(newUser),
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
+
+// The method changes the Role and/or the active state of the User.
+// A deactivated User keeps the history but cannot log in and loses the open Sessions.
+// Users cannot deactivate themselves, so there is always an admin left to reactivate them.
+func UpdateUser(ctx context.Context, store storage.Store, userId int, user UpdateUserJSON) error {
+	if current, ok := FromContext(ctx); ok && current.UserID == userId && user.IsActive != nil && !*user.IsActive {
+		return validation.Errors{{Field: "isActive", Message: "cannot deactivate the current user"}}
+	}
+
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		before, err := tx.Users().Get(ctx, userId)
+		if err != nil {
+			return err
+		}
+		after := before
+
+		if user.Role != nil {
+			if err := checkRole(ctx, tx, *user.Role); err != nil {
+				return err
+			}
+			if err := tx.Users().SetRole(ctx, userId, *user.Role); err != nil {
+				return err
+			}
+			after.Role = *user.Role
+		}
+
+		if user.IsActive != nil {
+			if err := tx.Users().SetActive(ctx, userId, *user.IsActive); err != nil {
+				return err
+			}
+			if !*user.IsActive {
+				if err := tx.Sessions().DeleteForUser(ctx, userId, ""); err != nil {
+					return err
+				}
+			}
+			after.IsActive = *user.IsActive
+		}
+
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   UserID(ctx),
+			Action:   audit.ActionUpdate,
+			Entity:   audit.EntityUser,
+			EntityID: userId,
+			Before:   toUserJSON(before),
+			After:    toUserJSON(after),
+		})
+	})
+}
+
+// The method sets the password chosen by an admin and closes all Sessions of the User.
+func ResetPassword(ctx context.Context, store storage.Store, userId int, password string) error {
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		user, err := tx.Users().Get(ctx, userId)
+		if err != nil {
+			return err
+		}
+		if user.IsService {
+			return validation.Errors{{Field: "password", Message: "cannot be set for a service account"}}
+		}
+
+		if err := tx.Users().SetPassword(ctx, userId, passwordHash); err != nil {
+			return err
+		}
+		if err := tx.Sessions().DeleteForUser(ctx, userId, ""); err != nil {
+			return err
+		}
+		return recordPassword(ctx, tx, userId)
+	})
+}
+
+// The method changes the password of the authenticated User after checking the current one.
+// The other Sessions of the User are closed, the one of the token stays open.
+func ChangePassword(ctx context.Context, store storage.Store, token string, password PasswordJSON) error {
+	current, ok := FromContext(ctx)
+	if !ok {
+		return errs.New(errs.ErrUnauthorized, "Authentication required", nil)
+	}
+
+	user, err := store.Users().Get(ctx, current.UserID)
+	if err != nil {
+		return err
+	}
+	if matches, _ := checkPassword(user.Password, password.CurrentPassword); !matches {
+		return validation.Errors{{Field: "currentPassword", Message: "is wrong"}}
+	}
+
+	passwordHash, err := HashPassword(password.Password)
+	if err != nil {
+		return err
+	}
+
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		if err := tx.Users().SetPassword(ctx, user.UserID, passwordHash); err != nil {
+			return err
+		}
+		if err := tx.Sessions().DeleteForUser(ctx, user.UserID, hashToken(token)); err != nil {
+			return err
+		}
+		return recordPassword(ctx, tx, user.UserID)
+	})
+}
+
+// Only the fact of a new password is audited, never the password or its hash
+func recordPassword(ctx context.Context, tx storage.Tx, userId int) error {
+	return audit.Record(ctx, tx, audit.Change{
+		UserID:   UserID(ctx),
+		Action:   audit.ActionSetPassword,
+		Entity:   audit.EntityUser,
+		EntityID: userId,
+	})
+}
+
+// Unknown Roles are rejected as invalid input rather than as a foreign key conflict
+func checkRole(ctx context.Context, tx storage.Tx, role string) error {
+	roles, err := tx.Roles().List(ctx)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(roles, func(r storage.RoleDB) bool { return r.Name == role }) {
+		return validation.Errors{{Field: "role", Message: "unknown role: " + role}}
+	}
+	return nil
+}
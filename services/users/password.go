@@ -0,0 +1,40 @@
+package users
+
+import (
+	"crypto/subtle"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The hash compared with the passwords of unknown usernames
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("dummy password")
+	return hash
+})
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// The method compares the password with the stored one, which is either a bcrypt hash or a legacy plaintext.
+// A matching plaintext one needs to be replaced by its hash. Nothing matches an empty one (service accounts).
+func checkPassword(stored string, password string) (matches bool, needsUpgrade bool) {
+	if stored == "" {
+		return false, false
+	}
+	if isBcryptHash(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil, false
+	}
+	matches = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+	return matches, matches
+}
+
+func isBcryptHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
@@ -0,0 +1,94 @@
+package users
+
+import (
+	"context"
+	"inv_app/services/audit"
+	"inv_app/services/errs"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"slices"
+)
+
+// Permissions are granted to the Roles in the role_permissions table
+const (
+	PermMaterialsRead       = "materials.read"
+	PermReportsRead         = "reports.read"
+	PermIncomingCreate      = "incoming.create"
+	PermIncomingUpdate      = "incoming.update"
+	PermMaterialsReceive    = "materials.receive"
+	PermMaterialsMove       = "materials.move"
+	PermMaterialsRemove     = "materials.remove"
+	PermMaterialsUpdate     = "materials.update"
+	PermVaultManage         = "vault.manage"
+	PermRequestsCreate      = "requests.create"
+	PermRequestsUpdate      = "requests.update"
+	PermCustomersCreate     = "customers.create"
+	PermWarehousesCreate    = "warehouses.create"
+	PermDataImport          = "data.import"
+	PermRolesManage         = "roles.manage"
+	PermUsersManage         = "users.manage"
+	PermAuditRead           = "audit.read"
+	PermTransactionsReverse = "transactions.reverse"
+	PermCostingManage       = "costing.manage"
+	PermRatesManage         = "rates.manage"
+)
+
+// The method returns errs.ErrForbidden naming the Permission if the authenticated User does not have it.
+func Require(ctx context.Context, permission string) error {
+	user, ok := FromContext(ctx)
+	if !ok {
+		return errs.New(errs.ErrUnauthorized, "Authentication required", nil)
+	}
+	if !slices.Contains(user.Permissions, permission) {
+		return errs.New(errs.ErrForbidden,
+			"Missing permission: "+permission,
+			map[string]string{"permission": permission},
+		)
+	}
+	return nil
+}
+
+func FetchRoles(ctx context.Context, store storage.Store) ([]storage.RoleDB, error) {
+	return store.Roles().List(ctx)
+}
+
+func FetchPermissions(ctx context.Context, store storage.Store) ([]storage.PermissionDB, error) {
+	return store.Roles().ListPermissions(ctx)
+}
+
+// The method replaces the Permissions of the Role, unknown Permissions are rejected.
+func SetRolePermissions(ctx context.Context, store storage.Store, role string, permissions []string) error {
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		known, err := tx.Roles().ListPermissions(ctx)
+		if err != nil {
+			return err
+		}
+
+		errList := validation.Errors{}
+		for _, permission := range permissions {
+			if !slices.ContainsFunc(known, func(p storage.PermissionDB) bool { return p.Name == permission }) {
+				errList.Add("permissions", "unknown permission: "+permission)
+			}
+		}
+		if err := errList.Err(); err != nil {
+			return err
+		}
+
+		before, err := tx.Roles().Permissions(ctx, role)
+		if err != nil {
+			return err
+		}
+		if err := tx.Roles().SetPermissions(ctx, role, permissions); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   UserID(ctx),
+			Action:   audit.ActionUpdate,
+			Entity:   audit.EntityRole,
+			EntityID: role,
+			Before:   map[string][]string{"permissions": before},
+			After:    map[string][]string{"permissions": permissions},
+		})
+	})
+}
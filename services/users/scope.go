@@ -0,0 +1,94 @@
+package users
+
+import (
+	"context"
+	"inv_app/services/audit"
+	"inv_app/services/validation"
+	"inv_app/storage"
+	"slices"
+	"strconv"
+)
+
+// Users of a customer scoped Role (the customer portal) are bound to one or more Customers.
+// The services filter every list and report by CustomerScope, and the Users keep only the
+// read Permissions of their Role, so they cannot change anything whatever the Role grants.
+
+var customerPermissions = []string{PermMaterialsRead, PermReportsRead}
+
+// The method returns the Customer IDs the authenticated User is limited to,
+// nil if the User sees all Customers. An empty list matches no rows.
+func CustomerScope(ctx context.Context) []int {
+	user, ok := FromContext(ctx)
+	if !ok || !user.CustomerScoped {
+		return nil
+	}
+	if user.CustomerIDs == nil {
+		return []int{}
+	}
+	return user.CustomerIDs
+}
+
+func withCustomerScope(ctx context.Context, store storage.Store, user UserJSON) (UserJSON, error) {
+	scoped, err := store.Roles().IsCustomerScoped(ctx, user.Role)
+	if err != nil || !scoped {
+		return user, err
+	}
+
+	user.CustomerScoped = true
+	user.CustomerIDs, err = store.Users().CustomerIDs(ctx, user.UserID)
+	if err != nil {
+		return UserJSON{}, err
+	}
+	user.Permissions = slices.DeleteFunc(user.Permissions, func(permission string) bool {
+		return !slices.Contains(customerPermissions, permission)
+	})
+	return user, nil
+}
+
+func FetchUserCustomers(ctx context.Context, store storage.Store, userId int) ([]int, error) {
+	if _, err := store.Users().Get(ctx, userId); err != nil {
+		return nil, err
+	}
+	return store.Users().CustomerIDs(ctx, userId)
+}
+
+// The method replaces the Customers the User is bound to, unknown Customers are rejected.
+// The Customers only limit the Users of a customer scoped Role.
+func SetUserCustomers(ctx context.Context, store storage.Store, userId int, customerIds []int) error {
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		if _, err := tx.Users().Get(ctx, userId); err != nil {
+			return err
+		}
+
+		customers, _, err := tx.Customers().List(ctx, storage.CustomerFilter{CustomerIDs: customerIds}, storage.Page{})
+		if err != nil {
+			return err
+		}
+		errList := validation.Errors{}
+		for _, customerId := range customerIds {
+			if !slices.ContainsFunc(customers, func(c storage.CustomerDB) bool { return c.ID == customerId }) {
+				errList.Add("customerIds", "unknown customer: "+strconv.Itoa(customerId))
+			}
+		}
+		if err := errList.Err(); err != nil {
+			return err
+		}
+
+		before, err := tx.Users().CustomerIDs(ctx, userId)
+		if err != nil {
+			return err
+		}
+		if err := tx.Users().SetCustomers(ctx, userId, customerIds); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   UserID(ctx),
+			Action:   audit.ActionUpdate,
+			Entity:   audit.EntityUser,
+			EntityID: userId,
+			Before:   map[string][]int{"customerIds": before},
+			After:    map[string][]int{"customerIds": customerIds},
+		})
+	})
+}
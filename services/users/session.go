@@ -0,0 +1,55 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"inv_app/storage"
+	"log"
+	"os"
+	"time"
+)
+
+const defaultSessionTTL = 12 * time.Hour
+
+// The Session lifetime is set by the SESSION_TTL env (e.g. "8h")
+func sessionTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("SESSION_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultSessionTTL
+	}
+	return ttl
+}
+
+// The method stores a new Session of the User and returns its token.
+// Only the token hash is stored, so a leaked DB does not give access to the Sessions.
+func createSession(ctx context.Context, store storage.Store, userId int) (string, time.Time, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	now := time.Now()
+	expiresAt := now.Add(sessionTTL())
+	err := store.Sessions().Create(ctx, storage.Session{
+		TokenHash: hashToken(token),
+		UserID:    userId,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if err := store.Sessions().DeleteExpired(ctx, now); err != nil {
+		log.Println("Error deleting expired sessions: ", err)
+	}
+	return token, expiresAt, nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
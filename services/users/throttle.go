@@ -0,0 +1,186 @@
+package users
+
+import (
+	"context"
+	"inv_app/services/audit"
+	"inv_app/services/errs"
+	"inv_app/storage"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Failed logins are counted per username and per IP address. Each failure makes the key wait
+// twice as long as the previous one (LOGIN_BACKOFF, default 1s), and after LOGIN_MAX_FAILURES
+// (default 5, an IP address gets LOGIN_MAX_IP_FAILURES, default 20) the key is locked for
+// LOGIN_LOCKOUT (default 15m). Failures older than the lockout are forgotten.
+
+// Reasons of the login history rows
+const (
+	LoginSucceeded   = "success"
+	LoginWrongCreds  = "invalid_credentials"
+	LoginDeactivated = "deactivated"
+	LoginThrottled   = "throttled"
+)
+
+type loginPolicy struct {
+	maxFailures   int
+	maxIPFailures int
+	backoff       time.Duration
+	lockout       time.Duration
+}
+
+func loginLimits() loginPolicy {
+	return loginPolicy{
+		maxFailures:   envInt("LOGIN_MAX_FAILURES", 5),
+		maxIPFailures: envInt("LOGIN_MAX_IP_FAILURES", 20),
+		backoff:       envDuration("LOGIN_BACKOFF", time.Second),
+		lockout:       envDuration("LOGIN_LOCKOUT", 15*time.Minute),
+	}
+}
+
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// ThrottleDetails tells a throttled client how many seconds to wait before the next login
+type ThrottleDetails struct {
+	RetryAfter int `json:"retryAfter"`
+}
+
+type loginKey struct {
+	key         string
+	maxFailures int
+}
+
+func loginKeys(policy loginPolicy, username string, ipAddress string) []loginKey {
+	return []loginKey{
+		{key: "user:" + username, maxFailures: policy.maxFailures},
+		{key: "ip:" + ipAddress, maxFailures: policy.maxIPFailures},
+	}
+}
+
+// The method returns errs.ErrTooManyRequests if any of the keys is still waiting or locked.
+func checkThrottles(ctx context.Context, store storage.Store, keys []loginKey, now time.Time) error {
+	var wait time.Duration
+	for _, key := range keys {
+		throttle, err := store.Logins().GetThrottle(ctx, key.key)
+		if err == storage.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		wait = max(wait, throttle.LockedUntil.Sub(now))
+	}
+	if wait <= 0 {
+		return nil
+	}
+	return errs.New(errs.ErrTooManyRequests,
+		"Too many failed logins, try again later",
+		ThrottleDetails{RetryAfter: int(math.Ceil(wait.Seconds()))},
+	)
+}
+
+// The method counts a failed login of the keys and sets how long they have to wait.
+func recordFailure(ctx context.Context, store storage.Store, policy loginPolicy, keys []loginKey, now time.Time) error {
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		for _, key := range keys {
+			throttle, err := tx.Logins().GetThrottle(ctx, key.key)
+			if err == storage.ErrNotFound {
+				throttle = storage.LoginThrottle{Key: key.key}
+			} else if err != nil {
+				return err
+			}
+
+			if now.Sub(throttle.LastFailureAt) > policy.lockout {
+				throttle.Failures = 0
+			}
+			throttle.Failures++
+			throttle.LastFailureAt = now
+			throttle.LockedUntil = now.Add(policy.delay(throttle.Failures, key.maxFailures))
+
+			if err := tx.Logins().SaveThrottle(ctx, throttle); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Doubles the backoff with every failure up to the lockout, which is reached after maxFailures
+func (p loginPolicy) delay(failures int, maxFailures int) time.Duration {
+	if failures >= maxFailures {
+		return p.lockout
+	}
+	delay := p.backoff << (failures - 1)
+	if delay <= 0 || delay > p.lockout {
+		return p.lockout
+	}
+	return delay
+}
+
+// A failed history insert does not fail the login, it is only logged
+func recordAttempt(ctx context.Context, store storage.Store, attempt storage.LoginAttempt) {
+	if err := store.Logins().AddAttempt(ctx, attempt); err != nil {
+		log.Println("Error recording the login attempt: ", err)
+	}
+}
+
+// The method removes the failed logins of the User, so the next login is accepted at once.
+// Locked IP addresses stay locked until the lockout ends.
+func UnlockUser(ctx context.Context, store storage.Store, userId int) error {
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		user, err := tx.Users().Get(ctx, userId)
+		if err != nil {
+			return err
+		}
+		if err := tx.Logins().DeleteThrottle(ctx, "user:"+user.Username); err != nil {
+			return err
+		}
+
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   UserID(ctx),
+			Action:   audit.ActionUnlock,
+			Entity:   audit.EntityUser,
+			EntityID: userId,
+		})
+	})
+}
+
+type LoginAttemptJSON struct {
+	LoginID     int       `json:"loginId"`
+	Username    string    `json:"username"`
+	UserID      int       `json:"userId,omitempty"`
+	IPAddress   string    `json:"ipAddress"`
+	Success     bool      `json:"success"`
+	Reason      string    `json:"reason"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
+
+func FetchLoginHistory(ctx context.Context, store storage.Store, filter storage.LoginFilter, page storage.Page) ([]LoginAttemptJSON, int, error) {
+	list, total, err := store.Logins().ListAttempts(ctx, filter, page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	attempts := make([]LoginAttemptJSON, 0, len(list))
+	for _, attempt := range list {
+		attempts = append(attempts, LoginAttemptJSON(attempt))
+	}
+	return attempts, total, nil
+}
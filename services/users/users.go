@@ -1,8 +1,12 @@
 package users
 
 import (
-	"database/sql"
+	"context"
 	"errors"
+	"inv_app/services/errs"
+	"inv_app/storage"
+	"log"
+	"time"
 )
 
 type UserJSON struct {
@@ -10,42 +14,145 @@ type UserJSON struct {
 	Username string `json:"username"`
 	Password string `json:"password,omitempty"`
 	Role     string `json:"role"`
+	// New Users are always active, deactivated ones cannot log in
+	IsActive bool `json:"isActive"`
+	// Service accounts have no password and authenticate with API keys
+	IsService bool `json:"isService"`
+	// Permissions of the Role, filled for the authenticated User
+	Permissions []string `json:"permissions,omitempty"`
+	// Customers a User of a customer scoped Role is limited to (see CustomerScope)
+	CustomerScoped bool  `json:"customerScoped,omitempty"`
+	CustomerIDs    []int `json:"customerIds,omitempty"`
 }
 
-type UserDB struct {
-	UserID   int    `field:"user_id"`
-	Username string `field:"username"`
-	Password string `field:"password"`
-	Role     string `field:"role"`
+// AuthJSON is the authenticated User with the token to send in the "Authorization: Bearer" header
+type AuthJSON struct {
+	UserJSON
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
 }
 
-func AuthUser(db *sql.DB, user UserJSON) (UserJSON, error) {
-	username := user.Username
-	password := user.Password
+// The method checks the User password and opens a new Session.
+// Unknown usernames, wrong passwords and deactivated Users get the same error, so the answer
+// does not tell which usernames exist. Failed logins are throttled (see throttle.go),
+// and every attempt is recorded in the login history.
+// Passwords still stored as plaintext are replaced by their hash once they match.
+func AuthUser(ctx context.Context, store storage.Store, user UserJSON, ipAddress string) (AuthJSON, error) {
+	now := time.Now()
+	policy := loginLimits()
+	keys := loginKeys(policy, user.Username, ipAddress)
+	attempt := storage.LoginAttempt{Username: user.Username, IPAddress: ipAddress, AttemptedAt: now}
 
-	var actualUser UserDB
-	db.QueryRow(`
-		SELECT user_id, username, password, role FROM users WHERE username = $1
-		`, username).Scan(
-		&actualUser.UserID,
-		&actualUser.Username,
-		&actualUser.Password,
-		&actualUser.Role,
-	)
+	if err := checkThrottles(ctx, store, keys, now); err != nil {
+		if errors.Is(err, errs.ErrTooManyRequests) {
+			attempt.Reason = LoginThrottled
+			recordAttempt(ctx, store, attempt)
+		}
+		return AuthJSON{}, err
+	}
+
+	actualUser, err := store.Users().FindByUsername(ctx, user.Username)
+	if err != nil && err != storage.ErrNotFound {
+		return AuthJSON{}, err
+	}
+	attempt.UserID = actualUser.UserID
+
+	// An unknown username is checked against a dummy hash, so it takes as long as a wrong password
+	stored := actualUser.Password
+	if err == storage.ErrNotFound {
+		stored = dummyHash()
+	}
+	matches, needsUpgrade := checkPassword(stored, user.Password)
+	matches = matches && err == nil
+
+	if !matches || !actualUser.IsActive || actualUser.IsService {
+		attempt.Reason = LoginWrongCreds
+		if matches {
+			attempt.Reason = LoginDeactivated
+		}
+		recordAttempt(ctx, store, attempt)
+		if err := recordFailure(ctx, store, policy, keys, time.Now()); err != nil {
+			return AuthJSON{}, err
+		}
+		return AuthJSON{}, errs.New(errs.ErrUnauthorized, "Invalid username or password", nil)
+	}
 
-	if actualUser.Username == "" {
-		return UserJSON{}, errors.New("No user found")
+	if err := store.Logins().DeleteThrottle(ctx, keys[0].key); err != nil {
+		return AuthJSON{}, err
 	}
+	attempt.Success = true
+	attempt.Reason = LoginSucceeded
+	recordAttempt(ctx, store, attempt)
 
-	if password != actualUser.Password {
-		return UserJSON{}, errors.New("Wrong password")
+	if needsUpgrade {
+		upgradePassword(ctx, store, actualUser.UserID, user.Password)
 	}
 
-	authUser := UserJSON{
-		UserID:   actualUser.UserID,
-		Username: actualUser.Username,
-		Role:     actualUser.Role,
+	token, expiresAt, err := createSession(ctx, store, actualUser.UserID)
+	if err != nil {
+		return AuthJSON{}, err
 	}
 
-	return authUser, nil
+	authUser, err := withPermissions(ctx, store, actualUser)
+	if err != nil {
+		return AuthJSON{}, err
+	}
+
+	return AuthJSON{
+		UserJSON:  authUser,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// The method returns the User of the Session token with the Permissions of the User Role.
+func Authenticate(ctx context.Context, store storage.Store, token string) (UserJSON, error) {
+	if token == "" {
+		return UserJSON{}, errs.New(errs.ErrUnauthorized, "Authentication required", nil)
+	}
+
+	user, err := store.Sessions().GetUser(ctx, hashToken(token), time.Now())
+	if err == storage.ErrNotFound {
+		return UserJSON{}, errs.New(errs.ErrUnauthorized, "Invalid or expired token", nil)
+	}
+	if err != nil {
+		return UserJSON{}, err
+	}
+	return withPermissions(ctx, store, user)
+}
+
+func withPermissions(ctx context.Context, store storage.Store, user storage.UserDB) (UserJSON, error) {
+	permissions, err := store.Roles().Permissions(ctx, user.Role)
+	if err != nil {
+		return UserJSON{}, err
+	}
+	userJSON := toUserJSON(user)
+	userJSON.Permissions = permissions
+	return withCustomerScope(ctx, store, userJSON)
+}
+
+// The method closes the Session of the token.
+func Logout(ctx context.Context, store storage.Store, token string) error {
+	return store.Sessions().Delete(ctx, hashToken(token))
+}
+
+// A failed upgrade does not fail the login, the password is upgraded on the next one
+func upgradePassword(ctx context.Context, store storage.Store, userId int, password string) {
+	passwordHash, err := HashPassword(password)
+	if err == nil {
+		err = store.Users().SetPassword(ctx, userId, passwordHash)
+	}
+	if err != nil {
+		log.Println("Error upgrading the password hash: ", err)
+	}
+}
+
+func toUserJSON(user storage.UserDB) UserJSON {
+	return UserJSON{
+		UserID:    user.UserID,
+		Username:  user.Username,
+		Role:      user.Role,
+		IsActive:  user.IsActive,
+		IsService: user.IsService,
+	}
 }
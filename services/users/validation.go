@@ -0,0 +1,51 @@
+package users
+
+import "inv_app/services/validation"
+
+// Payload checks of the User management requests, each method returns validation.Errors or nil.
+
+const minPasswordLength = 8
+
+// bcrypt hashes at most 72 bytes and rejects longer passwords
+const maxPasswordBytes = 72
+
+func ValidateCreate(user UserJSON) error {
+	errs := validation.Errors{}
+	errs.Required("username", user.Username)
+	if user.IsService {
+		if user.Password != "" {
+			errs.Add("password", "must be empty for a service account")
+		}
+	} else {
+		errs.MinLength("password", user.Password, minPasswordLength)
+		errs.MaxBytes("password", user.Password, maxPasswordBytes)
+	}
+	errs.Required("role", user.Role)
+	return errs.Err()
+}
+
+func ValidateUpdate(user UpdateUserJSON) error {
+	errs := validation.Errors{}
+	if user.Role == nil && user.IsActive == nil {
+		errs.Add("body", "must contain role or isActive")
+	}
+	if user.Role != nil {
+		errs.Required("role", *user.Role)
+	}
+	return errs.Err()
+}
+
+func ValidatePasswordReset(password PasswordJSON) error {
+	errs := validation.Errors{}
+	errs.MinLength("password", password.Password, minPasswordLength)
+	errs.MaxBytes("password", password.Password, maxPasswordBytes)
+	return errs.Err()
+}
+
+func ValidatePasswordChange(password PasswordJSON) error {
+	errs := validation.Errors{}
+	errs.Required("currentPassword", password.CurrentPassword)
+	errs.MinLength("password", password.Password, minPasswordLength)
+	errs.MaxBytes("password", password.Password, maxPasswordBytes)
+	return errs.Err()
+}
@@ -0,0 +1,111 @@
+package validation
+
+import (
+	"fmt"
+	"inv_app/services/errs"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// The package collects per-field errors of a request payload,
+// so all problems are reported at once before the Business Logic is called.
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors is returned as an error once at least one field is invalid.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := []string{}
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Field+": "+fieldErr.Message)
+	}
+	return "Invalid request: " + strings.Join(messages, "; ")
+}
+
+func (e *Errors) Add(field string, message string) {
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// Errors match errs.ErrValidation
+func (e Errors) Is(target error) bool {
+	return target == errs.ErrValidation
+}
+
+// Returns nil if there are no errors, so the result can be returned as an error directly
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *Errors) Required(field string, value string) {
+	if strings.TrimSpace(value) == "" {
+		e.Add(field, "is required")
+	}
+}
+
+func (e *Errors) ID(field string, value int) {
+	if value <= 0 {
+		e.Add(field, "must be a positive ID")
+	}
+}
+
+func (e *Errors) Positive(field string, value int) {
+	if value <= 0 {
+		e.Add(field, "must be greater than 0")
+	}
+}
+
+func (e *Errors) NonNegative(field string, value int) {
+	if value < 0 {
+		e.Add(field, "must not be negative")
+	}
+}
+
+func (e *Errors) NonNegativeMoney(field string, value decimal.Decimal, places int32) {
+	if value.IsNegative() {
+		e.Add(field, "must not be negative")
+	} else if !value.Equal(value.Round(places)) {
+		e.Add(field, fmt.Sprintf("must have at most %d decimal places", places))
+	}
+}
+
+func (e *Errors) MinLength(field string, value string, length int) {
+	if len([]rune(value)) < length {
+		e.Add(field, fmt.Sprintf("must be at least %d characters long", length))
+	}
+}
+
+func (e *Errors) MaxBytes(field string, value string, bytes int) {
+	if len(value) > bytes {
+		e.Add(field, fmt.Sprintf("must be at most %d bytes long", bytes))
+	}
+}
+
+func (e *Errors) OneOf(field string, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		e.Add(field, "must be one of: "+strings.Join(allowed, ", "))
+	}
+}
+
+// Currencies are passed as ISO 4217 codes, e.g. "EUR", in any case
+func (e *Errors) Currency(field string, value string) {
+	if len(value) != 3 || strings.Trim(strings.ToUpper(value), "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		e.Add(field, "must be a 3-letter currency code")
+	}
+}
+
+// Dates are passed as "YYYY-MM-DD", an empty value is allowed
+func (e *Errors) Date(field string, value string) {
+	if _, err := time.Parse(time.DateOnly, value); value != "" && err != nil {
+		e.Add(field, "must be a date in the YYYY-MM-DD format")
+	}
+}
@@ -1,7 +1,10 @@
 package warehouses
 
 import (
-	"database/sql"
+	"context"
+	"inv_app/services/audit"
+	"inv_app/services/users"
+	"inv_app/storage"
 	"log"
 )
 
@@ -10,68 +13,51 @@ type WarehouseJSON struct {
 	LocationName  string `json:"locationName"`
 }
 
-type WarehouseDB struct {
-	WarehouseID   int    `field:"warehouse_id"`
-	WarehouseName string `field:"name"`
-}
-
-func FetchWarehouses(db *sql.DB) ([]WarehouseDB, error) {
-	rows, err := db.Query("SELECT * FROM warehouses;")
+func FetchWarehouses(ctx context.Context, store storage.Store, page storage.Page) ([]storage.WarehouseDB, int, error) {
+	warehouses, total, err := store.Warehouses().List(ctx, page)
 	if err != nil {
-		log.Println("Error fetchWarehouses1: ", err)
-		return nil, err
-	}
-	defer rows.Close()
-
-	var warehouses []WarehouseDB
-
-	for rows.Next() {
-		var warehouse WarehouseDB
-		if err := rows.Scan(&warehouse.WarehouseID, &warehouse.WarehouseName); err != nil {
-			log.Println("Error fetchWarehouses2: ", err)
-			return warehouses, err
-		}
-		warehouses = append(warehouses, warehouse)
+		log.Println("Error fetchWarehouses: ", err)
+		return nil, 0, err
 	}
-	if err = rows.Err(); err != nil {
-		return warehouses, err
-	}
-
-	return warehouses, nil
+	return warehouses, total, nil
 }
 
-func CreateWarehouse(warehouse WarehouseJSON, db *sql.DB) error {
-	warehouses, err := FetchWarehouses(db)
-
-	if err != nil {
-		return err
-	}
-
-	warehousesMap := make(map[string]int)
-	for _, warehouse := range warehouses {
-		warehousesMap[warehouse.WarehouseName] = warehouse.WarehouseID
-	}
+// The method creates the Location and its Warehouse if the Warehouse does not exist yet.
+func CreateWarehouse(ctx context.Context, store storage.Store, warehouse WarehouseJSON) error {
+	return storage.WithTx(ctx, store, func(tx storage.Tx) error {
+		warehouseId, err := tx.Warehouses().Find(ctx, warehouse.WarehouseName)
+		if err != nil {
+			return err
+		}
 
-	id, ok := warehousesMap[warehouse.WarehouseName]
+		if warehouseId == 0 {
+			warehouseId, err = tx.Warehouses().Create(ctx, warehouse.WarehouseName)
+			if err != nil {
+				return err
+			}
+			err = audit.Record(ctx, tx, audit.Change{
+				UserID:   users.UserID(ctx),
+				Action:   audit.ActionCreate,
+				Entity:   audit.EntityWarehouse,
+				EntityID: warehouseId,
+				After:    map[string]string{"warehouseName": warehouse.WarehouseName},
+			})
+			if err != nil {
+				return err
+			}
+		}
 
-	var warehouseId int
-	if !ok {
-		err = db.QueryRow(`
-			INSERT INTO warehouses(name) VALUES($1)
-			RETURNING warehouse_id;`,
-			warehouse.WarehouseName).Scan(&warehouseId)
+		locationId, err := tx.Locations().Create(ctx, warehouse.LocationName, warehouseId)
 		if err != nil {
 			return err
 		}
-	} else {
-		warehouseId = id
-	}
-
-	_, err = db.Exec("INSERT INTO locations(name, warehouse_id) VALUES ($1,$2);",
-		warehouse.LocationName, warehouseId)
-	if err != nil {
-		return err
-	}
 
-	return nil
+		return audit.Record(ctx, tx, audit.Change{
+			UserID:   users.UserID(ctx),
+			Action:   audit.ActionCreate,
+			Entity:   audit.EntityLocation,
+			EntityID: locationId,
+			After:    map[string]any{"locationName": warehouse.LocationName, "warehouseId": warehouseId},
+		})
+	})
 }
@@ -1,18 +1,19 @@
 package websocket
 
 import (
+	"inv_app/services/users"
 	"log"
 	"net/http"
 )
 
 // Web Socket Endpoint without any Parameters
-func WsEndpoint(w http.ResponseWriter, r *http.Request) {
-	upgrader.CheckOrigin = func(r *http.Request) bool { return true }
-	ws, err := upgrader.Upgrade(w, r, nil)
+func (h *Hub) WsEndpoint(w http.ResponseWriter, r *http.Request) {
+	ws, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("Upgrade error:", err)
 		return
 	}
-	addClient(ws)
-	go reader(ws)
+	customerIds := users.CustomerScope(r.Context())
+	h.addClient(ws, customerIds)
+	go h.reader(ws, customerIds)
 }
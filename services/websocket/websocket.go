@@ -1,36 +1,52 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
-	"inv_app/database"
 	"inv_app/services/materials"
+	"inv_app/storage"
 	"log"
+	"net/http"
+	"slices"
 	"sync"
 
 	"github.com/gorilla/websocket"
 )
 
-var (
-	upgrader = websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
-	}
-	wsActiveClients = make(map[*websocket.Conn]bool)
-	clientsMutex    sync.Mutex
-)
-
 type Message struct {
 	Type string `json:"type"`
 	Data any    `json:"data,omitempty"`
 }
 
-func addClient(conn *websocket.Conn) {
-	clientsMutex.Lock()
-	defer clientsMutex.Unlock()
-	wsActiveClients[conn] = true
+// Hub keeps the active WebSocket Clients and the Storage used to build the broadcast messages.
+// Every Client keeps the customer scope of its User, so it is sent the counts of its own Customers only.
+type Hub struct {
+	store        storage.Store
+	upgrader     websocket.Upgrader
+	clients      map[*websocket.Conn][]int
+	clientsMutex sync.Mutex
 }
 
-func reader(conn *websocket.Conn) {
+func NewHub(store storage.Store) *Hub {
+	return &Hub{
+		store: store,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     func(r *http.Request) bool { return true },
+		},
+		clients: make(map[*websocket.Conn][]int),
+	}
+}
+
+func (h *Hub) addClient(conn *websocket.Conn, customerIds []int) {
+	h.clientsMutex.Lock()
+	defer h.clientsMutex.Unlock()
+	h.clients[conn] = customerIds
+}
+
+// Customer scoped Users change nothing, so their messages are ignored
+func (h *Hub) reader(conn *websocket.Conn, customerIds []int) {
 	for {
 		_, p, err := conn.ReadMessage()
 		if err != nil {
@@ -38,72 +54,68 @@ func reader(conn *websocket.Conn) {
 			return
 		}
 
+		if customerIds != nil {
+			continue
+		}
+
 		msgType := string(p)
 
 		if msgType == "materialsUpdated" {
-			handleSendMaterial()
+			h.handleSendMaterial()
 		} else if msgType == "vaultUpdated" {
-			handleSendVault()
+			h.handleSendVault()
 		}
 	}
 }
 
-func handleSendMaterial() {
-	db, _ := database.ConnectToDB()
-	materials, err := materials.GetIncomingMaterials(db, 0)
-	count := 0
-	for _, material := range materials {
-		if material.MaterialType != "CARDS" && material.MaterialType != "CHIPS" {
-			count++
-		}
-	}
-
+func (h *Hub) handleSendMaterial() {
+	materials, _, err := materials.GetIncomingMaterials(context.Background(), h.store, 0, storage.Page{})
 	if err != nil {
 		log.Println("WS error getting materials:", err)
 		return
 	}
 
 	// Broadcast the message to all clients
-	msg := Message{Type: "incomingMaterialsQty", Data: count}
-	broadcastMessage(msg)
+	h.broadcastCount("incomingMaterialsQty", materials, func(material storage.IncomingMaterialDB) bool {
+		return material.MaterialType != "CARDS" && material.MaterialType != "CHIPS"
+	})
 }
 
-func handleSendVault() {
-	db, _ := database.ConnectToDB()
-	materials, err := materials.GetIncomingMaterials(db, 0)
-	count := 0
-	for _, material := range materials {
-		if material.MaterialType == "CARDS" || material.MaterialType == "CHIPS" {
-			count++
-		}
-	}
-
+func (h *Hub) handleSendVault() {
+	materials, _, err := materials.GetIncomingMaterials(context.Background(), h.store, 0, storage.Page{})
 	if err != nil {
 		log.Println("WS error getting materials:", err)
 		return
 	}
 
 	// Broadcast the message to all clients
-	msg := Message{Type: "incomingVaultQty", Data: count}
-	broadcastMessage(msg)
+	h.broadcastCount("incomingVaultQty", materials, func(material storage.IncomingMaterialDB) bool {
+		return material.MaterialType == "CARDS" || material.MaterialType == "CHIPS"
+	})
 }
 
-func broadcastMessage(message Message) {
-	clientsMutex.Lock()
-	defer clientsMutex.Unlock()
-
-	msg, err := json.Marshal(message)
-	if err != nil {
-		log.Println("WS Broadcast error encoding message:", err)
-		return
-	}
+// The method sends every Client the number of the matching Incoming Materials of its Customers
+func (h *Hub) broadcastCount(msgType string, materials []storage.IncomingMaterialDB, matches func(material storage.IncomingMaterialDB) bool) {
+	h.clientsMutex.Lock()
+	defer h.clientsMutex.Unlock()
+
+	for client, customerIds := range h.clients {
+		count := 0
+		for _, material := range materials {
+			if matches(material) && (customerIds == nil || slices.Contains(customerIds, material.CustomerID)) {
+				count++
+			}
+		}
 
-	for client := range wsActiveClients {
+		msg, err := json.Marshal(Message{Type: msgType, Data: count})
+		if err != nil {
+			log.Println("WS Broadcast error encoding message:", err)
+			return
+		}
 		if err := client.WriteMessage(websocket.TextMessage, msg); err != nil {
 			log.Println("WS Broadcast WriteMessage error:", err)
 			client.Close()
-			delete(wsActiveClients, client)
+			delete(h.clients, client)
 		}
-
 	}
 }
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// The Transaction Logs form a hash chain: every row keeps the SHA-256 of its content
+// together with the hash of the previous row, so an edited, removed or reordered row breaks the chain.
+
+// The method returns the hex encoded hash of the Transaction Log content and its PrevHash.
+// UpdatedAt is hashed as a date, the precision kept by the transactions_log table.
+// Fields added after the chain was introduced are omitted when empty, so older rows keep their hashes.
+func TransactionHash(trx Transaction) string {
+	content, _ := json.Marshal(struct {
+		TransactionID     int
+		PriceID           int
+		Qty               int
+		Notes             string
+		JobTicket         string
+		UpdatedAt         string
+		SerialNumberRange string
+		UserID            int
+		ApprovedBy        int
+		Type              string `json:",omitempty"`
+		FromLocationID    int    `json:",omitempty"`
+		ToLocationID      int    `json:",omitempty"`
+		ShippingID        int    `json:",omitempty"`
+		RequestID         int    `json:",omitempty"`
+		CorrelationID     string `json:",omitempty"`
+		ReversesID        int    `json:",omitempty"`
+		PrevHash          string
+	}{
+		TransactionID:     trx.TransactionID,
+		PriceID:           trx.PriceID,
+		Qty:               trx.Qty,
+		Notes:             trx.Notes,
+		JobTicket:         trx.JobTicket,
+		UpdatedAt:         trx.UpdatedAt.Format(time.DateOnly),
+		SerialNumberRange: trx.SerialNumberRange,
+		UserID:            trx.UserID,
+		ApprovedBy:        trx.ApprovedBy,
+		Type:              trx.Type,
+		FromLocationID:    trx.FromLocationID,
+		ToLocationID:      trx.ToLocationID,
+		ShippingID:        trx.ShippingID,
+		RequestID:         trx.RequestID,
+		CorrelationID:     trx.CorrelationID,
+		ReversesID:        trx.ReversesID,
+		PrevHash:          trx.PrevHash,
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// The method returns a random ID shared by the Transaction Logs of one stock change
+func NewCorrelationID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
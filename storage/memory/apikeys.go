@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"inv_app/storage"
+	"slices"
+	"time"
+)
+
+type apiKeyRepo struct {
+	a access
+}
+
+func (r apiKeyRepo) Create(ctx context.Context, key storage.APIKey) (int, error) {
+	err := r.a.write(func(d *data) error {
+		if _, ok := d.users[key.UserID]; !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: user_id (%d)", storage.ErrConflict, key.UserID)
+		}
+		if _, ok := d.users[key.CreatedBy]; !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: created_by (%d)", storage.ErrConflict, key.CreatedBy)
+		}
+		for _, k := range d.apiKeys {
+			if k.KeyHash == key.KeyHash {
+				return fmt.Errorf("%w: duplicate key value violates unique constraint: key_hash", storage.ErrConflict)
+			}
+		}
+		key.KeyID = d.nextID("api_keys")
+		d.apiKeys[key.KeyID] = key
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return key.KeyID, nil
+}
+
+func (r apiKeyRepo) List(ctx context.Context, userId int) ([]storage.APIKey, error) {
+	keys := []storage.APIKey{}
+	r.a.read(func(d *data) {
+		for _, key := range d.apiKeys {
+			if key.UserID == userId {
+				keys = append(keys, key)
+			}
+		}
+	})
+	slices.SortFunc(keys, func(a, b storage.APIKey) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(b.KeyID, a.KeyID))
+	})
+	return keys, nil
+}
+
+func (r apiKeyRepo) GetUser(ctx context.Context, keyHash string, now time.Time) (storage.APIKey, storage.UserDB, error) {
+	var key storage.APIKey
+	var user storage.UserDB
+	found := false
+	r.a.read(func(d *data) {
+		for _, k := range d.apiKeys {
+			if k.KeyHash != keyHash || k.RevokedAt != nil || (k.ExpiresAt != nil && !k.ExpiresAt.After(now)) {
+				continue
+			}
+			user, found = d.users[k.UserID]
+			found = found && user.IsActive
+			key = k
+			return
+		}
+	})
+	if !found {
+		return storage.APIKey{}, storage.UserDB{}, storage.ErrNotFound
+	}
+	user.Password = ""
+	return key, user, nil
+}
+
+func (r apiKeyRepo) SetLastUsed(ctx context.Context, keyId int, now time.Time) error {
+	return r.a.write(func(d *data) error {
+		key, ok := d.apiKeys[keyId]
+		if ok {
+			key.LastUsedAt = &now
+			d.apiKeys[keyId] = key
+		}
+		return nil
+	})
+}
+
+func (r apiKeyRepo) Revoke(ctx context.Context, keyId int, now time.Time) error {
+	return r.a.write(func(d *data) error {
+		key, ok := d.apiKeys[keyId]
+		if !ok || key.RevokedAt != nil {
+			return storage.ErrNotFound
+		}
+		key.RevokedAt = &now
+		d.apiKeys[keyId] = key
+		return nil
+	})
+}
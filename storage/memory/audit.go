@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"inv_app/storage"
+	"slices"
+)
+
+type auditRepo struct {
+	a access
+}
+
+func (r auditRepo) Add(ctx context.Context, entry storage.AuditEntry) error {
+	return r.a.write(func(d *data) error {
+		if _, ok := d.users[entry.UserID]; entry.UserID != 0 && !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: user_id (%d)", storage.ErrConflict, entry.UserID)
+		}
+		entry.AuditID = d.nextID("audit_log")
+		entry.Username = ""
+		d.audit = append(d.audit, entry)
+		return nil
+	})
+}
+
+func (r auditRepo) List(ctx context.Context, filter storage.AuditFilter, page storage.Page) ([]storage.AuditEntry, int, error) {
+	var entries []storage.AuditEntry
+	r.a.read(func(d *data) {
+		for _, entry := range d.audit {
+			createdAt := dateString(entry.CreatedAt)
+			if (filter.UserID != 0 && entry.UserID != filter.UserID) ||
+				(filter.Entity != "" && entry.Entity != filter.Entity) ||
+				(filter.EntityID != "" && entry.EntityID != filter.EntityID) ||
+				(filter.DateFrom != "" && createdAt < filter.DateFrom) ||
+				(filter.DateTo != "" && createdAt > filter.DateTo) {
+				continue
+			}
+			entry.Username = d.users[entry.UserID].Username
+			entries = append(entries, entry)
+		}
+	})
+	slices.SortFunc(entries, func(a, b storage.AuditEntry) int {
+		return cmp.Compare(b.AuditID, a.AuditID)
+	})
+	rows, total := paginate(entries, page, auditSortFields)
+	return rows, total, nil
+}
@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"inv_app/storage"
+	"slices"
+)
+
+type costingRepo struct {
+	a access
+}
+
+func (r costingRepo) Rules(ctx context.Context) ([]storage.CostingRule, error) {
+	var rules []storage.CostingRule
+	r.a.read(func(d *data) {
+		rules = slices.Clone(d.costingRules)
+	})
+	if rules == nil {
+		rules = []storage.CostingRule{}
+	}
+	return rules, nil
+}
+
+func (r costingRepo) SetRules(ctx context.Context, rules []storage.CostingRule) error {
+	return r.a.write(func(d *data) error {
+		sorted := slices.Clone(rules)
+		slices.SortFunc(sorted, func(a, b storage.CostingRule) int {
+			return cmp.Or(cmp.Compare(a.Owner, b.Owner), cmp.Compare(a.CustomerID, b.CustomerID))
+		})
+		for i, rule := range sorted {
+			if _, ok := d.customers[rule.CustomerID]; rule.CustomerID != 0 && !ok {
+				return fmt.Errorf("%w: insert violates foreign key constraint: customer_id (%d)", storage.ErrConflict, rule.CustomerID)
+			}
+			if i > 0 && sorted[i-1].Owner == rule.Owner && sorted[i-1].CustomerID == rule.CustomerID {
+				return fmt.Errorf("%w: duplicate key value violates unique constraint: owner (%s), customer_id (%d)", storage.ErrConflict, rule.Owner, rule.CustomerID)
+			}
+		}
+		d.costingRules = sorted
+		return nil
+	})
+}
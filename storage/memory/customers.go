@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"inv_app/storage"
+	"sort"
+)
+
+type customerRepo struct {
+	a access
+}
+
+func (r customerRepo) List(ctx context.Context, filter storage.CustomerFilter, page storage.Page) ([]storage.CustomerDB, int, error) {
+	var customers []storage.CustomerDB
+	r.a.read(func(d *data) {
+		for _, customer := range d.customers {
+			if inScope(filter.CustomerIDs, customer.ID) {
+				customers = append(customers, customer)
+			}
+		}
+	})
+	sort.Slice(customers, func(i, j int) bool {
+		return customers[i].Name < customers[j].Name
+	})
+	customers, total := paginate(customers, page, customerSortFields)
+	return customers, total, nil
+}
+
+func (r customerRepo) Find(ctx context.Context, name string, code string) (int, error) {
+	customerId := 0
+	r.a.read(func(d *data) {
+		for _, customer := range d.customers {
+			if customer.Name == name && customer.Code == code {
+				customerId = customer.ID
+			}
+		}
+	})
+	return customerId, nil
+}
+
+func (r customerRepo) Create(ctx context.Context, name string, code string) (int, error) {
+	var customerId int
+	err := r.a.write(func(d *data) error {
+		for _, customer := range d.customers {
+			if customer.Name == name {
+				return fmt.Errorf("%w: duplicate key value violates unique constraint: name (%s)", storage.ErrConflict, name)
+			}
+		}
+		customerId = d.nextID("customers")
+		d.customers[customerId] = storage.CustomerDB{ID: customerId, Name: name, Code: code}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return customerId, nil
+}
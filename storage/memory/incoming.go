@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"inv_app/storage"
+	"sort"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+)
+
+type incomingRepo struct {
+	a access
+}
+
+func (r incomingRepo) Create(ctx context.Context, material storage.IncomingMaterialDB) (int, error) {
+	var shippingId int
+	r.a.write(func(d *data) error {
+		shippingId = d.nextID("incoming_materials")
+		material.ShippingID = strconv.Itoa(shippingId)
+		material.CustomerName, material.UserName = "", ""
+		d.incoming[shippingId] = material
+		return nil
+	})
+	return shippingId, nil
+}
+
+func (r incomingRepo) Get(ctx context.Context, shippingId int) (storage.IncomingMaterialDB, error) {
+	var material storage.IncomingMaterialDB
+	var ok bool
+	r.a.read(func(d *data) {
+		material, ok = d.incoming[shippingId]
+	})
+	if !ok {
+		return storage.IncomingMaterialDB{}, storage.ErrNotFound
+	}
+	return material, nil
+}
+
+// Transactions are serialized, so the Incoming Material needs no row lock
+func (r incomingRepo) GetForUpdate(ctx context.Context, shippingId int) (storage.IncomingMaterialDB, error) {
+	return r.Get(ctx, shippingId)
+}
+
+func (r incomingRepo) List(ctx context.Context, filter storage.IncomingFilter, page storage.Page) ([]storage.IncomingMaterialDB, int, error) {
+	var materials []storage.IncomingMaterialDB
+	r.a.read(func(d *data) {
+		for id, material := range d.incoming {
+			if material.Quantity <= 0 || (filter.ShippingID != 0 && id != filter.ShippingID) || !inScope(filter.CustomerIDs, material.CustomerID) {
+				continue
+			}
+			material.CustomerName = d.customers[material.CustomerID].Name
+			material.UserName = d.users[material.UserID].Username
+			materials = append(materials, material)
+		}
+	})
+	sort.Slice(materials, func(i, j int) bool {
+		return compareIDs(materials[i].ShippingID, materials[j].ShippingID) < 0
+	})
+	materials, total := paginate(materials, page, incomingSortFields)
+	return materials, total, nil
+}
+
+func (r incomingRepo) Update(ctx context.Context, material storage.IncomingMaterialDB) error {
+	shippingId, _ := strconv.Atoi(material.ShippingID)
+	return r.a.write(func(d *data) error {
+		current, ok := d.incoming[shippingId]
+		if !ok {
+			return nil
+		}
+		material.UserID = current.UserID
+		material.LandedCost, material.LandedValue = current.LandedCost, current.LandedValue
+		material.CustomerName, material.UserName = "", ""
+		d.incoming[shippingId] = material
+		return nil
+	})
+}
+
+func (r incomingRepo) AddQuantity(ctx context.Context, shippingId int, qty int) error {
+	return r.a.write(func(d *data) error {
+		material, ok := d.incoming[shippingId]
+		if !ok {
+			return nil
+		}
+		material.Quantity += qty
+		d.incoming[shippingId] = material
+		return nil
+	})
+}
+
+func (r incomingRepo) AddLandedCost(ctx context.Context, shippingId int, unitCost decimal.Decimal, value decimal.Decimal) error {
+	return r.a.write(func(d *data) error {
+		material, ok := d.incoming[shippingId]
+		if !ok {
+			return nil
+		}
+		material.LandedCost = material.LandedCost.Add(unitCost)
+		material.LandedValue = material.LandedValue.Add(value)
+		d.incoming[shippingId] = material
+		return nil
+	})
+}
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"inv_app/storage"
+	"slices"
+	"time"
+)
+
+type landedCostRepo struct {
+	a access
+}
+
+func (r landedCostRepo) Create(ctx context.Context, charge storage.LandedCharge) (int, error) {
+	err := r.a.write(func(d *data) error {
+		if _, ok := d.users[charge.UserID]; charge.UserID != 0 && !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: user_id (%d)", storage.ErrConflict, charge.UserID)
+		}
+		charge.ChargeID = d.nextID("landed_charges")
+		charge.Username = ""
+		charge.CreatedAt = time.Now()
+		charge.Allocations = slices.Clone(charge.Allocations)
+		for i := range charge.Allocations {
+			charge.Allocations[i].ChargeID = charge.ChargeID
+		}
+		d.landedCharges = append(d.landedCharges, charge)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return charge.ChargeID, nil
+}
+
+func (r landedCostRepo) List(ctx context.Context, shippingId int, page storage.Page) ([]storage.LandedCharge, int, error) {
+	charges := []storage.LandedCharge{}
+	r.a.read(func(d *data) {
+		for _, charge := range d.landedCharges {
+			allocated := slices.ContainsFunc(charge.Allocations, func(allocation storage.LandedAllocation) bool {
+				return allocation.ShippingID == shippingId
+			})
+			if shippingId != 0 && !allocated {
+				continue
+			}
+			charge.Username = d.users[charge.UserID].Username
+			charge.Allocations = slices.Clone(charge.Allocations)
+			slices.SortFunc(charge.Allocations, func(a, b storage.LandedAllocation) int {
+				return cmp.Compare(a.ShippingID, b.ShippingID)
+			})
+			charges = append(charges, charge)
+		}
+	})
+	slices.Reverse(charges)
+	charges, total := paginate(charges, page, landedCostSortFields)
+	return charges, total, nil
+}
@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"inv_app/storage"
+	"sort"
+)
+
+type locationRepo struct {
+	a access
+}
+
+func (r locationRepo) List(ctx context.Context, page storage.Page) ([]storage.LocationDB, int, error) {
+	locations, total := paginate(r.list(func(d *data, l location) bool { return true }), page, locationSortFields)
+	return locations, total, nil
+}
+
+func (r locationRepo) ListAvailable(ctx context.Context, opts storage.LocationFilter, page storage.Page) ([]storage.LocationDB, int, error) {
+	available := r.list(func(d *data, l location) bool {
+		for _, material := range d.materials {
+			if material.LocationID == l.id {
+				return material.StockID == opts.StockId && material.Owner == opts.Owner
+			}
+		}
+		return true
+	})
+	locations, total := paginate(available, page, locationSortFields)
+	return locations, total, nil
+}
+
+func (r locationRepo) Find(ctx context.Context, name string, warehouseId int) (int, error) {
+	locationId := 0
+	r.a.read(func(d *data) {
+		for _, l := range d.locations {
+			if l.name == name && l.warehouseId == warehouseId {
+				locationId = l.id
+			}
+		}
+	})
+	return locationId, nil
+}
+
+func (r locationRepo) Create(ctx context.Context, name string, warehouseId int) (int, error) {
+	var locationId int
+	err := r.a.write(func(d *data) error {
+		if _, ok := d.warehouses[warehouseId]; !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: warehouse_id (%d)", storage.ErrConflict, warehouseId)
+		}
+		for _, l := range d.locations {
+			if l.name == name && l.warehouseId == warehouseId {
+				return fmt.Errorf("%w: duplicate key value violates unique constraint: name (%s), warehouse_id (%d)", storage.ErrConflict, name, warehouseId)
+			}
+		}
+		locationId = d.nextID("locations")
+		d.locations[locationId] = location{id: locationId, name: name, warehouseId: warehouseId}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return locationId, nil
+}
+
+func (r locationRepo) list(match func(d *data, l location) bool) []storage.LocationDB {
+	var locations []storage.LocationDB
+	r.a.read(func(d *data) {
+		for _, l := range d.locations {
+			if !match(d, l) {
+				continue
+			}
+			locations = append(locations, storage.LocationDB{
+				ID:            l.id,
+				Name:          l.name,
+				WarehouseID:   l.warehouseId,
+				WarehouseName: d.warehouses[l.warehouseId].WarehouseName,
+			})
+		}
+	})
+	sort.Slice(locations, func(i, j int) bool {
+		if locations[i].Name != locations[j].Name {
+			return locations[i].Name < locations[j].Name
+		}
+		return locations[i].ID < locations[j].ID
+	})
+	return locations
+}
+
+type warehouseRepo struct {
+	a access
+}
+
+func (r warehouseRepo) List(ctx context.Context, page storage.Page) ([]storage.WarehouseDB, int, error) {
+	var warehouses []storage.WarehouseDB
+	r.a.read(func(d *data) {
+		for _, warehouse := range d.warehouses {
+			warehouses = append(warehouses, warehouse)
+		}
+	})
+	sort.Slice(warehouses, func(i, j int) bool {
+		return warehouses[i].WarehouseID < warehouses[j].WarehouseID
+	})
+	warehouses, total := paginate(warehouses, page, warehouseSortFields)
+	return warehouses, total, nil
+}
+
+func (r warehouseRepo) Find(ctx context.Context, name string) (int, error) {
+	warehouseId := 0
+	r.a.read(func(d *data) {
+		for _, warehouse := range d.warehouses {
+			if warehouse.WarehouseName == name {
+				warehouseId = warehouse.WarehouseID
+			}
+		}
+	})
+	return warehouseId, nil
+}
+
+func (r warehouseRepo) Create(ctx context.Context, name string) (int, error) {
+	var warehouseId int
+	err := r.a.write(func(d *data) error {
+		for _, warehouse := range d.warehouses {
+			if warehouse.WarehouseName == name {
+				return fmt.Errorf("%w: duplicate key value violates unique constraint: name (%s)", storage.ErrConflict, name)
+			}
+		}
+		warehouseId = d.nextID("warehouses")
+		d.warehouses[warehouseId] = storage.WarehouseDB{WarehouseID: warehouseId, WarehouseName: name}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return warehouseId, nil
+}
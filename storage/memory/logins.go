@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"inv_app/storage"
+	"slices"
+)
+
+type loginRepo struct {
+	a access
+}
+
+// Transactions are serialized, so the Throttle needs no row lock
+func (r loginRepo) GetThrottle(ctx context.Context, key string) (storage.LoginThrottle, error) {
+	var throttle storage.LoginThrottle
+	var ok bool
+	r.a.read(func(d *data) {
+		throttle, ok = d.throttles[key]
+	})
+	if !ok {
+		return storage.LoginThrottle{}, storage.ErrNotFound
+	}
+	return throttle, nil
+}
+
+func (r loginRepo) SaveThrottle(ctx context.Context, throttle storage.LoginThrottle) error {
+	return r.a.write(func(d *data) error {
+		d.throttles[throttle.Key] = throttle
+		return nil
+	})
+}
+
+func (r loginRepo) DeleteThrottle(ctx context.Context, key string) error {
+	return r.a.write(func(d *data) error {
+		delete(d.throttles, key)
+		return nil
+	})
+}
+
+func (r loginRepo) AddAttempt(ctx context.Context, attempt storage.LoginAttempt) error {
+	return r.a.write(func(d *data) error {
+		if _, ok := d.users[attempt.UserID]; attempt.UserID != 0 && !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: user_id (%d)", storage.ErrConflict, attempt.UserID)
+		}
+		attempt.LoginID = d.nextID("login_history")
+		d.logins = append(d.logins, attempt)
+		return nil
+	})
+}
+
+func (r loginRepo) ListAttempts(ctx context.Context, filter storage.LoginFilter, page storage.Page) ([]storage.LoginAttempt, int, error) {
+	var attempts []storage.LoginAttempt
+	r.a.read(func(d *data) {
+		for _, attempt := range d.logins {
+			if filter.Username != "" && attempt.Username != filter.Username {
+				continue
+			}
+			if filter.Success != nil && attempt.Success != *filter.Success {
+				continue
+			}
+			attempts = append(attempts, attempt)
+		}
+	})
+	slices.SortFunc(attempts, func(a, b storage.LoginAttempt) int {
+		return cmp.Or(b.AttemptedAt.Compare(a.AttemptedAt), cmp.Compare(b.LoginID, a.LoginID))
+	})
+	rows, total := paginate(attempts, page, loginSortFields)
+	return rows, total, nil
+}
@@ -0,0 +1,234 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"inv_app/storage"
+	"sort"
+	"strings"
+)
+
+type materialRepo struct {
+	a access
+}
+
+func (r materialRepo) List(ctx context.Context, opts storage.MaterialFilter, page storage.Page) ([]storage.MaterialDB, int, error) {
+	var materials []storage.MaterialDB
+	r.a.read(func(d *data) {
+		for _, material := range d.materials {
+			material = d.joinMaterial(material)
+			if (opts.MaterialId != 0 && material.MaterialID != opts.MaterialId) || !inScope(opts.CustomerIDs, material.CustomerID) {
+				continue
+			}
+			if !contains(material.StockID, opts.StockId) ||
+				!contains(material.CustomerName, opts.CustomerName) ||
+				!contains(material.Description, opts.Description) {
+				continue
+			}
+			if opts.LocationName != "" && (material.LocationID == 0 || !contains(material.LocationName, opts.LocationName)) {
+				continue
+			}
+			if material.Notes == "" {
+				material.Notes = "None"
+			}
+			materials = append(materials, material)
+		}
+	})
+
+	sort.Slice(materials, func(i, j int) bool {
+		if materials[i].IsPrimary != materials[j].IsPrimary {
+			return materials[i].IsPrimary
+		}
+		if materials[i].StockID != materials[j].StockID {
+			return materials[i].StockID < materials[j].StockID
+		}
+		return materials[i].MaterialID < materials[j].MaterialID
+	})
+	materials, total := paginate(materials, page, materialSortFields)
+	return materials, total, nil
+}
+
+func (r materialRepo) Get(ctx context.Context, materialId int) (storage.MaterialDB, error) {
+	var material storage.MaterialDB
+	var ok bool
+	r.a.read(func(d *data) {
+		material, ok = d.materials[materialId]
+	})
+	if !ok {
+		return storage.MaterialDB{}, storage.ErrNotFound
+	}
+	return material, nil
+}
+
+// Transactions are serialized, so the row is already protected from concurrent changes
+func (r materialRepo) GetForUpdate(ctx context.Context, materialId int) (storage.MaterialDB, error) {
+	return r.Get(ctx, materialId)
+}
+
+func (r materialRepo) FindAtLocation(ctx context.Context, stockId string, locationId int, owner string) (int, error) {
+	materialId := 0
+	r.a.read(func(d *data) {
+		for _, material := range d.materials {
+			if material.StockID == stockId && material.LocationID == locationId &&
+				material.LocationID != 0 && material.Owner == owner {
+				materialId = material.MaterialID
+			}
+		}
+	})
+	return materialId, nil
+}
+
+func (r materialRepo) FindUnplaced(ctx context.Context, stockId string, owner string) (int, error) {
+	materialId := 0
+	r.a.read(func(d *data) {
+		for _, material := range d.materials {
+			if material.StockID == stockId && material.LocationID == 0 && material.Owner == owner &&
+				material.MaterialID > materialId {
+				materialId = material.MaterialID
+			}
+		}
+	})
+	return materialId, nil
+}
+
+func (r materialRepo) Create(ctx context.Context, material storage.MaterialDB) (int, error) {
+	err := r.a.write(func(d *data) error {
+		if err := d.checkLocationIsFree(material.LocationID, 0); err != nil {
+			return err
+		}
+		material.MaterialID = d.nextID("materials")
+		material.UpdatedAt = date(material.UpdatedAt)
+		material.WarehouseName, material.CustomerName, material.LocationName = "", "", ""
+		d.materials[material.MaterialID] = material
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return material.MaterialID, nil
+}
+
+func (r materialRepo) AddQuantity(ctx context.Context, materialId int, qty int) error {
+	return r.a.write(func(d *data) error {
+		material, ok := d.materials[materialId]
+		if !ok {
+			return nil
+		}
+		if material.Quantity+qty < 0 {
+			return fmt.Errorf("%w: new row violates check constraint: materials.quantity (%d)", storage.ErrConflict, material.Quantity+qty)
+		}
+		material.Quantity += qty
+		d.materials[materialId] = material
+		return nil
+	})
+}
+
+func (r materialRepo) UpdateNotes(ctx context.Context, materialId int, notes string) error {
+	return r.update(materialId, func(material *storage.MaterialDB) {
+		material.Notes = notes
+	})
+}
+
+func (r materialRepo) Place(ctx context.Context, materialId int, locationId int, qty int, notes string) error {
+	return r.a.write(func(d *data) error {
+		if err := d.checkLocationIsFree(locationId, materialId); err != nil {
+			return err
+		}
+		material, ok := d.materials[materialId]
+		if !ok {
+			return nil
+		}
+		material.LocationID = locationId
+		material.Quantity = qty
+		material.Notes = notes
+		d.materials[materialId] = material
+		return nil
+	})
+}
+
+func (r materialRepo) Unplace(ctx context.Context, materialId int) error {
+	return r.update(materialId, func(material *storage.MaterialDB) {
+		material.LocationID = 0
+		material.Quantity = 0
+	})
+}
+
+func (r materialRepo) SetPrimary(ctx context.Context, materialId int, isPrimary bool) error {
+	return r.update(materialId, func(material *storage.MaterialDB) {
+		material.IsPrimary = isPrimary
+	})
+}
+
+func (r materialRepo) Types(ctx context.Context) ([]string, error) {
+	return append([]string{}, MaterialTypes...), nil
+}
+
+func (r materialRepo) Description(ctx context.Context, stockId string, customerIds []int) (string, error) {
+	description := ""
+	found := false
+	r.a.read(func(d *data) {
+		for _, material := range d.materials {
+			if strings.EqualFold(material.StockID, stockId) && inScope(customerIds, material.CustomerID) {
+				description = material.Description
+				found = true
+				return
+			}
+		}
+	})
+	if !found {
+		return "", storage.ErrNotFound
+	}
+	return description, nil
+}
+
+// As an UPDATE statement, a missing Material is not an error
+func (r materialRepo) update(materialId int, fn func(material *storage.MaterialDB)) error {
+	return r.a.write(func(d *data) error {
+		material, ok := d.materials[materialId]
+		if !ok {
+			return nil
+		}
+		fn(&material)
+		d.materials[materialId] = material
+		return nil
+	})
+}
+
+// A Location keeps one Material only (materials.location_id is UNIQUE)
+func (d *data) checkLocationIsFree(locationId int, materialId int) error {
+	if locationId == 0 {
+		return nil
+	}
+	for _, material := range d.materials {
+		if material.LocationID == locationId && material.MaterialID != materialId {
+			return fmt.Errorf("%w: duplicate key value violates unique constraint: location_id (%d)", storage.ErrConflict, locationId)
+		}
+	}
+	return nil
+}
+
+// Fills the Customer, Location and Warehouse names as the joins of the materials query
+func (d *data) joinMaterial(material storage.MaterialDB) storage.MaterialDB {
+	material.CustomerName = d.customers[material.CustomerID].Name
+	material.LocationName = "None"
+	material.WarehouseName = "None"
+	if location, ok := d.locations[material.LocationID]; ok {
+		material.LocationName = location.name
+		material.WarehouseName = d.warehouses[location.warehouseId].WarehouseName
+	}
+	return material
+}
+
+// Works as ILIKE '%' || substr || '%', an empty substr matches everything
+func contains(s string, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func materialTypeOrder(materialType string) int {
+	for i, t := range MaterialTypes {
+		if t == materialType {
+			return i
+		}
+	}
+	return len(MaterialTypes)
+}
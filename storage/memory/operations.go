@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"inv_app/storage"
+	"slices"
+	"time"
+)
+
+// Default material types of the dual_control_types migration
+var DualControlTypes = []string{"CARDS", "CHIPS"}
+
+type operationRepo struct {
+	a access
+}
+
+func (r operationRepo) DualControlTypes(ctx context.Context) ([]string, error) {
+	var materialTypes []string
+	r.a.read(func(d *data) {
+		materialTypes = slices.Clone(d.dualControl)
+	})
+	if materialTypes == nil {
+		materialTypes = []string{}
+	}
+	slices.Sort(materialTypes)
+	return materialTypes, nil
+}
+
+func (r operationRepo) SetDualControlTypes(ctx context.Context, materialTypes []string) error {
+	return r.a.write(func(d *data) error {
+		sorted := slices.Clone(materialTypes)
+		slices.Sort(sorted)
+		d.dualControl = slices.Compact(sorted)
+		return nil
+	})
+}
+
+func (r operationRepo) Create(ctx context.Context, operation storage.PendingOperation) (int, error) {
+	err := r.a.write(func(d *data) error {
+		if _, ok := d.materials[operation.MaterialID]; !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: material_id (%d)", storage.ErrConflict, operation.MaterialID)
+		}
+		if _, ok := d.requests[operation.RequestID]; operation.RequestID != 0 && !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: request_id (%d)", storage.ErrConflict, operation.RequestID)
+		}
+		if operation.TransactionID != 0 && !slices.ContainsFunc(d.transactions, func(trx storage.Transaction) bool {
+			return trx.TransactionID == operation.TransactionID
+		}) {
+			return fmt.Errorf("%w: insert violates foreign key constraint: transaction_id (%d)", storage.ErrConflict, operation.TransactionID)
+		}
+		if _, ok := d.users[operation.RequestedBy]; !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: requested_by (%d)", storage.ErrConflict, operation.RequestedBy)
+		}
+		operation.OperationID = d.nextID("pending_operations")
+		d.operations[operation.OperationID] = operation
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return operation.OperationID, nil
+}
+
+// Transactions are serialized, so the Operation needs no row lock
+func (r operationRepo) GetForUpdate(ctx context.Context, operationId int) (storage.PendingOperation, error) {
+	var operation storage.PendingOperation
+	var ok bool
+	r.a.read(func(d *data) {
+		operation, ok = d.operations[operationId]
+		operation = d.joinOperation(operation)
+	})
+	if !ok {
+		return storage.PendingOperation{}, storage.ErrNotFound
+	}
+	return operation, nil
+}
+
+func (r operationRepo) List(ctx context.Context, filter storage.OperationFilter, page storage.Page) ([]storage.PendingOperation, int, error) {
+	var operations []storage.PendingOperation
+	r.a.read(func(d *data) {
+		for _, operation := range d.operations {
+			if filter.Status == "" || operation.Status == filter.Status {
+				operations = append(operations, d.joinOperation(operation))
+			}
+		}
+	})
+	slices.SortFunc(operations, func(a, b storage.PendingOperation) int {
+		return cmp.Or(a.RequestedAt.Compare(b.RequestedAt), cmp.Compare(a.OperationID, b.OperationID))
+	})
+	rows, total := paginate(operations, page, operationSortFields)
+	return rows, total, nil
+}
+
+func (r operationRepo) Decide(ctx context.Context, operationId int, status string, decidedBy int, decidedAt time.Time) error {
+	return r.a.write(func(d *data) error {
+		operation, ok := d.operations[operationId]
+		if !ok || operation.Status != "pending" {
+			return storage.ErrNotFound
+		}
+		if status == "approved" && decidedBy == operation.RequestedBy {
+			return fmt.Errorf("%w: update violates check constraint: pending_operations_second_user", storage.ErrConflict)
+		}
+		operation.Status = status
+		operation.DecidedBy = decidedBy
+		operation.DecidedAt = &decidedAt
+		d.operations[operationId] = operation
+		return nil
+	})
+}
+
+func (d *data) joinOperation(operation storage.PendingOperation) storage.PendingOperation {
+	material := d.materials[operation.MaterialID]
+	operation.StockID = material.StockID
+	operation.MaterialType = material.MaterialType
+	return operation
+}
@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"cmp"
+	"inv_app/storage"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The method sorts the rows, already in the default order, by the Page sort field
+// and returns the rows of the Page with the total number of rows.
+func paginate[T any](rows []T, page storage.Page, sortFields map[string]func(a, b T) int) ([]T, int) {
+	if compare, ok := sortFields[page.SortBy]; ok {
+		slices.SortStableFunc(rows, func(a, b T) int {
+			if page.Desc {
+				return compare(b, a)
+			}
+			return compare(a, b)
+		})
+	}
+
+	if rows == nil {
+		rows = []T{}
+	}
+	total := len(rows)
+	start := min(page.Offset, total)
+	end := total
+	if page.Limit > 0 {
+		end = min(start+page.Limit, total)
+	}
+	return rows[start:end], total
+}
+
+// Works as ORDER BY of a TEXT column with a case-insensitive collation
+func compareText(a string, b string) int {
+	return cmp.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func compareMaterialTypes(a string, b string) int {
+	return cmp.Compare(materialTypeOrder(a), materialTypeOrder(b))
+}
+
+func compareIDs(a string, b string) int {
+	aId, _ := strconv.Atoi(a)
+	bId, _ := strconv.Atoi(b)
+	return cmp.Compare(aId, bId)
+}
+
+var materialSortFields = map[string]func(a, b storage.MaterialDB) int{
+	"materialId":    func(a, b storage.MaterialDB) int { return cmp.Compare(a.MaterialID, b.MaterialID) },
+	"stockId":       func(a, b storage.MaterialDB) int { return compareText(a.StockID, b.StockID) },
+	"description":   func(a, b storage.MaterialDB) int { return compareText(a.Description, b.Description) },
+	"customerName":  func(a, b storage.MaterialDB) int { return compareText(a.CustomerName, b.CustomerName) },
+	"locationName":  func(a, b storage.MaterialDB) int { return compareText(a.LocationName, b.LocationName) },
+	"warehouseName": func(a, b storage.MaterialDB) int { return compareText(a.WarehouseName, b.WarehouseName) },
+	"materialType":  func(a, b storage.MaterialDB) int { return compareMaterialTypes(a.MaterialType, b.MaterialType) },
+	"quantity":      func(a, b storage.MaterialDB) int { return cmp.Compare(a.Quantity, b.Quantity) },
+}
+
+var incomingSortFields = map[string]func(a, b storage.IncomingMaterialDB) int{
+	"shippingId":   func(a, b storage.IncomingMaterialDB) int { return compareIDs(a.ShippingID, b.ShippingID) },
+	"stockId":      func(a, b storage.IncomingMaterialDB) int { return compareText(a.StockID, b.StockID) },
+	"description":  func(a, b storage.IncomingMaterialDB) int { return compareText(a.Description, b.Description) },
+	"customerName": func(a, b storage.IncomingMaterialDB) int { return compareText(a.CustomerName, b.CustomerName) },
+	"materialType": func(a, b storage.IncomingMaterialDB) int { return compareMaterialTypes(a.MaterialType, b.MaterialType) },
+	"quantity":     func(a, b storage.IncomingMaterialDB) int { return cmp.Compare(a.Quantity, b.Quantity) },
+	"cost":         func(a, b storage.IncomingMaterialDB) int { return a.Cost.Cmp(b.Cost) },
+}
+
+var requestSortFields = map[string]func(a, b storage.MaterialDB) int{
+	"requestId":         func(a, b storage.MaterialDB) int { return cmp.Compare(a.RequestID, b.RequestID) },
+	"stockId":           func(a, b storage.MaterialDB) int { return compareText(a.StockID, b.StockID) },
+	"description":       func(a, b storage.MaterialDB) int { return compareText(a.Description, b.Description) },
+	"status":            func(a, b storage.MaterialDB) int { return compareText(a.Status, b.Status) },
+	"quantityRequested": func(a, b storage.MaterialDB) int { return cmp.Compare(a.QtyRequested, b.QtyRequested) },
+	"requestedAt":       func(a, b storage.MaterialDB) int { return a.RequestedAt.Compare(b.RequestedAt) },
+}
+
+var locationSortFields = map[string]func(a, b storage.LocationDB) int{
+	"name":          func(a, b storage.LocationDB) int { return compareText(a.Name, b.Name) },
+	"warehouseName": func(a, b storage.LocationDB) int { return compareText(a.WarehouseName, b.WarehouseName) },
+}
+
+var warehouseSortFields = map[string]func(a, b storage.WarehouseDB) int{
+	"name": func(a, b storage.WarehouseDB) int { return compareText(a.WarehouseName, b.WarehouseName) },
+}
+
+var customerSortFields = map[string]func(a, b storage.CustomerDB) int{
+	"name": func(a, b storage.CustomerDB) int { return compareText(a.Name, b.Name) },
+	"code": func(a, b storage.CustomerDB) int { return compareText(a.Code, b.Code) },
+}
+
+var userSortFields = map[string]func(a, b storage.UserDB) int{
+	"userId":   func(a, b storage.UserDB) int { return cmp.Compare(a.UserID, b.UserID) },
+	"username": func(a, b storage.UserDB) int { return compareText(a.Username, b.Username) },
+	"role":     func(a, b storage.UserDB) int { return compareText(a.Role, b.Role) },
+}
+
+var loginSortFields = map[string]func(a, b storage.LoginAttempt) int{
+	"username":    func(a, b storage.LoginAttempt) int { return compareText(a.Username, b.Username) },
+	"attemptedAt": func(a, b storage.LoginAttempt) int { return a.AttemptedAt.Compare(b.AttemptedAt) },
+}
+
+var operationSortFields = map[string]func(a, b storage.PendingOperation) int{
+	"operationId": func(a, b storage.PendingOperation) int { return cmp.Compare(a.OperationID, b.OperationID) },
+	"stockId":     func(a, b storage.PendingOperation) int { return compareText(a.StockID, b.StockID) },
+	"requestedAt": func(a, b storage.PendingOperation) int { return a.RequestedAt.Compare(b.RequestedAt) },
+}
+
+var auditSortFields = map[string]func(a, b storage.AuditEntry) int{
+	"auditId":   func(a, b storage.AuditEntry) int { return cmp.Compare(a.AuditID, b.AuditID) },
+	"username":  func(a, b storage.AuditEntry) int { return compareText(a.Username, b.Username) },
+	"entity":    func(a, b storage.AuditEntry) int { return compareText(a.Entity, b.Entity) },
+	"createdAt": func(a, b storage.AuditEntry) int { return a.CreatedAt.Compare(b.CreatedAt) },
+}
+
+var transactionSortFields = map[string]func(a, b storage.TransactionRecord) int{
+	"stockId":      func(a, b storage.TransactionRecord) int { return compareText(a.StockID, b.StockID) },
+	"materialType": func(a, b storage.TransactionRecord) int { return compareMaterialTypes(a.MaterialType, b.MaterialType) },
+	"quantity":     func(a, b storage.TransactionRecord) int { return cmp.Compare(a.Qty, b.Qty) },
+	"unitCost":     func(a, b storage.TransactionRecord) int { return a.UnitCost.Cmp(b.UnitCost) },
+	"cost":         func(a, b storage.TransactionRecord) int { return a.Cost.Cmp(b.Cost) },
+	"date":         func(a, b storage.TransactionRecord) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+}
+
+var historySortFields = map[string]func(a, b storage.HistoryRecord) int{
+	"transactionId": func(a, b storage.HistoryRecord) int { return cmp.Compare(a.TransactionID, b.TransactionID) },
+	"date":          func(a, b storage.HistoryRecord) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+}
+
+var rateSortFields = map[string]func(a, b storage.ExchangeRate) int{
+	"currency":      func(a, b storage.ExchangeRate) int { return compareText(a.Currency, b.Currency) },
+	"effectiveDate": func(a, b storage.ExchangeRate) int { return cmp.Compare(a.EffectiveDate, b.EffectiveDate) },
+}
+
+var landedCostSortFields = map[string]func(a, b storage.LandedCharge) int{
+	"chargeId":   func(a, b storage.LandedCharge) int { return cmp.Compare(a.ChargeID, b.ChargeID) },
+	"chargeDate": func(a, b storage.LandedCharge) int { return cmp.Compare(a.ChargeDate, b.ChargeDate) },
+	"amount":     func(a, b storage.LandedCharge) int { return a.Amount.Cmp(b.Amount) },
+}
+
+var balanceSortFields = map[string]func(a, b storage.BalanceRecord) int{
+	"stockId":      func(a, b storage.BalanceRecord) int { return compareText(a.StockID, b.StockID) },
+	"description":  func(a, b storage.BalanceRecord) int { return compareText(a.Description, b.Description) },
+	"materialType": func(a, b storage.BalanceRecord) int { return compareMaterialTypes(a.MaterialType, b.MaterialType) },
+	"quantity":     func(a, b storage.BalanceRecord) int { return cmp.Compare(a.Qty, b.Qty) },
+	"totalValue":   func(a, b storage.BalanceRecord) int { return a.TotalValue.Cmp(b.TotalValue) },
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"inv_app/storage"
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+type priceRepo struct {
+	a access
+}
+
+func (r priceRepo) ListAvailable(ctx context.Context, materialId int) ([]storage.Price, error) {
+	prices := []storage.Price{}
+	r.a.read(func(d *data) {
+		for _, price := range d.prices {
+			if price.MaterialID == materialId && price.Qty > 0 {
+				prices = append(prices, price)
+			}
+		}
+	})
+	sort.Slice(prices, func(i, j int) bool {
+		if !prices[i].ReceivedAt.Equal(prices[j].ReceivedAt) {
+			return prices[i].ReceivedAt.Before(prices[j].ReceivedAt)
+		}
+		return prices[i].PriceID < prices[j].PriceID
+	})
+	return prices, nil
+}
+
+func (r priceRepo) ListAvailableForUpdate(ctx context.Context, materialId int) ([]storage.Price, error) {
+	return r.ListAvailable(ctx, materialId)
+}
+
+func (r priceRepo) Create(ctx context.Context, price storage.Price) (int, error) {
+	err := r.a.write(func(d *data) error {
+		if _, ok := d.materials[price.MaterialID]; !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: material_id (%d)", storage.ErrConflict, price.MaterialID)
+		}
+		price.PriceID = d.nextID("prices")
+		d.prices[price.PriceID] = price
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return price.PriceID, nil
+}
+
+// Transactions are serialized, so the Price needs no row lock
+func (r priceRepo) GetForUpdate(ctx context.Context, priceId int) (storage.Price, error) {
+	var price storage.Price
+	var ok bool
+	r.a.read(func(d *data) {
+		price, ok = d.prices[priceId]
+	})
+	if !ok {
+		return storage.Price{}, storage.ErrNotFound
+	}
+	return price, nil
+}
+
+func (r priceRepo) AddQuantity(ctx context.Context, priceId int, qty int) (decimal.Decimal, error) {
+	var cost decimal.Decimal
+	err := r.a.write(func(d *data) error {
+		price, ok := d.prices[priceId]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		if price.Qty+qty < 0 {
+			return fmt.Errorf("%w: new row violates check constraint: prices.quantity (%d)", storage.ErrConflict, price.Qty+qty)
+		}
+		price.Qty += qty
+		d.prices[priceId] = price
+		cost = price.Cost
+		return nil
+	})
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	return cost, nil
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"inv_app/storage"
+	"slices"
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+type exchangeRateRepo struct {
+	a access
+}
+
+func (r exchangeRateRepo) List(ctx context.Context, currency string, page storage.Page) ([]storage.ExchangeRate, int, error) {
+	rates := []storage.ExchangeRate{}
+	r.a.read(func(d *data) {
+		for _, rate := range d.exchangeRates {
+			if currency == "" || rate.Currency == currency {
+				rates = append(rates, rate)
+			}
+		}
+	})
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].EffectiveDate != rates[j].EffectiveDate {
+			return rates[i].EffectiveDate > rates[j].EffectiveDate
+		}
+		return rates[i].Currency < rates[j].Currency
+	})
+	rates, total := paginate(rates, page, rateSortFields)
+	return rates, total, nil
+}
+
+func (r exchangeRateRepo) Upsert(ctx context.Context, rate storage.ExchangeRate) error {
+	return r.a.write(func(d *data) error {
+		for i, other := range d.exchangeRates {
+			if other.Currency == rate.Currency && other.EffectiveDate == rate.EffectiveDate {
+				d.exchangeRates[i] = rate
+				return nil
+			}
+		}
+		d.exchangeRates = append(d.exchangeRates, rate)
+		return nil
+	})
+}
+
+func (r exchangeRateRepo) Missing(ctx context.Context, base string, date string) ([]string, error) {
+	currencies := []string{}
+	r.a.read(func(d *data) {
+		for _, price := range d.prices {
+			if price.Currency == base || slices.Contains(currencies, price.Currency) {
+				continue
+			}
+			if _, ok := rateOf(d, price.Currency, base, date); !ok {
+				currencies = append(currencies, price.Currency)
+			}
+		}
+	})
+	slices.Sort(currencies)
+	return currencies, nil
+}
+
+func (r exchangeRateRepo) Effective(ctx context.Context, currency string, date string) (storage.ExchangeRate, error) {
+	var rate storage.ExchangeRate
+	var ok bool
+	r.a.read(func(d *data) {
+		rate, ok = effectiveRate(d, currency, date)
+	})
+	if !ok {
+		return storage.ExchangeRate{}, storage.ErrNotFound
+	}
+	return rate, nil
+}
+
+// Returns the rate of the Currency effective on the date, 1 for the base currency or no conversion
+func rateOf(d *data, currency string, base string, date string) (decimal.Decimal, bool) {
+	if base == "" || currency == base {
+		return decimal.New(1, 0), true
+	}
+	rate, ok := effectiveRate(d, currency, date)
+	return rate.Rate, ok
+}
+
+// Returns the latest rate of the Currency effective on or before the date
+func effectiveRate(d *data, currency string, date string) (storage.ExchangeRate, bool) {
+	var found *storage.ExchangeRate
+	for i, rate := range d.exchangeRates {
+		if rate.Currency == currency && rate.EffectiveDate <= date && (found == nil || rate.EffectiveDate > found.EffectiveDate) {
+			found = &d.exchangeRates[i]
+		}
+	}
+	if found == nil {
+		return storage.ExchangeRate{}, false
+	}
+	return *found, true
+}
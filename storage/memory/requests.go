@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"inv_app/storage"
+	"slices"
+	"sort"
+)
+
+type requestRepo struct {
+	a access
+}
+
+func (r requestRepo) Create(ctx context.Context, requests []storage.RequestedMaterial) error {
+	return r.a.write(func(d *data) error {
+		for _, m := range requests {
+			requestId := d.nextID("requested_materials")
+			d.requests[requestId] = request{
+				MaterialDB: storage.MaterialDB{
+					RequestID:    requestId,
+					StockID:      m.StockID,
+					Description:  m.Description,
+					QtyRequested: m.Qty,
+					Status:       m.Status,
+					Notes:        m.Notes,
+					UpdatedAt:    date(m.RequestedAt),
+					RequestedAt:  date(m.RequestedAt),
+				},
+				userId: m.UserID,
+			}
+		}
+		return nil
+	})
+}
+
+func (r requestRepo) List(ctx context.Context, filterOpts storage.MaterialFilter, page storage.Page) ([]storage.MaterialDB, int, error) {
+	var materials []storage.MaterialDB
+	r.a.read(func(d *data) {
+		for _, req := range d.requests {
+			if (filterOpts.RequestId != 0 && req.RequestID != filterOpts.RequestId) ||
+				!contains(req.StockID, filterOpts.StockId) ||
+				(filterOpts.Status != "" && req.Status != filterOpts.Status) ||
+				(filterOpts.RequestedAt != "" && dateString(req.RequestedAt) > filterOpts.RequestedAt) ||
+				!d.stockInScope(filterOpts.CustomerIDs, req.StockID) {
+				continue
+			}
+			material := req.MaterialDB
+			material.UserName = d.users[req.userId].Username
+			materials = append(materials, material)
+		}
+	})
+	sort.Slice(materials, func(i, j int) bool {
+		if !materials[i].RequestedAt.Equal(materials[j].RequestedAt) {
+			return materials[i].RequestedAt.Before(materials[j].RequestedAt)
+		}
+		return materials[i].RequestID < materials[j].RequestID
+	})
+	materials, total := paginate(materials, page, requestSortFields)
+	return materials, total, nil
+}
+
+func (r requestRepo) Update(ctx context.Context, request storage.RequestUpdate) error {
+	return r.a.write(func(d *data) error {
+		req, ok := d.requests[request.RequestID]
+		if !ok {
+			return nil
+		}
+		req.QtyUsed += request.QtyUsed
+		req.Status = request.Status
+		req.Notes = request.Notes
+		req.UpdatedAt = date(request.UpdatedAt)
+		d.requests[request.RequestID] = req
+		return nil
+	})
+}
+
+// Requests have no Customer, they are in scope if a Material of a Customer in scope has the Stock ID
+func (d *data) stockInScope(customerIds []int, stockId string) bool {
+	if customerIds == nil {
+		return true
+	}
+	for _, material := range d.materials {
+		if material.StockID == stockId && slices.Contains(customerIds, material.CustomerID) {
+			return true
+		}
+	}
+	return false
+}
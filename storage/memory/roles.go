@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"inv_app/storage"
+	"slices"
+	"sort"
+)
+
+// Default Roles and Permissions as seeded by the role_permissions migration
+var Permissions = []string{
+	"materials.read", "reports.read", "incoming.create", "incoming.update", "materials.receive",
+	"materials.move", "materials.remove", "materials.update", "vault.manage", "requests.create",
+	"requests.update", "customers.create", "warehouses.create", "data.import", "roles.manage",
+	"users.manage", "audit.read", "transactions.reverse", "costing.manage", "rates.manage",
+}
+
+var RolePermissions = map[string][]string{
+	"admin": Permissions,
+	"warehouse": {
+		"materials.read", "reports.read", "incoming.create", "materials.receive", "materials.move",
+		"materials.remove", "materials.update", "requests.update", "warehouses.create",
+	},
+	"csr":        {"materials.read", "reports.read", "incoming.create", "incoming.update", "customers.create", "requests.create"},
+	"production": {"materials.read", "requests.create"},
+	"vault": {
+		"materials.read", "reports.read", "materials.receive", "materials.move", "materials.remove",
+		"vault.manage", "requests.update",
+	},
+	"viewer":   {"materials.read", "reports.read"},
+	"customer": {"materials.read", "reports.read"},
+}
+
+// Roles whose Users see the data of their own Customers only
+var CustomerScopedRoles = []string{"customer"}
+
+type roleRepo struct {
+	a access
+}
+
+func (r roleRepo) List(ctx context.Context) ([]storage.RoleDB, error) {
+	roles := []storage.RoleDB{}
+	r.a.read(func(d *data) {
+		for name, permissions := range d.roles {
+			roles = append(roles, storage.RoleDB{
+				Name:           name,
+				Permissions:    sortedPermissions(permissions),
+				CustomerScoped: slices.Contains(CustomerScopedRoles, name),
+			})
+		}
+	})
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+	return roles, nil
+}
+
+func (r roleRepo) Permissions(ctx context.Context, role string) ([]string, error) {
+	var permissions []string
+	r.a.read(func(d *data) {
+		permissions = sortedPermissions(d.roles[role])
+	})
+	return permissions, nil
+}
+
+func (r roleRepo) ListPermissions(ctx context.Context) ([]storage.PermissionDB, error) {
+	permissions := []storage.PermissionDB{}
+	for _, name := range sortedPermissions(Permissions) {
+		permissions = append(permissions, storage.PermissionDB{Name: name})
+	}
+	return permissions, nil
+}
+
+func (r roleRepo) IsCustomerScoped(ctx context.Context, role string) (bool, error) {
+	return slices.Contains(CustomerScopedRoles, role), nil
+}
+
+func (r roleRepo) SetPermissions(ctx context.Context, role string, permissions []string) error {
+	return r.a.write(func(d *data) error {
+		if _, ok := d.roles[role]; !ok {
+			return storage.ErrNotFound
+		}
+		for _, permission := range permissions {
+			if !slices.Contains(Permissions, permission) {
+				return fmt.Errorf("%w: insert violates foreign key constraint: permission (%s)", storage.ErrConflict, permission)
+			}
+		}
+		d.roles[role] = slices.Clone(permissions)
+		return nil
+	})
+}
+
+func sortedPermissions(permissions []string) []string {
+	sorted := slices.Clone(permissions)
+	if sorted == nil {
+		sorted = []string{}
+	}
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
+}
@@ -0,0 +1,310 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"inv_app/storage"
+	"slices"
+	"sync"
+	"time"
+)
+
+// The package keeps the whole Storage in memory. It mirrors the Postgres behaviour closely enough
+// to run the Business Logic in tests without a DB: IDs are serial, unique constraints are checked,
+// DATE columns are truncated, and Transactions are isolated, serialized and can be rolled back.
+
+var errTxFailed = errors.New("Current transaction is aborted, commands ignored until end of transaction block")
+
+// Default values of the material_type enum
+var MaterialTypes = []string{
+	"ACT LABEL", "BUBBLE", "BURGO", "CARRIER", "ENVELOPE", "FREE SHIPPING", "INSERT", "KEYCHAIN",
+	"LABELS", "PAPER", "PRINT", "RIBBON", "SHIPPING", "STICKER", "WEARABLE", "CHIPS", "CARDS",
+}
+
+type request struct {
+	storage.MaterialDB
+	userId int
+}
+
+type location struct {
+	id          int
+	name        string
+	warehouseId int
+}
+
+type data struct {
+	materials     map[int]storage.MaterialDB
+	prices        map[int]storage.Price
+	transactions  []storage.Transaction
+	incoming      map[int]storage.IncomingMaterialDB
+	locations     map[int]location
+	warehouses    map[int]storage.WarehouseDB
+	customers     map[int]storage.CustomerDB
+	requests      map[int]request
+	users         map[int]storage.UserDB
+	userCustomers map[int][]int
+	sessions      map[string]storage.Session
+	roles         map[string][]string
+	throttles     map[string]storage.LoginThrottle
+	logins        []storage.LoginAttempt
+	audit         []storage.AuditEntry
+	apiKeys       map[int]storage.APIKey
+	dualControl   []string
+	operations    map[int]storage.PendingOperation
+	costingRules  []storage.CostingRule
+	exchangeRates []storage.ExchangeRate
+	landedCharges []storage.LandedCharge
+	sequences     map[string]int
+}
+
+func newData() *data {
+	return &data{
+		materials:     make(map[int]storage.MaterialDB),
+		prices:        make(map[int]storage.Price),
+		incoming:      make(map[int]storage.IncomingMaterialDB),
+		locations:     make(map[int]location),
+		warehouses:    make(map[int]storage.WarehouseDB),
+		customers:     make(map[int]storage.CustomerDB),
+		requests:      make(map[int]request),
+		users:         make(map[int]storage.UserDB),
+		userCustomers: make(map[int][]int),
+		sessions:      make(map[string]storage.Session),
+		roles:         make(map[string][]string),
+		throttles:     make(map[string]storage.LoginThrottle),
+		apiKeys:       make(map[int]storage.APIKey),
+		operations:    make(map[int]storage.PendingOperation),
+		sequences:     make(map[string]int),
+	}
+}
+
+func (d *data) clone() *data {
+	c := newData()
+	for k, v := range d.materials {
+		c.materials[k] = v
+	}
+	for k, v := range d.prices {
+		c.prices[k] = v
+	}
+	c.transactions = append(c.transactions, d.transactions...)
+	for k, v := range d.incoming {
+		c.incoming[k] = v
+	}
+	for k, v := range d.locations {
+		c.locations[k] = v
+	}
+	for k, v := range d.warehouses {
+		c.warehouses[k] = v
+	}
+	for k, v := range d.customers {
+		c.customers[k] = v
+	}
+	for k, v := range d.requests {
+		c.requests[k] = v
+	}
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	for k, v := range d.userCustomers {
+		c.userCustomers[k] = v
+	}
+	for k, v := range d.sessions {
+		c.sessions[k] = v
+	}
+	for k, v := range d.roles {
+		c.roles[k] = v
+	}
+	for k, v := range d.throttles {
+		c.throttles[k] = v
+	}
+	c.logins = append(c.logins, d.logins...)
+	c.audit = append(c.audit, d.audit...)
+	for k, v := range d.apiKeys {
+		c.apiKeys[k] = v
+	}
+	c.dualControl = d.dualControl
+	for k, v := range d.operations {
+		c.operations[k] = v
+	}
+	c.costingRules = d.costingRules
+	c.exchangeRates = append(c.exchangeRates, d.exchangeRates...)
+	c.landedCharges = append(c.landedCharges, d.landedCharges...)
+	for k, v := range d.sequences {
+		c.sequences[k] = v
+	}
+	return c
+}
+
+// Works as a SERIAL column
+func (d *data) nextID(table string) int {
+	d.sequences[table]++
+	return d.sequences[table]
+}
+
+// access is implemented by the Store (every write is committed at once) and by Tx.
+type access interface {
+	read(fn func(d *data))
+	write(fn func(d *data) error) error
+}
+
+type repositories struct {
+	a access
+}
+
+func (r repositories) Materials() storage.MaterialRepository       { return materialRepo{r.a} }
+func (r repositories) Prices() storage.PriceRepository             { return priceRepo{r.a} }
+func (r repositories) Transactions() storage.TransactionRepository { return transactionRepo{r.a} }
+func (r repositories) Incoming() storage.IncomingRepository        { return incomingRepo{r.a} }
+func (r repositories) Locations() storage.LocationRepository       { return locationRepo{r.a} }
+func (r repositories) Warehouses() storage.WarehouseRepository     { return warehouseRepo{r.a} }
+func (r repositories) Customers() storage.CustomerRepository       { return customerRepo{r.a} }
+func (r repositories) Requests() storage.RequestRepository         { return requestRepo{r.a} }
+func (r repositories) Users() storage.UserRepository               { return userRepo{r.a} }
+func (r repositories) Sessions() storage.SessionRepository         { return sessionRepo{r.a} }
+func (r repositories) Roles() storage.RoleRepository               { return roleRepo{r.a} }
+func (r repositories) Logins() storage.LoginRepository             { return loginRepo{r.a} }
+func (r repositories) APIKeys() storage.APIKeyRepository           { return apiKeyRepo{r.a} }
+func (r repositories) Operations() storage.OperationRepository     { return operationRepo{r.a} }
+func (r repositories) Audit() storage.AuditRepository              { return auditRepo{r.a} }
+func (r repositories) Costing() storage.CostingRepository          { return costingRepo{r.a} }
+func (r repositories) ExchangeRates() storage.ExchangeRateRepository {
+	return exchangeRateRepo{r.a}
+}
+func (r repositories) LandedCosts() storage.LandedCostRepository { return landedCostRepo{r.a} }
+
+// Store is the in-memory implementation of storage.Store.
+// Transactions are serialized: Begin blocks until the previous Transaction is committed or rolled back,
+// so a goroutine must not use the Store directly while it keeps a Transaction open.
+type Store struct {
+	repositories
+	writeMutex sync.Mutex
+	dataMutex  sync.RWMutex
+	data       *data
+}
+
+func New() *Store {
+	s := &Store{data: newData()}
+	for role, permissions := range RolePermissions {
+		s.data.roles[role] = permissions
+	}
+	s.data.dualControl = DualControlTypes
+	s.repositories = repositories{a: s}
+	return s
+}
+
+// The method adds a User without the Role and username checks of Users().Create and returns its ID.
+func (s *Store) AddUser(user storage.UserDB) int {
+	s.write(func(d *data) error {
+		user.UserID = d.nextID("users")
+		d.users[user.UserID] = user
+		return nil
+	})
+	return user.UserID
+}
+
+func (s *Store) read(fn func(d *data)) {
+	s.dataMutex.RLock()
+	defer s.dataMutex.RUnlock()
+	fn(s.data)
+}
+
+func (s *Store) write(fn func(d *data) error) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	changed := s.data.clone()
+	if err := fn(changed); err != nil {
+		return err
+	}
+	s.commit(changed)
+	return nil
+}
+
+func (s *Store) commit(changed *data) {
+	s.dataMutex.Lock()
+	defer s.dataMutex.Unlock()
+	s.data = changed
+}
+
+func (s *Store) Begin(ctx context.Context) (storage.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.writeMutex.Lock()
+
+	s.dataMutex.RLock()
+	snapshot := s.data.clone()
+	s.dataMutex.RUnlock()
+
+	tx := &Tx{store: s, data: snapshot}
+	tx.repositories = repositories{a: tx}
+	return tx, nil
+}
+
+// Tx works on its own copy of the data which replaces the Store data on Commit.
+type Tx struct {
+	repositories
+	store  *Store
+	data   *data
+	done   bool
+	failed bool
+}
+
+func (t *Tx) read(fn func(d *data)) {
+	fn(t.data)
+}
+
+// As in Postgres, the first failed statement aborts the whole Transaction
+func (t *Tx) write(fn func(d *data) error) error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	if t.failed {
+		return errTxFailed
+	}
+	if err := fn(t.data); err != nil {
+		t.failed = true
+		return err
+	}
+	return nil
+}
+
+func (t *Tx) Commit() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	defer t.store.writeMutex.Unlock()
+
+	if t.failed {
+		return errTxFailed
+	}
+	t.store.commit(t.data)
+	return nil
+}
+
+func (t *Tx) Rollback() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	t.store.writeMutex.Unlock()
+	return nil
+}
+
+// Works as "customer_id = ANY(...)", a nil list of Customer IDs disables the filter
+func inScope(customerIds []int, customerId int) bool {
+	return customerIds == nil || slices.Contains(customerIds, customerId)
+}
+
+// DATE columns keep no time
+func date(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func dateString(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+var _ storage.Store = (*Store)(nil)
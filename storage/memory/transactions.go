@@ -0,0 +1,281 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"inv_app/storage"
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+type transactionRepo struct {
+	a access
+}
+
+// Transactions are serialized, so the chain needs no lock
+func (r transactionRepo) LockChain(ctx context.Context) error {
+	return nil
+}
+
+func (r transactionRepo) Add(ctx context.Context, trx storage.Transaction) error {
+	return r.a.write(func(d *data) error {
+		if _, ok := d.prices[trx.PriceID]; !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: price_id (%d)", storage.ErrConflict, trx.PriceID)
+		}
+		if _, ok := d.requests[trx.RequestID]; trx.RequestID != 0 && !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: request_id (%d)", storage.ErrConflict, trx.RequestID)
+		}
+		if trx.ReversesID != 0 {
+			found := false
+			for _, other := range d.transactions {
+				if other.ReversesID == trx.ReversesID {
+					return fmt.Errorf("%w: duplicate key value violates unique constraint: reverses_id (%d)", storage.ErrConflict, trx.ReversesID)
+				}
+				found = found || other.TransactionID == trx.ReversesID
+			}
+			if !found {
+				return fmt.Errorf("%w: insert violates foreign key constraint: reverses_id (%d)", storage.ErrConflict, trx.ReversesID)
+			}
+		}
+		trx.UpdatedAt = date(trx.UpdatedAt)
+		trx.TransactionID = d.nextID("transactions_log")
+		if len(d.transactions) > 0 {
+			trx.PrevHash = d.transactions[len(d.transactions)-1].Hash
+		}
+		trx.Hash = storage.TransactionHash(trx)
+		d.transactions = append(d.transactions, trx)
+		return nil
+	})
+}
+
+func (r transactionRepo) Chain(ctx context.Context, afterId int, limit int) ([]storage.Transaction, error) {
+	trxList := []storage.Transaction{}
+	r.a.read(func(d *data) {
+		for _, trx := range d.transactions {
+			if trx.TransactionID > afterId && len(trxList) < limit {
+				trxList = append(trxList, trx)
+			}
+		}
+	})
+	return trxList, nil
+}
+
+func (r transactionRepo) ListCorrelated(ctx context.Context, transactionId int) ([]storage.Transaction, error) {
+	trxList := []storage.Transaction{}
+	r.a.read(func(d *data) {
+		correlationId := ""
+		for _, trx := range d.transactions {
+			if trx.TransactionID == transactionId {
+				correlationId = trx.CorrelationID
+			}
+		}
+		for _, trx := range d.transactions {
+			if trx.TransactionID == transactionId || (correlationId != "" && trx.CorrelationID == correlationId) {
+				trxList = append(trxList, trx)
+			}
+		}
+	})
+	if len(trxList) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	return trxList, nil
+}
+
+func (r transactionRepo) ListByPrice(ctx context.Context, priceId int) ([]storage.Transaction, error) {
+	trxList := []storage.Transaction{}
+	r.a.read(func(d *data) {
+		for _, trx := range d.transactions {
+			if trx.PriceID == priceId {
+				trxList = append(trxList, trx)
+			}
+		}
+	})
+	return trxList, nil
+}
+
+func (r transactionRepo) ListReceipts(ctx context.Context, shippingId int) ([]storage.Transaction, error) {
+	trxList := []storage.Transaction{}
+	r.a.read(func(d *data) {
+		for _, trx := range d.transactions {
+			if trx.ShippingID == shippingId && trx.Type == storage.TransactionReceipt {
+				trxList = append(trxList, trx)
+			}
+		}
+	})
+	return trxList, nil
+}
+
+func (r transactionRepo) ReversalOf(ctx context.Context, transactionId int) (int, error) {
+	reversalId := 0
+	r.a.read(func(d *data) {
+		for _, trx := range d.transactions {
+			if trx.ReversesID == transactionId {
+				reversalId = trx.TransactionID
+			}
+		}
+	})
+	return reversalId, nil
+}
+
+func (r transactionRepo) ChainHead(ctx context.Context) (int, string, error) {
+	var transactionId int
+	var hash string
+	r.a.read(func(d *data) {
+		if len(d.transactions) > 0 {
+			last := d.transactions[len(d.transactions)-1]
+			transactionId, hash = last.TransactionID, last.Hash
+		}
+	})
+	return transactionId, hash, nil
+}
+
+func (r transactionRepo) Report(ctx context.Context, filter storage.ReportFilter, page storage.Page) ([]storage.TransactionRecord, int, error) {
+	trxList := []storage.TransactionRecord{}
+	r.a.read(func(d *data) {
+		for _, trx := range d.transactions {
+			price := d.prices[trx.PriceID]
+			material := d.materials[price.MaterialID]
+			updatedAt := dateString(trx.UpdatedAt)
+
+			if (filter.CustomerId != 0 && material.CustomerID != filter.CustomerId) ||
+				(filter.MaterialType != "" && material.MaterialType != filter.MaterialType) ||
+				(filter.DateFrom != "" && updatedAt < filter.DateFrom) ||
+				(filter.DateTo != "" && updatedAt > filter.DateTo) ||
+				(filter.Owner != "" && material.Owner != filter.Owner) ||
+				!inScope(filter.CustomerIDs, material.CustomerID) {
+				continue
+			}
+
+			rate, _ := rateOf(d, price.Currency, filter.Currency, filter.RateDate)
+			trxList = append(trxList, storage.TransactionRecord{
+				StockID:           material.StockID,
+				MaterialType:      material.MaterialType,
+				Type:              trx.Type,
+				Qty:               trx.Qty,
+				UnitCost:          storage.UnitCost(price.Cost.Mul(rate)),
+				Cost:              storage.ConvertedValue(trx.Qty, price.Cost, rate),
+				LandedCost:        storage.ConvertedValue(trx.Qty, price.LandedCost, rate),
+				Currency:          rowCurrency(filter.Currency, price),
+				UpdatedAt:         trx.UpdatedAt,
+				SerialNumberRange: trx.SerialNumberRange,
+			})
+		}
+	})
+	trxList, total := paginate(trxList, page, transactionSortFields)
+	return trxList, total, nil
+}
+
+func (r transactionRepo) Balance(ctx context.Context, filter storage.ReportFilter, page storage.Page) ([]storage.BalanceRecord, int, error) {
+	type balanceKey struct {
+		stockId      string
+		description  string
+		materialType string
+		currency     string
+	}
+	balances := make(map[balanceKey]*storage.BalanceRecord)
+
+	r.a.read(func(d *data) {
+		for _, trx := range d.transactions {
+			price := d.prices[trx.PriceID]
+			material := d.materials[price.MaterialID]
+
+			if (filter.CustomerId != 0 && material.CustomerID != filter.CustomerId) ||
+				(filter.MaterialType != "" && material.MaterialType != filter.MaterialType) ||
+				(filter.DateAsOf != "" && dateString(trx.UpdatedAt) > filter.DateAsOf) ||
+				(filter.Owner != "" && material.Owner != filter.Owner) ||
+				!inScope(filter.CustomerIDs, material.CustomerID) ||
+				material.LocationID == 0 {
+				continue
+			}
+
+			currency := rowCurrency(filter.Currency, price)
+			key := balanceKey{material.StockID, material.Description, material.MaterialType, currency}
+			balance, ok := balances[key]
+			if !ok {
+				balance = &storage.BalanceRecord{
+					StockID:      material.StockID,
+					Description:  material.Description,
+					MaterialType: material.MaterialType,
+					Currency:     currency,
+				}
+				balances[key] = balance
+			}
+			rate, _ := rateOf(d, price.Currency, filter.Currency, filter.RateDate)
+			balance.Qty += trx.Qty
+			balance.TotalValue = balance.TotalValue.Add(storage.ConvertedValue(trx.Qty, price.Cost, rate))
+			balance.LandedValue = balance.LandedValue.Add(storage.ConvertedValue(trx.Qty, price.LandedCost, rate))
+		}
+	})
+
+	blcList := []storage.BalanceRecord{}
+	for _, balance := range balances {
+		blcList = append(blcList, *balance)
+	}
+	sort.Slice(blcList, func(i, j int) bool {
+		iOrder, jOrder := materialTypeOrder(blcList[i].MaterialType), materialTypeOrder(blcList[j].MaterialType)
+		if iOrder != jOrder {
+			return iOrder < jOrder
+		}
+		if blcList[i].Description != blcList[j].Description {
+			return blcList[i].Description < blcList[j].Description
+		}
+		if blcList[i].StockID != blcList[j].StockID {
+			return blcList[i].StockID < blcList[j].StockID
+		}
+		return blcList[i].Currency < blcList[j].Currency
+	})
+	blcList, total := paginate(blcList, page, balanceSortFields)
+	return blcList, total, nil
+}
+
+func (r transactionRepo) History(ctx context.Context, materialId int, page storage.Page) ([]storage.HistoryRecord, int, error) {
+	history := []storage.HistoryRecord{}
+	r.a.read(func(d *data) {
+		// The value balance is kept per currency
+		qtyBalance, valueBalances := 0, map[string]decimal.Decimal{}
+		for _, trx := range d.transactions {
+			price := d.prices[trx.PriceID]
+			if price.MaterialID != materialId {
+				continue
+			}
+
+			value := storage.ExtendedValue(trx.Qty, price.Cost)
+			qtyBalance += trx.Qty
+			valueBalances[price.Currency] = valueBalances[price.Currency].Add(value)
+			history = append(history, storage.HistoryRecord{
+				TransactionID:     trx.TransactionID,
+				Type:              trx.Type,
+				Qty:               trx.Qty,
+				UnitCost:          price.Cost,
+				Value:             value,
+				LandedCost:        price.LandedCost,
+				Currency:          price.Currency,
+				QtyBalance:        qtyBalance,
+				ValueBalance:      valueBalances[price.Currency],
+				Notes:             trx.Notes,
+				JobTicket:         trx.JobTicket,
+				SerialNumberRange: trx.SerialNumberRange,
+				UpdatedAt:         trx.UpdatedAt,
+				UserID:            trx.UserID,
+				Username:          d.users[trx.UserID].Username,
+				ApprovedBy:        trx.ApprovedBy,
+				FromLocation:      d.locations[trx.FromLocationID].name,
+				ToLocation:        d.locations[trx.ToLocationID].name,
+				ShippingID:        trx.ShippingID,
+				RequestID:         trx.RequestID,
+				CorrelationID:     trx.CorrelationID,
+			})
+		}
+	})
+	history, total := paginate(history, page, historySortFields)
+	return history, total, nil
+}
+
+// The currency of the report row: the report Currency or the one of the Price
+func rowCurrency(currency string, price storage.Price) string {
+	if currency != "" {
+		return currency
+	}
+	return price.Currency
+}
@@ -0,0 +1,207 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"inv_app/storage"
+	"slices"
+	"time"
+)
+
+type userRepo struct {
+	a access
+}
+
+func (r userRepo) Get(ctx context.Context, userId int) (storage.UserDB, error) {
+	var user storage.UserDB
+	var ok bool
+	r.a.read(func(d *data) {
+		user, ok = d.users[userId]
+	})
+	if !ok {
+		return storage.UserDB{}, storage.ErrNotFound
+	}
+	return user, nil
+}
+
+func (r userRepo) FindByUsername(ctx context.Context, username string) (storage.UserDB, error) {
+	var user storage.UserDB
+	found := false
+	r.a.read(func(d *data) {
+		for _, u := range d.users {
+			if u.Username == username {
+				user, found = u, true
+				return
+			}
+		}
+	})
+	if !found {
+		return storage.UserDB{}, storage.ErrNotFound
+	}
+	return user, nil
+}
+
+func (r userRepo) List(ctx context.Context, page storage.Page) ([]storage.UserDB, int, error) {
+	var users []storage.UserDB
+	r.a.read(func(d *data) {
+		for _, user := range d.users {
+			user.Password = ""
+			users = append(users, user)
+		}
+	})
+	slices.SortFunc(users, func(a, b storage.UserDB) int {
+		return cmp.Or(compareText(a.Username, b.Username), cmp.Compare(a.UserID, b.UserID))
+	})
+	rows, total := paginate(users, page, userSortFields)
+	return rows, total, nil
+}
+
+func (r userRepo) Create(ctx context.Context, user storage.UserDB) (int, error) {
+	err := r.a.write(func(d *data) error {
+		if _, ok := d.roles[user.Role]; !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: role (%s)", storage.ErrConflict, user.Role)
+		}
+		for _, u := range d.users {
+			if u.Username == user.Username {
+				return fmt.Errorf("%w: duplicate key value violates unique constraint: username", storage.ErrConflict)
+			}
+		}
+		user.UserID = d.nextID("users")
+		d.users[user.UserID] = user
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return user.UserID, nil
+}
+
+func (r userRepo) SetPassword(ctx context.Context, userId int, passwordHash string) error {
+	return r.update(userId, func(d *data, user *storage.UserDB) error {
+		user.Password = passwordHash
+		return nil
+	})
+}
+
+func (r userRepo) SetRole(ctx context.Context, userId int, role string) error {
+	return r.update(userId, func(d *data, user *storage.UserDB) error {
+		if _, ok := d.roles[role]; !ok {
+			return fmt.Errorf("%w: update violates foreign key constraint: role (%s)", storage.ErrConflict, role)
+		}
+		user.Role = role
+		return nil
+	})
+}
+
+func (r userRepo) SetActive(ctx context.Context, userId int, isActive bool) error {
+	return r.update(userId, func(d *data, user *storage.UserDB) error {
+		user.IsActive = isActive
+		return nil
+	})
+}
+
+func (r userRepo) CustomerIDs(ctx context.Context, userId int) ([]int, error) {
+	var customerIds []int
+	r.a.read(func(d *data) {
+		customerIds = slices.Clone(d.userCustomers[userId])
+	})
+	if customerIds == nil {
+		customerIds = []int{}
+	}
+	return customerIds, nil
+}
+
+func (r userRepo) SetCustomers(ctx context.Context, userId int, customerIds []int) error {
+	return r.a.write(func(d *data) error {
+		if _, ok := d.users[userId]; !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: user_id (%d)", storage.ErrConflict, userId)
+		}
+		for _, customerId := range customerIds {
+			if _, ok := d.customers[customerId]; !ok {
+				return fmt.Errorf("%w: insert violates foreign key constraint: customer_id (%d)", storage.ErrConflict, customerId)
+			}
+		}
+		sorted := slices.Clone(customerIds)
+		slices.Sort(sorted)
+		d.userCustomers[userId] = slices.Compact(sorted)
+		return nil
+	})
+}
+
+// Returns ErrNotFound if there is no such User
+func (r userRepo) update(userId int, fn func(d *data, user *storage.UserDB) error) error {
+	return r.a.write(func(d *data) error {
+		user, ok := d.users[userId]
+		if !ok {
+			return storage.ErrNotFound
+		}
+		if err := fn(d, &user); err != nil {
+			return err
+		}
+		d.users[userId] = user
+		return nil
+	})
+}
+
+type sessionRepo struct {
+	a access
+}
+
+func (r sessionRepo) Create(ctx context.Context, session storage.Session) error {
+	return r.a.write(func(d *data) error {
+		if _, ok := d.users[session.UserID]; !ok {
+			return fmt.Errorf("%w: insert violates foreign key constraint: user_id (%d)", storage.ErrConflict, session.UserID)
+		}
+		if _, ok := d.sessions[session.TokenHash]; ok {
+			return fmt.Errorf("%w: duplicate key value violates unique constraint: token_hash", storage.ErrConflict)
+		}
+		d.sessions[session.TokenHash] = session
+		return nil
+	})
+}
+
+func (r sessionRepo) GetUser(ctx context.Context, tokenHash string, now time.Time) (storage.UserDB, error) {
+	var user storage.UserDB
+	found := false
+	r.a.read(func(d *data) {
+		session, ok := d.sessions[tokenHash]
+		if ok && session.ExpiresAt.After(now) {
+			user, found = d.users[session.UserID]
+			found = found && user.IsActive
+		}
+	})
+	if !found {
+		return storage.UserDB{}, storage.ErrNotFound
+	}
+	return user, nil
+}
+
+func (r sessionRepo) Delete(ctx context.Context, tokenHash string) error {
+	return r.a.write(func(d *data) error {
+		delete(d.sessions, tokenHash)
+		return nil
+	})
+}
+
+func (r sessionRepo) DeleteForUser(ctx context.Context, userId int, keepTokenHash string) error {
+	return r.a.write(func(d *data) error {
+		for tokenHash, session := range d.sessions {
+			if session.UserID == userId && tokenHash != keepTokenHash {
+				delete(d.sessions, tokenHash)
+			}
+		}
+		return nil
+	})
+}
+
+func (r sessionRepo) DeleteExpired(ctx context.Context, now time.Time) error {
+	return r.a.write(func(d *data) error {
+		for tokenHash, session := range d.sessions {
+			if !session.ExpiresAt.After(now) {
+				delete(d.sessions, tokenHash)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,425 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type MaterialDB struct {
+	MaterialID        int       `field:"material_id"`
+	WarehouseName     string    `field:"warehouse_name"`
+	StockID           string    `field:"stock_id"`
+	CustomerID        int       `field:"customer_id"`
+	CustomerName      string    `field:"customer_name"`
+	LocationID        int       `field:"location_id"`
+	LocationName      string    `field:"location_name"`
+	MaterialType      string    `field:"material_type"`
+	Description       string    `field:"description"`
+	Notes             string    `field:"notes"`
+	Quantity          int       `field:"quantity"`
+	UpdatedAt         time.Time `field:"updated_at"`
+	IsActive          bool      `field:"is_active"`
+	MinQty            int       `field:"min_required_quantity"`
+	MaxQty            int       `field:"max_required_quantity"`
+	Owner             string    `field:"onwer"`
+	IsPrimary         bool      `field:"is_primary"`
+	SerialNumberRange string    `field:"serial_number_range"`
+	RequestID         int       `field:"request_id"`
+	UserName          string    `field:"username"`
+	Status            string    `field:"status"`
+	QtyRequested      int       `field:"quantity_requested"`
+	QtyUsed           int       `field:"quantity_used"`
+	RequestedAt       time.Time `field:"requested_at"`
+}
+
+type MaterialFilter struct {
+	MaterialId   int
+	StockId      string
+	CustomerName string
+	Description  string
+	LocationName string
+	Status       string
+	RequestId    int
+	RequestedAt  string
+	// Only the Materials of these Customers if not nil, an empty list matches none
+	CustomerIDs []int
+}
+
+// IncomingFilter selects one Incoming Material by its Shipping ID, all of them if 0
+type IncomingFilter struct {
+	ShippingID  int
+	CustomerIDs []int
+}
+
+type IncomingMaterialDB struct {
+	ShippingID   string          `field:"shipping_id"`
+	CustomerName string          `field:"customer_name"`
+	CustomerID   int             `field:"customer_id"`
+	StockID      string          `field:"stock_id"`
+	Cost         decimal.Decimal `field:"cost"`
+	Currency     string          `field:"currency"`
+	UnitWeight   decimal.Decimal `field:"unit_weight"`
+	Quantity     int             `field:"quantity"`
+	MinQty       int             `field:"min_required_quantity"`
+	MaxQty       int             `field:"max_required_quantity"`
+	Description  string          `field:"description"`
+	IsActive     bool            `field:"is_active"`
+	MaterialType string          `field:"material_type"`
+	Owner        string          `field:"owner"`
+	UserID       int             `field:"user_id"`
+	UserName     string          `field:"username"`
+	// The landed charges allocated per unit, added to the Cost of the layers on the receipt.
+	// LandedValue is their value for the Quantity still incoming, the last receipt gets what is left of it.
+	LandedCost  decimal.Decimal `field:"landed_cost"`
+	LandedValue decimal.Decimal `field:"landed_value"`
+}
+
+// Price is a cost layer: the quantity of one receipt still in stock with its unit cost.
+// A moved layer keeps the receipt time, so the stock is consumed in the receipt order at any Location.
+type Price struct {
+	PriceID     int             `field:"price_id"`
+	MaterialID  int             `field:"material_id"`
+	Qty         int             `field:"quantity"`
+	Cost        decimal.Decimal `field:"cost"`
+	Currency    string          `field:"currency"`
+	ReceivedAt  time.Time       `field:"received_at"`
+	OriginalQty int             `field:"original_quantity"`
+	// The part of the Cost from the landed charges
+	LandedCost decimal.Decimal `field:"landed_cost"`
+}
+
+// Types of the Transaction Logs, the TRANSACTION_TYPE enum
+const (
+	TransactionReceipt    = "receipt"
+	TransactionMoveOut    = "move_out"
+	TransactionMoveIn     = "move_in"
+	TransactionIssue      = "issue"
+	TransactionAdjustment = "adjustment"
+	TransactionImport     = "import"
+	TransactionReversal   = "reversal"
+)
+
+// Transaction is a row of the transactions_log table.
+// TransactionID, PrevHash and Hash are set by TransactionRepository.Add, see TransactionHash.
+type Transaction struct {
+	TransactionID     int       `field:"transaction_id"`
+	PriceID           int       `field:"price_id"`
+	Qty               int       `field:"quantity_change"`
+	Notes             string    `field:"notes"`
+	JobTicket         string    `field:"job_ticket"`
+	UpdatedAt         time.Time `field:"updated_at"`
+	SerialNumberRange string    `field:"serial_number_range"`
+	// The User who made the change and the one who approved it, 0 if none
+	UserID     int `field:"user_id"`
+	ApprovedBy int `field:"approved_by"`
+	// One of the Transaction types, empty for the rows written before the types
+	Type           string `field:"transaction_type"`
+	FromLocationID int    `field:"from_location_id"`
+	ToLocationID   int    `field:"to_location_id"`
+	// The Incoming Material of a receipt and the Request an issue fulfils, 0 if none
+	ShippingID int `field:"shipping_id"`
+	RequestID  int `field:"request_id"`
+	// Shared by all rows of one stock change, e.g. both halves of a move
+	CorrelationID string `field:"correlation_id"`
+	// The Transaction Log a reversal compensates, 0 for the other types
+	ReversesID int `field:"reverses_id"`
+	// Empty for the rows written before the hash chain
+	PrevHash string `field:"prev_hash"`
+	Hash     string `field:"row_hash"`
+}
+
+// PendingOperation is a move, removal or reversal waiting for the approval of a second User.
+// LocationID is the new Location of a move and the one a reversal returns the stock to,
+// TransactionID is the Transaction Log to reverse. DecidedBy and DecidedAt are set once approved or rejected.
+type PendingOperation struct {
+	OperationID       int        `field:"operation_id"`
+	Operation         string     `field:"operation"`
+	MaterialID        int        `field:"material_id"`
+	StockID           string     `field:"stock_id"`
+	MaterialType      string     `field:"material_type"`
+	Qty               int        `field:"quantity"`
+	LocationID        int        `field:"location_id"`
+	JobTicket         string     `field:"job_ticket"`
+	SerialNumberRange string     `field:"serial_number_range"`
+	RequestID         int        `field:"request_id"`
+	Status            string     `field:"status"`
+	RequestedBy       int        `field:"requested_by"`
+	RequestedAt       time.Time  `field:"requested_at"`
+	DecidedBy         int        `field:"decided_by"`
+	DecidedAt         *time.Time `field:"decided_at"`
+	Layers            []Layer    `field:"layers"`
+	TransactionID     int        `field:"transaction_id"`
+}
+
+// Layer is the quantity taken from one cost layer, chosen by the User for the specific identification
+type Layer struct {
+	PriceID int `field:"price_id" json:"priceId"`
+	Qty     int `field:"quantity" json:"quantity"`
+}
+
+// Costing methods of the Materials
+const (
+	CostingFIFO     = "fifo"
+	CostingAverage  = "average"
+	CostingSpecific = "specific"
+)
+
+// CostingRule sets the costing method of the Materials with the Owner and/or the Customer, the empty ones match any
+type CostingRule struct {
+	Owner      string `field:"owner"`
+	CustomerID int    `field:"customer_id"`
+	Method     string `field:"method"`
+}
+
+// Bases of the landed charge allocation
+const (
+	AllocationQuantity = "quantity"
+	AllocationValue    = "value"
+	AllocationWeight   = "weight"
+)
+
+// LandedCharge is an additional cost of Incoming Materials, e.g. freight or duties,
+// allocated to the shipments by the Method on the charge date "YYYY-MM-DD"
+type LandedCharge struct {
+	ChargeID    int             `field:"charge_id"`
+	Description string          `field:"description"`
+	Amount      decimal.Decimal `field:"amount"`
+	Currency    string          `field:"currency"`
+	Method      string          `field:"method"`
+	ChargeDate  string          `field:"charge_date"`
+	UserID      int             `field:"user_id"`
+	Username    string          `field:"username"`
+	CreatedAt   time.Time       `field:"created_at"`
+	Allocations []LandedAllocation
+}
+
+// LandedAllocation is the share of a charge of one shipment, in the Currency of the shipment.
+// UnitCost is the Amount per unit of the Quantity, added to the landed cost of the quantity still incoming.
+// Variance is the part of the Amount of the received units already consumed, it is not added to any layer.
+type LandedAllocation struct {
+	ChargeID   int             `field:"charge_id"`
+	ShippingID int             `field:"shipping_id"`
+	StockID    string          `field:"stock_id"`
+	Basis      decimal.Decimal `field:"basis"`
+	Amount     decimal.Decimal `field:"amount"`
+	Currency   string          `field:"currency"`
+	Qty        int             `field:"quantity"`
+	UnitCost   decimal.Decimal `field:"unit_cost"`
+	Variance   decimal.Decimal `field:"variance"`
+}
+
+type OperationFilter struct {
+	Status string
+}
+
+type RequestedMaterial struct {
+	UserID      int
+	StockID     string
+	Description string
+	Qty         int
+	Status      string
+	Notes       string
+	RequestedAt time.Time
+}
+
+type RequestUpdate struct {
+	RequestID int
+	QtyUsed   int
+	Status    string
+	Notes     string
+	UpdatedAt time.Time
+}
+
+type LocationFilter struct {
+	StockId string
+	Owner   string
+}
+
+type LocationDB struct {
+	ID            int    `field:"location_id"`
+	Name          string `field:"name"`
+	WarehouseID   int    `field:"warehouse_id"`
+	WarehouseName string `field:"warehouse_name"`
+}
+
+type WarehouseDB struct {
+	WarehouseID   int    `field:"warehouse_id"`
+	WarehouseName string `field:"name"`
+}
+
+type CustomerFilter struct {
+	CustomerIDs []int
+}
+
+type CustomerDB struct {
+	ID   int    `field:"id"`
+	Name string `field:"name"`
+	Code string `field:"customer_code"`
+}
+
+// Dates are compared as "YYYY-MM-DD" strings, an empty value disables the filter
+type ReportFilter struct {
+	CustomerId   int
+	Owner        string
+	MaterialType string
+	DateFrom     string
+	DateTo       string
+	DateAsOf     string
+	CustomerIDs  []int
+	// The values are converted to the Currency with the rates of RateDate, in the original currencies if empty
+	Currency string
+	RateDate string
+}
+
+// ExchangeRate is the value of one unit of the Currency in the base currency from the effective date "YYYY-MM-DD"
+type ExchangeRate struct {
+	Currency      string          `field:"currency"`
+	EffectiveDate string          `field:"effective_date"`
+	Rate          decimal.Decimal `field:"rate"`
+}
+
+// TransactionRecord is a row of the transactions report, LandedCost is the part of the Cost from the landed charges
+type TransactionRecord struct {
+	StockID           string          `field:"stock_id"`
+	MaterialType      string          `field:"material_type"`
+	Type              string          `field:"transaction_type"`
+	Qty               int             `field:"quantity"`
+	UnitCost          decimal.Decimal `field:"unit_cost"`
+	Cost              decimal.Decimal `field:"cost"`
+	LandedCost        decimal.Decimal `field:"landed_cost"`
+	Currency          string          `field:"currency"`
+	UpdatedAt         time.Time       `field:"updated_at"`
+	SerialNumberRange string          `field:"serial_number_range"`
+}
+
+// HistoryRecord is a Transaction Log of one Material with the quantity and value balances after it.
+// The value balance is the one of the layers in the Currency of the row, LandedCost is the part of the UnitCost from the landed charges.
+type HistoryRecord struct {
+	TransactionID     int             `field:"transaction_id"`
+	Type              string          `field:"transaction_type"`
+	Qty               int             `field:"quantity_change"`
+	UnitCost          decimal.Decimal `field:"unit_cost"`
+	Value             decimal.Decimal `field:"value"`
+	LandedCost        decimal.Decimal `field:"landed_cost"`
+	Currency          string          `field:"currency"`
+	QtyBalance        int             `field:"quantity_balance"`
+	ValueBalance      decimal.Decimal `field:"value_balance"`
+	Notes             string          `field:"notes"`
+	JobTicket         string          `field:"job_ticket"`
+	SerialNumberRange string          `field:"serial_number_range"`
+	UpdatedAt         time.Time       `field:"updated_at"`
+	UserID            int             `field:"user_id"`
+	Username          string          `field:"username"`
+	ApprovedBy        int             `field:"approved_by"`
+	FromLocation      string          `field:"from_location"`
+	ToLocation        string          `field:"to_location"`
+	ShippingID        int             `field:"shipping_id"`
+	RequestID         int             `field:"request_id"`
+	CorrelationID     string          `field:"correlation_id"`
+}
+
+// BalanceRecord is a row of the balance report, LandedValue is the part of the TotalValue from the landed charges
+type BalanceRecord struct {
+	StockID      string          `field:"stock_id"`
+	Description  string          `field:"description"`
+	MaterialType string          `field:"material_type"`
+	Qty          int             `field:"quantity"`
+	TotalValue   decimal.Decimal `field:"total_value"`
+	LandedValue  decimal.Decimal `field:"landed_value"`
+	Currency     string          `field:"currency"`
+}
+
+type UserDB struct {
+	UserID   int    `field:"user_id"`
+	Username string `field:"username"`
+	Password string `field:"password"`
+	Role     string `field:"role"`
+	IsActive bool   `field:"is_active"`
+	// Service accounts log in with API keys only
+	IsService bool `field:"is_service"`
+}
+
+// Session keeps the SHA-256 hash of the token given to the User, never the token itself
+type Session struct {
+	TokenHash string    `field:"token_hash"`
+	UserID    int       `field:"user_id"`
+	CreatedAt time.Time `field:"created_at"`
+	ExpiresAt time.Time `field:"expires_at"`
+}
+
+// LoginThrottle counts the recent failed logins of a "user:<username>" or "ip:<address>" key.
+// No login is accepted for the key before LockedUntil.
+type LoginThrottle struct {
+	Key           string    `field:"throttle_key"`
+	Failures      int       `field:"failures"`
+	LastFailureAt time.Time `field:"last_failure_at"`
+	LockedUntil   time.Time `field:"locked_until"`
+}
+
+// LoginAttempt is a row of the login history, UserID is 0 for an unknown username
+type LoginAttempt struct {
+	LoginID     int       `field:"login_id"`
+	Username    string    `field:"username"`
+	UserID      int       `field:"user_id"`
+	IPAddress   string    `field:"ip_address"`
+	Success     bool      `field:"success"`
+	Reason      string    `field:"reason"`
+	AttemptedAt time.Time `field:"attempted_at"`
+}
+
+// APIKey of a service account, only the SHA-256 hash of the key is stored.
+// ExpiresAt, LastUsedAt and RevokedAt are nil if not set.
+type APIKey struct {
+	KeyID      int        `field:"key_id"`
+	UserID     int        `field:"user_id"`
+	Name       string     `field:"name"`
+	Prefix     string     `field:"key_prefix"`
+	KeyHash    string     `field:"key_hash"`
+	CreatedBy  int        `field:"created_by"`
+	CreatedAt  time.Time  `field:"created_at"`
+	ExpiresAt  *time.Time `field:"expires_at"`
+	LastUsedAt *time.Time `field:"last_used_at"`
+	RevokedAt  *time.Time `field:"revoked_at"`
+}
+
+// AuditEntry is a row of the append-only audit log.
+// Changes is a JSON object of the changed fields, each one with its "before" and "after" value.
+type AuditEntry struct {
+	AuditID   int       `field:"audit_id"`
+	UserID    int       `field:"user_id"`
+	Username  string    `field:"username"`
+	Action    string    `field:"action"`
+	Entity    string    `field:"entity"`
+	EntityID  string    `field:"entity_id"`
+	Changes   []byte    `field:"changes"`
+	CreatedAt time.Time `field:"created_at"`
+}
+
+// Dates are "YYYY-MM-DD", the zero values match every Entry
+type AuditFilter struct {
+	UserID   int
+	Entity   string
+	EntityID string
+	DateFrom string
+	DateTo   string
+}
+
+type LoginFilter struct {
+	Username string
+	// Only the failed (false) or successful (true) Attempts if set
+	Success *bool
+}
+
+type RoleDB struct {
+	Name        string   `field:"name"`
+	Description string   `field:"description"`
+	Permissions []string `field:"permissions"`
+	// Users of the Role see the data of their own Customers only
+	CustomerScoped bool `field:"customer_scoped"`
+}
+
+type PermissionDB struct {
+	Name        string `field:"name"`
+	Description string `field:"description"`
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"os"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Money is kept as decimal.Decimal, NUMERIC in the database and a string in JSON.
+// Unit costs keep UnitCostPlaces decimal places, an extended value (quantity × unit cost) is rounded
+// to ValuePlaces per Transaction Log and the totals add up the rounded values, so the reports
+// match the accounting line by line. Both round half away from zero, like ROUND in Postgres.
+const (
+	UnitCostPlaces = 4
+	ValuePlaces    = 2
+)
+
+// Rounds the unit cost to UnitCostPlaces
+func UnitCost(cost decimal.Decimal) decimal.Decimal {
+	return cost.Round(UnitCostPlaces)
+}
+
+// Returns the quantity × unit cost rounded to ValuePlaces
+func ExtendedValue(qty int, unitCost decimal.Decimal) decimal.Decimal {
+	return unitCost.Mul(decimal.New(int64(qty), 0)).Round(ValuePlaces)
+}
+
+// Costs are kept in the Currency of their receipt, the ISO 4217 code. The reports convert them
+// to the base currency (BASE_CURRENCY env, DefaultCurrency if not set) with the exchange_rates
+// effective on the chosen date: a converted value is the rounded value × the rate, rounded to ValuePlaces.
+const DefaultCurrency = "USD"
+
+func BaseCurrency() string {
+	if currency := os.Getenv("BASE_CURRENCY"); currency != "" {
+		return strings.ToUpper(currency)
+	}
+	return DefaultCurrency
+}
+
+// Returns the value of the quantity × unit cost in the base currency at the rate
+func ConvertedValue(qty int, unitCost decimal.Decimal, rate decimal.Decimal) decimal.Decimal {
+	return ExtendedValue(qty, unitCost).Mul(rate).Round(ValuePlaces)
+}
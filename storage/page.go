@@ -0,0 +1,30 @@
+package storage
+
+// Page selects a part of a sorted list. The zero Page returns all rows in the default order.
+type Page struct {
+	// 0 returns all rows
+	Limit  int
+	Offset int
+	// One of the sort fields of the list, empty keeps the default order
+	SortBy string
+	Desc   bool
+}
+
+// Sort fields accepted by the List methods
+var (
+	MaterialSortFields    = []string{"materialId", "stockId", "description", "customerName", "locationName", "warehouseName", "materialType", "quantity"}
+	IncomingSortFields    = []string{"shippingId", "stockId", "description", "customerName", "materialType", "quantity", "cost"}
+	RequestSortFields     = []string{"requestId", "stockId", "description", "status", "quantityRequested", "requestedAt"}
+	LocationSortFields    = []string{"name", "warehouseName"}
+	WarehouseSortFields   = []string{"name"}
+	CustomerSortFields    = []string{"name", "code"}
+	UserSortFields        = []string{"userId", "username", "role"}
+	LoginSortFields       = []string{"username", "attemptedAt"}
+	OperationSortFields   = []string{"operationId", "stockId", "requestedAt"}
+	AuditSortFields       = []string{"auditId", "username", "entity", "createdAt"}
+	TransactionSortFields = []string{"stockId", "materialType", "quantity", "unitCost", "cost", "date"}
+	HistorySortFields     = []string{"transactionId", "date"}
+	BalanceSortFields     = []string{"stockId", "description", "materialType", "quantity", "totalValue"}
+	RateSortFields        = []string{"currency", "effectiveDate"}
+	LandedCostSortFields  = []string{"chargeId", "chargeDate", "amount"}
+)
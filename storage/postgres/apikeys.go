@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"inv_app/storage"
+	"time"
+)
+
+type apiKeyRepo struct {
+	q querier
+}
+
+func (r apiKeyRepo) Create(ctx context.Context, key storage.APIKey) (int, error) {
+	var keyId int
+	err := r.q.QueryRowContext(ctx, `
+		INSERT INTO api_keys (user_id, name, key_prefix, key_hash, created_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING key_id;`,
+		key.UserID, key.Name, key.Prefix, key.KeyHash, key.CreatedBy, key.CreatedAt, key.ExpiresAt,
+	).Scan(&keyId)
+	if err != nil {
+		return 0, err
+	}
+	return keyId, nil
+}
+
+func (r apiKeyRepo) List(ctx context.Context, userId int) ([]storage.APIKey, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT key_id, user_id, name, key_prefix, key_hash, created_by, created_at, expires_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC, key_id DESC;
+		`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []storage.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r apiKeyRepo) GetUser(ctx context.Context, keyHash string, now time.Time) (storage.APIKey, storage.UserDB, error) {
+	var key storage.APIKey
+	var user storage.UserDB
+	err := r.q.QueryRowContext(ctx, `
+		SELECT k.key_id, k.user_id, k.name, k.key_prefix, k.key_hash, k.created_by, k.created_at,
+		k.expires_at, k.last_used_at, k.revoked_at,
+		u.username, u.role, u.is_active, u.is_service
+		FROM api_keys k
+		JOIN users u ON u.user_id = k.user_id
+		WHERE k.key_hash = $1
+		AND k.revoked_at IS NULL
+		AND (k.expires_at IS NULL OR k.expires_at > $2)
+		AND u.is_active;
+		`, keyHash, now).Scan(
+		&key.KeyID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &key.CreatedBy, &key.CreatedAt,
+		&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt,
+		&user.Username, &user.Role, &user.IsActive, &user.IsService,
+	)
+	if err == sql.ErrNoRows {
+		return storage.APIKey{}, storage.UserDB{}, storage.ErrNotFound
+	}
+	if err != nil {
+		return storage.APIKey{}, storage.UserDB{}, err
+	}
+	user.UserID = key.UserID
+	return key, user, nil
+}
+
+func (r apiKeyRepo) SetLastUsed(ctx context.Context, keyId int, now time.Time) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE api_keys
+		SET last_used_at = $2
+		WHERE key_id = $1;
+		`, keyId, now)
+	return err
+}
+
+func (r apiKeyRepo) Revoke(ctx context.Context, keyId int, now time.Time) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = $2
+		WHERE key_id = $1 AND revoked_at IS NULL;
+		`, keyId, now)
+	if err != nil {
+		return err
+	}
+	revoked, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func scanAPIKey(r row) (storage.APIKey, error) {
+	var key storage.APIKey
+	err := r.Scan(
+		&key.KeyID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &key.CreatedBy, &key.CreatedAt,
+		&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt,
+	)
+	return key, err
+}
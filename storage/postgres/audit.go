@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"inv_app/storage"
+)
+
+type auditRepo struct {
+	q querier
+}
+
+func (r auditRepo) Add(ctx context.Context, entry storage.AuditEntry) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO audit_log (user_id, action, entity, entity_id, changes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6);`,
+		nullableID(entry.UserID), entry.Action, entry.Entity, entry.EntityID, string(entry.Changes), entry.CreatedAt,
+	)
+	return err
+}
+
+var auditSortColumns = map[string]string{
+	"auditId":   "a.audit_id",
+	"username":  "u.username",
+	"entity":    "a.entity",
+	"createdAt": "a.created_at",
+}
+
+func (r auditRepo) List(ctx context.Context, filter storage.AuditFilter, page storage.Page) ([]storage.AuditEntry, int, error) {
+	list := listQuery{
+		query: `
+		SELECT a.audit_id, COALESCE(a.user_id, 0), COALESCE(u.username, ''), a.action, a.entity, a.entity_id,
+		a.changes, a.created_at,
+		COUNT(*) OVER()
+		FROM audit_log a
+		LEFT JOIN users u ON u.user_id = a.user_id
+		WHERE
+			($1 = 0 OR a.user_id = $1) AND
+			($2 = '' OR a.entity = $2) AND
+			($3 = '' OR a.entity_id = $3) AND
+			($4 = '' OR a.created_at::DATE::TEXT >= $4) AND
+			($5 = '' OR a.created_at::DATE::TEXT <= $5)
+		`,
+		args:         []any{filter.UserID, filter.Entity, filter.EntityID, filter.DateFrom, filter.DateTo},
+		sortColumns:  auditSortColumns,
+		defaultOrder: "a.audit_id DESC",
+	}
+
+	entries := []storage.AuditEntry{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		var entry storage.AuditEntry
+		if err := rows.Scan(
+			&entry.AuditID,
+			&entry.UserID,
+			&entry.Username,
+			&entry.Action,
+			&entry.Entity,
+			&entry.EntityID,
+			&entry.Changes,
+			&entry.CreatedAt,
+			total,
+		); err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
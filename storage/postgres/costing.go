@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"inv_app/storage"
+)
+
+type costingRepo struct {
+	q querier
+}
+
+func (r costingRepo) Rules(ctx context.Context) ([]storage.CostingRule, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT COALESCE(owner::TEXT, ''), COALESCE(customer_id, 0), method
+		FROM costing_methods
+		ORDER BY owner NULLS FIRST, customer_id NULLS FIRST;
+		`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []storage.CostingRule{}
+	for rows.Next() {
+		var rule storage.CostingRule
+		if err := rows.Scan(&rule.Owner, &rule.CustomerID, &rule.Method); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r costingRepo) SetRules(ctx context.Context, rules []storage.CostingRule) error {
+	_, err := r.q.ExecContext(ctx, `
+		DELETE FROM costing_methods;
+		`)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		_, err := r.q.ExecContext(ctx, `
+			INSERT INTO costing_methods (owner, customer_id, method)
+			VALUES (NULLIF($1, '')::OWNER, $2, $3);
+			`, rule.Owner, nullableID(rule.CustomerID), rule.Method)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"inv_app/storage"
+)
+
+type customerRepo struct {
+	q querier
+}
+
+var customerSortColumns = map[string]string{
+	"name": "name",
+	"code": "customer_code",
+}
+
+func (r customerRepo) List(ctx context.Context, filter storage.CustomerFilter, page storage.Page) ([]storage.CustomerDB, int, error) {
+	list := listQuery{
+		query: `
+		SELECT customer_id, name, customer_code, COUNT(*) OVER() FROM customers
+		WHERE $1::INT[] IS NULL OR customer_id = ANY($1)
+		`,
+		args:         []any{customerScope(filter.CustomerIDs)},
+		sortColumns:  customerSortColumns,
+		defaultOrder: "name ASC, customer_id ASC",
+	}
+
+	customers := []storage.CustomerDB{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		var customer storage.CustomerDB
+		if err := rows.Scan(&customer.ID, &customer.Name, &customer.Code, total); err != nil {
+			return err
+		}
+		customers = append(customers, customer)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return customers, total, nil
+}
+
+func (r customerRepo) Find(ctx context.Context, name string, code string) (int, error) {
+	return findID(r.q.QueryRowContext(ctx, `
+		SELECT customer_id FROM customers
+		WHERE name = $1
+		AND customer_code = $2;
+		`, name, code))
+}
+
+func (r customerRepo) Create(ctx context.Context, name string, code string) (int, error) {
+	var customerId int
+	err := r.q.QueryRowContext(ctx, `
+		INSERT INTO customers(name, customer_code) VALUES($1, $2)
+		RETURNING customer_id;`,
+		name, code).Scan(&customerId)
+	if err != nil {
+		return 0, err
+	}
+	return customerId, nil
+}
@@ -0,0 +1,198 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"inv_app/storage"
+
+	"github.com/shopspring/decimal"
+)
+
+type incomingRepo struct {
+	q querier
+}
+
+func (r incomingRepo) Create(ctx context.Context, material storage.IncomingMaterialDB) (int, error) {
+	var shippingId int
+	err := r.q.QueryRowContext(ctx, `
+		INSERT INTO incoming_materials
+			(customer_id, stock_id, cost, quantity,
+			max_required_quantity, min_required_quantity,
+			description, is_active, type, owner, user_id, currency, unit_weight)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
+		RETURNING shipping_id;`,
+		material.CustomerID, material.StockID, material.Cost,
+		material.Quantity, material.MaxQty, material.MinQty,
+		material.Description, material.IsActive, material.MaterialType,
+		material.Owner,
+		material.UserID,
+		material.Currency,
+		material.UnitWeight,
+	).Scan(&shippingId)
+	if err != nil {
+		return 0, err
+	}
+	return shippingId, nil
+}
+
+func (r incomingRepo) Get(ctx context.Context, shippingId int) (storage.IncomingMaterialDB, error) {
+	return r.get(ctx, shippingId, "")
+}
+
+func (r incomingRepo) GetForUpdate(ctx context.Context, shippingId int) (storage.IncomingMaterialDB, error) {
+	return r.get(ctx, shippingId, "FOR UPDATE")
+}
+
+func (r incomingRepo) get(ctx context.Context, shippingId int, lock string) (storage.IncomingMaterialDB, error) {
+	var incomingMaterial storage.IncomingMaterialDB
+	err := r.q.QueryRowContext(ctx, `
+		SELECT shipping_id, customer_id, stock_id, quantity, cost, currency, landed_cost, landed_value, unit_weight,
+		min_required_quantity, max_required_quantity, description, is_active, type, owner, user_id
+		FROM incoming_materials
+		WHERE shipping_id = $1
+		`+lock, shippingId).
+		Scan(
+			&incomingMaterial.ShippingID,
+			&incomingMaterial.CustomerID,
+			&incomingMaterial.StockID,
+			&incomingMaterial.Quantity,
+			&incomingMaterial.Cost,
+			&incomingMaterial.Currency,
+			&incomingMaterial.LandedCost,
+			&incomingMaterial.LandedValue,
+			&incomingMaterial.UnitWeight,
+			&incomingMaterial.MinQty,
+			&incomingMaterial.MaxQty,
+			&incomingMaterial.Description,
+			&incomingMaterial.IsActive,
+			&incomingMaterial.MaterialType,
+			&incomingMaterial.Owner,
+			&incomingMaterial.UserID,
+		)
+	if err == sql.ErrNoRows {
+		return storage.IncomingMaterialDB{}, storage.ErrNotFound
+	}
+	if err != nil {
+		return storage.IncomingMaterialDB{}, err
+	}
+	return incomingMaterial, nil
+}
+
+var incomingSortColumns = map[string]string{
+	"shippingId":   "im.shipping_id",
+	"stockId":      "im.stock_id",
+	"description":  "im.description",
+	"customerName": "c.name",
+	"materialType": "im.type",
+	"quantity":     "im.quantity",
+	"cost":         "im.cost",
+}
+
+func (r incomingRepo) List(ctx context.Context, filter storage.IncomingFilter, page storage.Page) ([]storage.IncomingMaterialDB, int, error) {
+	list := listQuery{
+		query: `
+		SELECT shipping_id, c.name, c.customer_id, stock_id, cost, currency, landed_cost, landed_value, unit_weight, quantity,
+		min_required_quantity, max_required_quantity, description, is_active, type, owner,
+		u.user_id, u.username,
+		COUNT(*) OVER()
+		FROM incoming_materials im
+		LEFT JOIN customers c ON c.customer_id = im.customer_id
+		LEFT JOIN users u ON u.user_id = im.user_id
+		WHERE im.quantity > 0 AND
+			($1 = 0 OR im.shipping_id = $1) AND
+			($2::INT[] IS NULL OR im.customer_id = ANY($2))
+		`,
+		args:         []any{filter.ShippingID, customerScope(filter.CustomerIDs)},
+		sortColumns:  incomingSortColumns,
+		defaultOrder: "im.shipping_id ASC",
+	}
+
+	materials := []storage.IncomingMaterialDB{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		var material storage.IncomingMaterialDB
+		if err := rows.Scan(
+			&material.ShippingID,
+			&material.CustomerName,
+			&material.CustomerID,
+			&material.StockID,
+			&material.Cost,
+			&material.Currency,
+			&material.LandedCost,
+			&material.LandedValue,
+			&material.UnitWeight,
+			&material.Quantity,
+			&material.MinQty,
+			&material.MaxQty,
+			&material.Description,
+			&material.IsActive,
+			&material.MaterialType,
+			&material.Owner,
+			&material.UserID,
+			&material.UserName,
+			total,
+		); err != nil {
+			return err
+		}
+		materials = append(materials, material)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error querying incoming materials: %w", err)
+	}
+	return materials, total, nil
+}
+
+func (r incomingRepo) Update(ctx context.Context, material storage.IncomingMaterialDB) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE incoming_materials
+		SET customer_id = $2,
+			stock_id = $3,
+			cost = $4,
+			quantity = $5,
+			max_required_quantity = $6,
+			min_required_quantity = $7,
+			description = $8,
+			is_active = $9,
+			type = $10,
+			owner = $11,
+			currency = $12,
+			unit_weight = $13
+		WHERE shipping_id = $1;
+	`,
+		material.ShippingID,
+		material.CustomerID,
+		material.StockID,
+		material.Cost,
+		material.Quantity,
+		material.MaxQty,
+		material.MinQty,
+		material.Description,
+		material.IsActive,
+		material.MaterialType,
+		material.Owner,
+		material.Currency,
+		material.UnitWeight,
+	)
+	return err
+}
+
+func (r incomingRepo) AddQuantity(ctx context.Context, shippingId int, qty int) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE incoming_materials
+		SET quantity = (quantity + $2)
+		WHERE shipping_id = $1;
+		`, shippingId, qty,
+	)
+	return err
+}
+
+func (r incomingRepo) AddLandedCost(ctx context.Context, shippingId int, unitCost decimal.Decimal, value decimal.Decimal) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE incoming_materials
+		SET landed_cost = (landed_cost + $2), landed_value = (landed_value + $3)
+		WHERE shipping_id = $1;
+		`, shippingId, unitCost, value,
+	)
+	return err
+}
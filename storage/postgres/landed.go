@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"inv_app/storage"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type landedCostRepo struct {
+	q querier
+}
+
+func (r landedCostRepo) Create(ctx context.Context, charge storage.LandedCharge) (int, error) {
+	var chargeId int
+	err := r.q.QueryRowContext(ctx, `
+		INSERT INTO landed_charges (description, amount, currency, method, charge_date, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING charge_id;
+		`, charge.Description, charge.Amount, charge.Currency, charge.Method, charge.ChargeDate, nullableID(charge.UserID),
+	).Scan(&chargeId)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, allocation := range charge.Allocations {
+		_, err := r.q.ExecContext(ctx, `
+			INSERT INTO landed_allocations (charge_id, shipping_id, stock_id, basis, amount, currency, quantity, unit_cost, variance)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+			`, chargeId, allocation.ShippingID, allocation.StockID, allocation.Basis,
+			allocation.Amount, allocation.Currency, allocation.Qty, allocation.UnitCost, allocation.Variance)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return chargeId, nil
+}
+
+var landedCostSortColumns = map[string]string{
+	"chargeId":   "lc.charge_id",
+	"chargeDate": "lc.charge_date",
+	"amount":     "lc.amount",
+}
+
+func (r landedCostRepo) List(ctx context.Context, shippingId int, page storage.Page) ([]storage.LandedCharge, int, error) {
+	list := listQuery{
+		query: `
+		SELECT lc.charge_id, lc.description, lc.amount, lc.currency, lc.method, lc.charge_date,
+			COALESCE(lc.user_id, 0), COALESCE(u.username, ''), lc.created_at,
+			COUNT(*) OVER()
+		FROM landed_charges lc
+		LEFT JOIN users u ON u.user_id = lc.user_id
+		WHERE $1 = 0 OR EXISTS (
+			SELECT 1 FROM landed_allocations la
+			WHERE la.charge_id = lc.charge_id AND la.shipping_id = $1
+		)
+		`,
+		args:         []any{shippingId},
+		sortColumns:  landedCostSortColumns,
+		defaultOrder: "lc.charge_id DESC",
+	}
+
+	charges := []storage.LandedCharge{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		var charge storage.LandedCharge
+		var chargeDate time.Time
+		if err := rows.Scan(
+			&charge.ChargeID,
+			&charge.Description,
+			&charge.Amount,
+			&charge.Currency,
+			&charge.Method,
+			&chargeDate,
+			&charge.UserID,
+			&charge.Username,
+			&charge.CreatedAt,
+			total,
+		); err != nil {
+			return err
+		}
+		charge.ChargeDate = chargeDate.Format(time.DateOnly)
+		charge.Allocations = []storage.LandedAllocation{}
+		charges = append(charges, charge)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(charges) == 0 {
+		return charges, total, nil
+	}
+
+	chargeIds := make([]int, 0, len(charges))
+	for _, charge := range charges {
+		chargeIds = append(chargeIds, charge.ChargeID)
+	}
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT charge_id, shipping_id, stock_id, basis, amount, currency, quantity, unit_cost, variance
+		FROM landed_allocations
+		WHERE charge_id = ANY($1)
+		ORDER BY charge_id, shipping_id;
+		`, pq.Array(chargeIds))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var allocation storage.LandedAllocation
+		if err := rows.Scan(
+			&allocation.ChargeID,
+			&allocation.ShippingID,
+			&allocation.StockID,
+			&allocation.Basis,
+			&allocation.Amount,
+			&allocation.Currency,
+			&allocation.Qty,
+			&allocation.UnitCost,
+			&allocation.Variance,
+		); err != nil {
+			return nil, 0, err
+		}
+		for i := range charges {
+			if charges[i].ChargeID == allocation.ChargeID {
+				charges[i].Allocations = append(charges[i].Allocations, allocation)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return charges, total, nil
+}
@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"inv_app/storage"
+)
+
+type locationRepo struct {
+	q querier
+}
+
+var locationSortColumns = map[string]string{
+	"name":          "l.name",
+	"warehouseName": "w.name",
+}
+
+func (r locationRepo) List(ctx context.Context, page storage.Page) ([]storage.LocationDB, int, error) {
+	return r.query(ctx, page, `
+		SELECT l.location_id, l.name, w.warehouse_id, w.name as "warehouse_name", COUNT(*) OVER()
+		FROM locations l
+		LEFT JOIN warehouses w
+		ON l.warehouse_id = w.warehouse_id
+	`)
+}
+
+func (r locationRepo) ListAvailable(ctx context.Context, opts storage.LocationFilter, page storage.Page) ([]storage.LocationDB, int, error) {
+	return r.query(ctx, page, `
+		SELECT l.location_id, l.name, l.warehouse_id, w.name as "warehouse_name", COUNT(*) OVER()
+		FROM locations l
+		LEFT JOIN materials m ON l.location_id = m.location_id
+		LEFT JOIN warehouses w ON w.warehouse_id = l.warehouse_id
+		WHERE m.stock_id = $1 AND m.owner = $2 OR m.material_id IS NULL
+	`, opts.StockId, opts.Owner)
+}
+
+func (r locationRepo) Find(ctx context.Context, name string, warehouseId int) (int, error) {
+	return findID(r.q.QueryRowContext(ctx, `
+		SELECT location_id FROM locations
+		WHERE name = $1
+		AND warehouse_id = $2;
+		`, name, warehouseId))
+}
+
+func (r locationRepo) Create(ctx context.Context, name string, warehouseId int) (int, error) {
+	var locationId int
+	err := r.q.QueryRowContext(ctx, `
+		INSERT INTO locations(name, warehouse_id) VALUES($1, $2)
+		RETURNING location_id;`,
+		name, warehouseId).Scan(&locationId)
+	if err != nil {
+		return 0, err
+	}
+	return locationId, nil
+}
+
+func (r locationRepo) query(ctx context.Context, page storage.Page, query string, args ...any) ([]storage.LocationDB, int, error) {
+	list := listQuery{
+		query:        query,
+		args:         args,
+		sortColumns:  locationSortColumns,
+		defaultOrder: "l.name ASC, l.location_id ASC",
+	}
+
+	locations := []storage.LocationDB{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		var location storage.LocationDB
+		if err := rows.Scan(&location.ID, &location.Name, &location.WarehouseID, &location.WarehouseName, total); err != nil {
+			return err
+		}
+		locations = append(locations, location)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return locations, total, nil
+}
+
+type warehouseRepo struct {
+	q querier
+}
+
+var warehouseSortColumns = map[string]string{
+	"name": "name",
+}
+
+func (r warehouseRepo) List(ctx context.Context, page storage.Page) ([]storage.WarehouseDB, int, error) {
+	list := listQuery{
+		query:        "SELECT warehouse_id, name, COUNT(*) OVER() FROM warehouses",
+		sortColumns:  warehouseSortColumns,
+		defaultOrder: "warehouse_id ASC",
+	}
+
+	warehouses := []storage.WarehouseDB{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		var warehouse storage.WarehouseDB
+		if err := rows.Scan(&warehouse.WarehouseID, &warehouse.WarehouseName, total); err != nil {
+			return err
+		}
+		warehouses = append(warehouses, warehouse)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return warehouses, total, nil
+}
+
+func (r warehouseRepo) Find(ctx context.Context, name string) (int, error) {
+	return findID(r.q.QueryRowContext(ctx, `
+		SELECT warehouse_id FROM warehouses
+		WHERE name = $1;
+		`, name))
+}
+
+func (r warehouseRepo) Create(ctx context.Context, name string) (int, error) {
+	var warehouseId int
+	err := r.q.QueryRowContext(ctx, `
+		INSERT INTO warehouses(name) VALUES($1)
+		RETURNING warehouse_id;`,
+		name).Scan(&warehouseId)
+	if err != nil {
+		return 0, err
+	}
+	return warehouseId, nil
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"inv_app/storage"
+)
+
+type loginRepo struct {
+	q querier
+}
+
+func (r loginRepo) GetThrottle(ctx context.Context, key string) (storage.LoginThrottle, error) {
+	var throttle storage.LoginThrottle
+	err := r.q.QueryRowContext(ctx, `
+		SELECT throttle_key, failures, last_failure_at, locked_until
+		FROM login_throttles
+		WHERE throttle_key = $1
+		FOR UPDATE;
+		`, key).Scan(&throttle.Key, &throttle.Failures, &throttle.LastFailureAt, &throttle.LockedUntil)
+	if err == sql.ErrNoRows {
+		return storage.LoginThrottle{}, storage.ErrNotFound
+	}
+	if err != nil {
+		return storage.LoginThrottle{}, err
+	}
+	return throttle, nil
+}
+
+func (r loginRepo) SaveThrottle(ctx context.Context, throttle storage.LoginThrottle) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO login_throttles (throttle_key, failures, last_failure_at, locked_until)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (throttle_key) DO UPDATE
+		SET failures = EXCLUDED.failures,
+			last_failure_at = EXCLUDED.last_failure_at,
+			locked_until = EXCLUDED.locked_until;
+		`, throttle.Key, throttle.Failures, throttle.LastFailureAt, throttle.LockedUntil)
+	return err
+}
+
+func (r loginRepo) DeleteThrottle(ctx context.Context, key string) error {
+	_, err := r.q.ExecContext(ctx, `
+		DELETE FROM login_throttles WHERE throttle_key = $1;
+		`, key)
+	return err
+}
+
+func (r loginRepo) AddAttempt(ctx context.Context, attempt storage.LoginAttempt) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO login_history (username, user_id, ip_address, success, reason, attempted_at)
+		VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6);
+		`, attempt.Username, attempt.UserID, attempt.IPAddress, attempt.Success, attempt.Reason, attempt.AttemptedAt)
+	return err
+}
+
+var loginSortColumns = map[string]string{
+	"username":    "username",
+	"attemptedAt": "attempted_at",
+}
+
+func (r loginRepo) ListAttempts(ctx context.Context, filter storage.LoginFilter, page storage.Page) ([]storage.LoginAttempt, int, error) {
+	list := listQuery{
+		query: `
+		SELECT login_id, username, COALESCE(user_id, 0), ip_address, success, reason, attempted_at,
+		COUNT(*) OVER()
+		FROM login_history
+		WHERE
+			($1 = '' OR username = $1) AND
+			($2::boolean IS NULL OR success = $2)
+		`,
+		args:         []any{filter.Username, filter.Success},
+		sortColumns:  loginSortColumns,
+		defaultOrder: "attempted_at DESC, login_id DESC",
+	}
+
+	attempts := []storage.LoginAttempt{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		var attempt storage.LoginAttempt
+		if err := rows.Scan(
+			&attempt.LoginID,
+			&attempt.Username,
+			&attempt.UserID,
+			&attempt.IPAddress,
+			&attempt.Success,
+			&attempt.Reason,
+			&attempt.AttemptedAt,
+			total,
+		); err != nil {
+			return err
+		}
+		attempts = append(attempts, attempt)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return attempts, total, nil
+}
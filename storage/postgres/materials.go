@@ -0,0 +1,310 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"inv_app/storage"
+
+	"github.com/lib/pq"
+)
+
+type materialRepo struct {
+	q querier
+}
+
+var materialSortColumns = map[string]string{
+	"materialId":    "m.material_id",
+	"stockId":       "m.stock_id",
+	"description":   "m.description",
+	"customerName":  "c.name",
+	"locationName":  "l.name",
+	"warehouseName": "w.name",
+	"materialType":  "m.material_type",
+	"quantity":      "m.quantity",
+}
+
+func (r materialRepo) List(ctx context.Context, opts storage.MaterialFilter, page storage.Page) ([]storage.MaterialDB, int, error) {
+	list := listQuery{
+		query: `
+		SELECT material_id,
+		COALESCE(w.name,'None') as "warehouse_name",
+		c.name as "customer_name", c.customer_id,
+		COALESCE(l.location_id, 0) as "location_id",
+		COALESCE(l.name, 'None') as "location_name",
+		stock_id, quantity, min_required_quantity, max_required_quantity,
+		m.description, COALESCE(notes,'None') as "notes",
+		is_active, material_type, owner,
+		COALESCE(is_primary, false),
+		COALESCE(serial_number_range, ''),
+		COUNT(*) OVER()
+		FROM materials m
+		LEFT JOIN customers c ON c.customer_id = m.customer_id
+		LEFT JOIN locations l ON l.location_id = m.location_id
+		LEFT JOIN warehouses w ON w.warehouse_id = l.warehouse_id
+		WHERE
+			($1 = 0 OR m.material_id = $1) AND
+			($2 = '' OR m.stock_id ILIKE '%' || $2 || '%') AND
+			($3 = '' OR c.name ILIKE '%' || $3 || '%') AND
+			($4 = '' OR m.description ILIKE '%' || $4 || '%') AND
+			($5 = '' OR l.name ILIKE '%' || $5 || '%') AND
+			($6::INT[] IS NULL OR m.customer_id = ANY($6))
+		`,
+		args: []any{
+			opts.MaterialId,
+			opts.StockId,
+			opts.CustomerName,
+			opts.Description,
+			opts.LocationName,
+			customerScope(opts.CustomerIDs),
+		},
+		sortColumns:  materialSortColumns,
+		defaultOrder: "m.is_primary DESC NULLS LAST, m.stock_id ASC, m.material_id ASC",
+	}
+
+	materials := []storage.MaterialDB{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		var material storage.MaterialDB
+		if err := rows.Scan(
+			&material.MaterialID,
+			&material.WarehouseName,
+			&material.CustomerName,
+			&material.CustomerID,
+			&material.LocationID,
+			&material.LocationName,
+			&material.StockID,
+			&material.Quantity,
+			&material.MinQty,
+			&material.MaxQty,
+			&material.Description,
+			&material.Notes,
+			&material.IsActive,
+			&material.MaterialType,
+			&material.Owner,
+			&material.IsPrimary,
+			&material.SerialNumberRange,
+			total,
+		); err != nil {
+			return err
+		}
+		materials = append(materials, material)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error querying materials: %w", err)
+	}
+	return materials, total, nil
+}
+
+func (r materialRepo) Get(ctx context.Context, materialId int) (storage.MaterialDB, error) {
+	return r.get(ctx, materialId, "")
+}
+
+func (r materialRepo) GetForUpdate(ctx context.Context, materialId int) (storage.MaterialDB, error) {
+	return r.get(ctx, materialId, "FOR UPDATE")
+}
+
+func (r materialRepo) get(ctx context.Context, materialId int, lock string) (storage.MaterialDB, error) {
+	var currMaterial storage.MaterialDB
+	err := r.q.QueryRowContext(ctx, `SELECT
+							material_id, stock_id, COALESCE(location_id, 0),
+							customer_id, material_type, description, COALESCE(notes, ''),
+							quantity, updated_at,
+							is_active, min_required_quantity, max_required_quantity,
+							owner, is_primary, COALESCE(serial_number_range, '')
+						FROM materials
+						WHERE material_id = $1
+						`+lock,
+		materialId,
+	).Scan(
+		&currMaterial.MaterialID,
+		&currMaterial.StockID,
+		&currMaterial.LocationID,
+		&currMaterial.CustomerID,
+		&currMaterial.MaterialType,
+		&currMaterial.Description,
+		&currMaterial.Notes,
+		&currMaterial.Quantity,
+		&currMaterial.UpdatedAt,
+		&currMaterial.IsActive,
+		&currMaterial.MinQty,
+		&currMaterial.MaxQty,
+		&currMaterial.Owner,
+		&currMaterial.IsPrimary,
+		&currMaterial.SerialNumberRange,
+	)
+	if err == sql.ErrNoRows {
+		return storage.MaterialDB{}, storage.ErrNotFound
+	}
+	if err != nil {
+		return storage.MaterialDB{}, err
+	}
+
+	return currMaterial, nil
+}
+
+func (r materialRepo) FindAtLocation(ctx context.Context, stockId string, locationId int, owner string) (int, error) {
+	return findID(r.q.QueryRowContext(ctx, `
+		SELECT material_id FROM materials
+		WHERE stock_id = $1
+			AND location_id = $2
+			AND owner = $3;
+		`, stockId, locationId, owner))
+}
+
+func (r materialRepo) FindUnplaced(ctx context.Context, stockId string, owner string) (int, error) {
+	return scanID(r.q.QueryContext(ctx, `
+		SELECT material_id FROM materials
+		WHERE location_id is NULL
+			AND stock_id = $1
+			AND owner = $2;
+		`, stockId, owner))
+}
+
+func (r materialRepo) Create(ctx context.Context, material storage.MaterialDB) (int, error) {
+	var materialId int
+	err := r.q.QueryRowContext(ctx, `
+				INSERT INTO materials
+				(
+					stock_id,
+					location_id,
+					customer_id,
+					material_type,
+					description,
+					notes,
+					quantity,
+					updated_at,
+					min_required_quantity,
+					max_required_quantity,
+					is_active,
+					owner,
+					is_primary,
+					serial_number_range
+				)
+				VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14) RETURNING material_id;`,
+		material.StockID,
+		nullableID(material.LocationID),
+		material.CustomerID,
+		material.MaterialType,
+		material.Description,
+		material.Notes,
+		material.Quantity,
+		material.UpdatedAt,
+		material.MinQty,
+		material.MaxQty,
+		material.IsActive,
+		material.Owner,
+		material.IsPrimary,
+		material.SerialNumberRange,
+	).Scan(&materialId)
+	if err != nil {
+		return 0, err
+	}
+	return materialId, nil
+}
+
+func (r materialRepo) AddQuantity(ctx context.Context, materialId int, qty int) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE materials
+		SET quantity = (quantity + $2)
+		WHERE material_id = $1;
+		`, materialId, qty)
+	return err
+}
+
+func (r materialRepo) UpdateNotes(ctx context.Context, materialId int, notes string) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE materials
+		SET notes = $2
+		WHERE material_id = $1;
+		`, materialId, notes)
+	return err
+}
+
+func (r materialRepo) Place(ctx context.Context, materialId int, locationId int, qty int, notes string) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE materials
+		SET quantity = $2,
+			notes = $3,
+			location_id = $4
+		WHERE material_id = $1;
+		`, materialId, qty, notes, locationId)
+	return err
+}
+
+func (r materialRepo) Unplace(ctx context.Context, materialId int) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE materials
+		SET location_id = NULL,
+			quantity = 0
+		WHERE material_id = $1;
+		`, materialId)
+	return err
+}
+
+func (r materialRepo) SetPrimary(ctx context.Context, materialId int, isPrimary bool) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE materials
+		SET is_primary = $2
+		WHERE material_id = $1;
+		`, materialId, isPrimary)
+	return err
+}
+
+func (r materialRepo) Types(ctx context.Context) ([]string, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT enumlabel FROM pg_enum pe
+		LEFT JOIN pg_type pt ON pt.oid = pe.enumtypid
+		WHERE pt.typname = 'material_type'
+		ORDER BY pe.enumsortorder;
+	`)
+	if err != nil {
+		return []string{}, err
+	}
+	defer rows.Close()
+
+	var materialTypes []string
+	for rows.Next() {
+		var materialType string
+		if err := rows.Scan(&materialType); err != nil {
+			return nil, fmt.Errorf("Error scanning row: %w", err)
+		}
+		materialTypes = append(materialTypes, materialType)
+	}
+	return materialTypes, rows.Err()
+}
+
+func (r materialRepo) Description(ctx context.Context, stockId string, customerIds []int) (string, error) {
+	var description string
+	err := r.q.QueryRowContext(ctx, `
+		SELECT description
+		FROM materials
+		WHERE LOWER(stock_id) = LOWER($1) AND
+			($2::INT[] IS NULL OR customer_id = ANY($2))
+		LIMIT 1;
+	`,
+		stockId,
+		customerScope(customerIds),
+	).Scan(&description)
+	if err == sql.ErrNoRows {
+		return "", storage.ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return description, nil
+}
+
+// IDs equal to 0 are stored as NULL
+func nullableID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+// A nil list of Customer IDs is NULL and disables the "customer_id = ANY(...)" filter,
+// an empty one matches no rows
+func customerScope(customerIds []int) any {
+	if customerIds == nil {
+		return nil
+	}
+	return pq.Array(customerIds)
+}
@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"inv_app/storage"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type operationRepo struct {
+	q querier
+}
+
+func (r operationRepo) DualControlTypes(ctx context.Context) ([]string, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT material_type FROM dual_control_types ORDER BY material_type;
+		`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	materialTypes := []string{}
+	for rows.Next() {
+		var materialType string
+		if err := rows.Scan(&materialType); err != nil {
+			return nil, err
+		}
+		materialTypes = append(materialTypes, materialType)
+	}
+	return materialTypes, rows.Err()
+}
+
+func (r operationRepo) SetDualControlTypes(ctx context.Context, materialTypes []string) error {
+	_, err := r.q.ExecContext(ctx, `
+		DELETE FROM dual_control_types;
+		`)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO dual_control_types (material_type)
+		SELECT DISTINCT unnest($1::VARCHAR[]);
+		`, pq.Array(materialTypes))
+	return err
+}
+
+func (r operationRepo) Create(ctx context.Context, operation storage.PendingOperation) (int, error) {
+	layers := operation.Layers
+	if layers == nil {
+		layers = []storage.Layer{}
+	}
+	layersJSON, err := json.Marshal(layers)
+	if err != nil {
+		return 0, err
+	}
+
+	var operationId int
+	err = r.q.QueryRowContext(ctx, `
+		INSERT INTO pending_operations (
+			operation, material_id, quantity, location_id, job_ticket, serial_number_range, request_id,
+			status, requested_by, requested_at, layers, transaction_id
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING operation_id;`,
+		operation.Operation, operation.MaterialID, operation.Qty, nullableID(operation.LocationID),
+		operation.JobTicket, operation.SerialNumberRange, nullableID(operation.RequestID),
+		operation.Status, operation.RequestedBy, operation.RequestedAt, layersJSON, nullableID(operation.TransactionID),
+	).Scan(&operationId)
+	if err != nil {
+		return 0, err
+	}
+	return operationId, nil
+}
+
+const operationColumns = `
+	o.operation_id, o.operation, o.material_id, m.stock_id, m.material_type, o.quantity,
+	COALESCE(o.location_id, 0), o.job_ticket, o.serial_number_range, COALESCE(o.request_id, 0), o.status,
+	o.requested_by, o.requested_at, COALESCE(o.decided_by, 0), o.decided_at, o.layers, COALESCE(o.transaction_id, 0)`
+
+func (r operationRepo) GetForUpdate(ctx context.Context, operationId int) (storage.PendingOperation, error) {
+	operation, err := scanOperation(r.q.QueryRowContext(ctx, `
+		SELECT `+operationColumns+`
+		FROM pending_operations o
+		JOIN materials m ON m.material_id = o.material_id
+		WHERE o.operation_id = $1
+		FOR UPDATE OF o;
+		`, operationId), nil)
+	if err == sql.ErrNoRows {
+		return storage.PendingOperation{}, storage.ErrNotFound
+	}
+	return operation, err
+}
+
+var operationSortColumns = map[string]string{
+	"operationId": "o.operation_id",
+	"stockId":     "m.stock_id",
+	"requestedAt": "o.requested_at",
+}
+
+func (r operationRepo) List(ctx context.Context, filter storage.OperationFilter, page storage.Page) ([]storage.PendingOperation, int, error) {
+	list := listQuery{
+		query: `
+		SELECT ` + operationColumns + `, COUNT(*) OVER()
+		FROM pending_operations o
+		JOIN materials m ON m.material_id = o.material_id
+		WHERE $1 = '' OR o.status = $1
+		`,
+		args:         []any{filter.Status},
+		sortColumns:  operationSortColumns,
+		defaultOrder: "o.requested_at ASC, o.operation_id ASC",
+	}
+
+	operations := []storage.PendingOperation{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		operation, err := scanOperation(rows, total)
+		if err != nil {
+			return err
+		}
+		operations = append(operations, operation)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return operations, total, nil
+}
+
+func (r operationRepo) Decide(ctx context.Context, operationId int, status string, decidedBy int, decidedAt time.Time) error {
+	res, err := r.q.ExecContext(ctx, `
+		UPDATE pending_operations
+		SET status = $2, decided_by = $3, decided_at = $4
+		WHERE operation_id = $1 AND status = 'pending';
+		`, operationId, status, decidedBy, decidedAt)
+	if err != nil {
+		return err
+	}
+	decided, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if decided == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// The total is scanned from the list rows only
+func scanOperation(r row, total *int) (storage.PendingOperation, error) {
+	var operation storage.PendingOperation
+	var layers []byte
+	dest := []any{
+		&operation.OperationID, &operation.Operation, &operation.MaterialID, &operation.StockID,
+		&operation.MaterialType, &operation.Qty, &operation.LocationID, &operation.JobTicket,
+		&operation.SerialNumberRange, &operation.RequestID, &operation.Status, &operation.RequestedBy, &operation.RequestedAt,
+		&operation.DecidedBy, &operation.DecidedAt, &layers, &operation.TransactionID,
+	}
+	if total != nil {
+		dest = append(dest, total)
+	}
+	if err := r.Scan(dest...); err != nil {
+		return operation, err
+	}
+	err := json.Unmarshal(layers, &operation.Layers)
+	return operation, err
+}
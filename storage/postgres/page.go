@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"inv_app/storage"
+	"strings"
+)
+
+// listQuery is a SELECT of a list without ORDER BY, LIMIT and OFFSET.
+// Its last column must be "COUNT(*) OVER()", so every row also carries the total number of the filtered rows.
+type listQuery struct {
+	query string
+	args  []any
+	// SQL expressions of the storage sort fields
+	sortColumns map[string]string
+	// ORDER BY of the default order, also used to break the ties of the chosen sort field
+	defaultOrder string
+}
+
+// The method runs the list query for the Page and calls scan per row, total must be scanned as the last column.
+// A Page past the end of the list has no rows to carry the total, so it is counted separately.
+func queryPage(ctx context.Context, q querier, list listQuery, page storage.Page, scan func(rows *sql.Rows, total *int) error) (int, error) {
+	order := list.defaultOrder
+	if column, ok := list.sortColumns[page.SortBy]; ok {
+		direction := "ASC"
+		if page.Desc {
+			direction = "DESC"
+		}
+		order = column + " " + direction + ", " + order
+	}
+
+	// LIMIT NULL is the same as LIMIT ALL
+	limit := sql.NullInt64{Int64: int64(page.Limit), Valid: page.Limit > 0}
+	argCount := len(list.args)
+	query := fmt.Sprintf("%s ORDER BY %s LIMIT $%d OFFSET $%d;", list.query, order, argCount+1, argCount+2)
+
+	args := append(append([]any{}, list.args...), limit, page.Offset)
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	total := 0
+	count := 0
+	for rows.Next() {
+		if err := scan(rows, &total); err != nil {
+			return 0, fmt.Errorf("Error scanning row: %w", err)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if count == 0 && page.Offset > 0 {
+		countQuery := "SELECT COUNT(*) FROM (" + strings.TrimSpace(list.query) + ") AS list;"
+		if err := q.QueryRowContext(ctx, countQuery, list.args...).Scan(&total); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"inv_app/storage"
+
+	"github.com/shopspring/decimal"
+)
+
+type priceRepo struct {
+	q querier
+}
+
+func (r priceRepo) ListAvailable(ctx context.Context, materialId int) ([]storage.Price, error) {
+	return r.listAvailable(ctx, materialId, "")
+}
+
+func (r priceRepo) ListAvailableForUpdate(ctx context.Context, materialId int) ([]storage.Price, error) {
+	return r.listAvailable(ctx, materialId, "FOR UPDATE")
+}
+
+func (r priceRepo) listAvailable(ctx context.Context, materialId int, lock string) ([]storage.Price, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT price_id, material_id, quantity, cost, currency, received_at, original_quantity, landed_cost FROM prices
+		WHERE material_id = $1
+		AND quantity > 0
+		ORDER BY received_at ASC, price_id ASC
+		`+lock, materialId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []storage.Price{}
+	for rows.Next() {
+		var price storage.Price
+		err := rows.Scan(&price.PriceID, &price.MaterialID, &price.Qty, &price.Cost, &price.Currency, &price.ReceivedAt, &price.OriginalQty, &price.LandedCost)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+	return prices, rows.Err()
+}
+
+func (r priceRepo) Create(ctx context.Context, price storage.Price) (int, error) {
+	var priceId int
+	err := r.q.QueryRowContext(ctx, `
+		INSERT INTO prices(material_id, quantity, cost, currency, received_at, original_quantity, landed_cost)
+		VALUES($1, $2, $3, $4, $5, $6, $7)
+		RETURNING price_id;
+		`, price.MaterialID, price.Qty, price.Cost, price.Currency, price.ReceivedAt, price.OriginalQty, price.LandedCost,
+	).Scan(&priceId)
+	if err != nil {
+		return 0, err
+	}
+	return priceId, nil
+}
+
+func (r priceRepo) GetForUpdate(ctx context.Context, priceId int) (storage.Price, error) {
+	var price storage.Price
+	err := r.q.QueryRowContext(ctx, `
+		SELECT price_id, material_id, quantity, cost, currency, received_at, original_quantity, landed_cost FROM prices
+		WHERE price_id = $1
+		FOR UPDATE;
+		`, priceId,
+	).Scan(&price.PriceID, &price.MaterialID, &price.Qty, &price.Cost, &price.Currency, &price.ReceivedAt, &price.OriginalQty, &price.LandedCost)
+	if err == sql.ErrNoRows {
+		return storage.Price{}, storage.ErrNotFound
+	}
+	return price, err
+}
+
+func (r priceRepo) AddQuantity(ctx context.Context, priceId int, qty int) (decimal.Decimal, error) {
+	var updatedCost decimal.Decimal
+	err := r.q.QueryRowContext(ctx, `
+		UPDATE prices
+		SET quantity = (quantity + $2)
+		WHERE price_id = $1
+		RETURNING cost;
+		`, priceId, qty,
+	).Scan(&updatedCost)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	return updatedCost, nil
+}
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"inv_app/storage"
+	"time"
+)
+
+type exchangeRateRepo struct {
+	q querier
+}
+
+var rateSortColumns = map[string]string{
+	"currency":      "currency",
+	"effectiveDate": "effective_date",
+}
+
+func (r exchangeRateRepo) List(ctx context.Context, currency string, page storage.Page) ([]storage.ExchangeRate, int, error) {
+	list := listQuery{
+		query: `
+		SELECT currency, effective_date, rate, COUNT(*) OVER()
+		FROM exchange_rates
+		WHERE $1 = '' OR currency = $1
+		`,
+		args:         []any{currency},
+		sortColumns:  rateSortColumns,
+		defaultOrder: "effective_date DESC, currency ASC",
+	}
+
+	rates := []storage.ExchangeRate{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		var rate storage.ExchangeRate
+		var effectiveDate time.Time
+		if err := rows.Scan(&rate.Currency, &effectiveDate, &rate.Rate, total); err != nil {
+			return err
+		}
+		rate.EffectiveDate = effectiveDate.Format(time.DateOnly)
+		rates = append(rates, rate)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return rates, total, nil
+}
+
+func (r exchangeRateRepo) Upsert(ctx context.Context, rate storage.ExchangeRate) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO exchange_rates (currency, effective_date, rate)
+			VALUES ($1, $2, $3)
+		ON CONFLICT (currency, effective_date)
+			DO UPDATE SET rate = EXCLUDED.rate;
+		`, rate.Currency, rate.EffectiveDate, rate.Rate)
+	return err
+}
+
+func (r exchangeRateRepo) Missing(ctx context.Context, base string, date string) ([]string, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT DISTINCT p.currency
+		FROM prices p
+		WHERE p.currency <> $1 AND NOT EXISTS (
+			SELECT 1 FROM exchange_rates er
+			WHERE er.currency = p.currency AND er.effective_date <= $2::DATE
+		)
+		ORDER BY p.currency;
+		`, base, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	currencies := []string{}
+	for rows.Next() {
+		var currency string
+		if err := rows.Scan(&currency); err != nil {
+			return nil, err
+		}
+		currencies = append(currencies, currency)
+	}
+	return currencies, rows.Err()
+}
+
+func (r exchangeRateRepo) Effective(ctx context.Context, currency string, date string) (storage.ExchangeRate, error) {
+	rate := storage.ExchangeRate{Currency: currency}
+	var effectiveDate time.Time
+	err := r.q.QueryRowContext(ctx, `
+		SELECT effective_date, rate FROM exchange_rates
+		WHERE currency = $1 AND effective_date <= $2::DATE
+		ORDER BY effective_date DESC
+		LIMIT 1;
+		`, currency, date).Scan(&effectiveDate, &rate.Rate)
+	if err == sql.ErrNoRows {
+		return storage.ExchangeRate{}, storage.ErrNotFound
+	}
+	if err != nil {
+		return storage.ExchangeRate{}, err
+	}
+	rate.EffectiveDate = effectiveDate.Format(time.DateOnly)
+	return rate, nil
+}
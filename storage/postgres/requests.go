@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"inv_app/storage"
+)
+
+type requestRepo struct {
+	q querier
+}
+
+func (r requestRepo) Create(ctx context.Context, requests []storage.RequestedMaterial) error {
+	if len(requests) == 0 {
+		return nil
+	}
+
+	query := `
+	INSERT INTO requested_materials
+		(stock_id, description, quantity_requested, quantity_used, status, notes, updated_at, requested_at, user_id) VALUES `
+	args := []interface{}{}
+	placeholderCount := 1
+
+	for i, m := range requests {
+		if i > 0 {
+			query += ", "
+		}
+		query += fmt.Sprintf(
+			"($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			placeholderCount, placeholderCount+1,
+			placeholderCount+2, placeholderCount+3,
+			placeholderCount+4, placeholderCount+5,
+			placeholderCount+6, placeholderCount+7,
+			placeholderCount+8,
+		)
+
+		args = append(args, m.StockID, m.Description, m.Qty, 0, m.Status, m.Notes, m.RequestedAt, m.RequestedAt, nullableID(m.UserID))
+		placeholderCount += 9
+	}
+
+	_, err := r.q.ExecContext(ctx, query, args...)
+	return err
+}
+
+var requestSortColumns = map[string]string{
+	"requestId":         "rm.request_id",
+	"stockId":           "rm.stock_id",
+	"description":       "rm.description",
+	"status":            "rm.status",
+	"quantityRequested": "rm.quantity_requested",
+	"requestedAt":       "rm.requested_at",
+}
+
+func (r requestRepo) List(ctx context.Context, filterOpts storage.MaterialFilter, page storage.Page) ([]storage.MaterialDB, int, error) {
+	list := listQuery{
+		query: `
+		SELECT
+			request_id,
+			COALESCE(u.username, '') AS "username",
+			stock_id,
+			description,
+			quantity_requested,
+			quantity_used,
+			status,
+			notes,
+			updated_at,
+			requested_at,
+			COUNT(*) OVER()
+		FROM requested_materials rm
+		LEFT JOIN users u ON u.user_id = rm.user_id
+		WHERE ($1 = 0 OR rm.request_id = $1) AND
+		      ($2 = '' OR rm.stock_id ILIKE '%' || $2 || '%') AND
+			  ($3 = '' OR rm.status::TEXT = $3) AND
+			  ($4 = '' OR rm.requested_at::TEXT <= $4) AND
+			  ($5::INT[] IS NULL OR EXISTS (
+				SELECT 1 FROM materials m
+				WHERE m.stock_id = rm.stock_id AND m.customer_id = ANY($5)
+			  ))
+		`,
+		args: []any{
+			filterOpts.RequestId,
+			filterOpts.StockId,
+			filterOpts.Status,
+			filterOpts.RequestedAt,
+			customerScope(filterOpts.CustomerIDs),
+		},
+		sortColumns:  requestSortColumns,
+		defaultOrder: "rm.requested_at ASC, rm.request_id ASC",
+	}
+
+	materials := []storage.MaterialDB{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		var material storage.MaterialDB
+		if err := rows.Scan(
+			&material.RequestID,
+			&material.UserName,
+			&material.StockID,
+			&material.Description,
+			&material.QtyRequested,
+			&material.QtyUsed,
+			&material.Status,
+			&material.Notes,
+			&material.UpdatedAt,
+			&material.RequestedAt,
+			total,
+		); err != nil {
+			return err
+		}
+		materials = append(materials, material)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return materials, total, nil
+}
+
+func (r requestRepo) Update(ctx context.Context, request storage.RequestUpdate) error {
+	_, err := r.q.ExecContext(ctx, `
+		UPDATE requested_materials
+		SET quantity_used = quantity_used + $2,
+			status = $3,
+			notes = $4,
+			updated_at = $5
+		WHERE request_id = $1;
+	`, request.RequestID, request.QtyUsed, request.Status, request.Notes, request.UpdatedAt)
+	return err
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"inv_app/storage"
+
+	"github.com/lib/pq"
+)
+
+type roleRepo struct {
+	q querier
+}
+
+func (r roleRepo) List(ctx context.Context) ([]storage.RoleDB, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT r.name, r.description, r.customer_scoped,
+			COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role = r.name
+		GROUP BY r.name, r.description, r.customer_scoped
+		ORDER BY r.name;
+		`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []storage.RoleDB{}
+	for rows.Next() {
+		var role storage.RoleDB
+		if err := rows.Scan(&role.Name, &role.Description, &role.CustomerScoped, pq.Array(&role.Permissions)); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (r roleRepo) Permissions(ctx context.Context, role string) ([]string, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT permission FROM role_permissions
+		WHERE role = $1
+		ORDER BY permission;
+		`, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
+
+func (r roleRepo) ListPermissions(ctx context.Context) ([]storage.PermissionDB, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT name, description FROM permissions ORDER BY name;
+		`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []storage.PermissionDB{}
+	for rows.Next() {
+		var permission storage.PermissionDB
+		if err := rows.Scan(&permission.Name, &permission.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
+
+func (r roleRepo) IsCustomerScoped(ctx context.Context, role string) (bool, error) {
+	var scoped bool
+	err := r.q.QueryRowContext(ctx, `
+		SELECT customer_scoped FROM roles WHERE name = $1;
+		`, role).Scan(&scoped)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return scoped, err
+}
+
+func (r roleRepo) SetPermissions(ctx context.Context, role string, permissions []string) error {
+	var name string
+	err := r.q.QueryRowContext(ctx, `
+		SELECT name FROM roles WHERE name = $1 FOR UPDATE;
+		`, role).Scan(&name)
+	if err == sql.ErrNoRows {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = r.q.ExecContext(ctx, `
+		DELETE FROM role_permissions WHERE role = $1;
+		`, role)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO role_permissions (role, permission)
+		SELECT $1, unnest($2::VARCHAR[]);
+		`, role, pq.Array(permissions))
+	return err
+}
@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"inv_app/storage"
+
+	"github.com/lib/pq"
+)
+
+// sqlQuerier is implemented by both *sql.DB and *sql.Tx,
+// so the same Repository code runs either on the Pool or inside a Transaction.
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) row
+}
+
+type row interface {
+	Scan(dest ...any) error
+}
+
+// conn runs the statements of the Repositories and maps constraint violations to storage.ErrConflict.
+type conn struct {
+	q sqlQuerier
+}
+
+func (c conn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := c.q.ExecContext(ctx, query, args...)
+	return res, mapError(err)
+}
+
+func (c conn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := c.q.QueryContext(ctx, query, args...)
+	return rows, mapError(err)
+}
+
+func (c conn) QueryRowContext(ctx context.Context, query string, args ...any) row {
+	return errRow{c.q.QueryRowContext(ctx, query, args...)}
+}
+
+type errRow struct {
+	*sql.Row
+}
+
+func (r errRow) Scan(dest ...any) error {
+	return mapError(r.Row.Scan(dest...))
+}
+
+// Postgres error class of the integrity constraint violations (unique, foreign key, check)
+const integrityConstraintViolation = "23"
+
+func mapError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Class() == integrityConstraintViolation {
+		return fmt.Errorf("%w: %w", storage.ErrConflict, err)
+	}
+	return err
+}
+
+type repositories struct {
+	q querier
+}
+
+func (r repositories) Materials() storage.MaterialRepository       { return materialRepo{r.q} }
+func (r repositories) Prices() storage.PriceRepository             { return priceRepo{r.q} }
+func (r repositories) Transactions() storage.TransactionRepository { return transactionRepo{r.q} }
+func (r repositories) Incoming() storage.IncomingRepository        { return incomingRepo{r.q} }
+func (r repositories) Locations() storage.LocationRepository       { return locationRepo{r.q} }
+func (r repositories) Warehouses() storage.WarehouseRepository     { return warehouseRepo{r.q} }
+func (r repositories) Customers() storage.CustomerRepository       { return customerRepo{r.q} }
+func (r repositories) Requests() storage.RequestRepository         { return requestRepo{r.q} }
+func (r repositories) Users() storage.UserRepository               { return userRepo{r.q} }
+func (r repositories) Sessions() storage.SessionRepository         { return sessionRepo{r.q} }
+func (r repositories) Roles() storage.RoleRepository               { return roleRepo{r.q} }
+func (r repositories) Logins() storage.LoginRepository             { return loginRepo{r.q} }
+func (r repositories) APIKeys() storage.APIKeyRepository           { return apiKeyRepo{r.q} }
+func (r repositories) Operations() storage.OperationRepository     { return operationRepo{r.q} }
+func (r repositories) Audit() storage.AuditRepository              { return auditRepo{r.q} }
+func (r repositories) Costing() storage.CostingRepository          { return costingRepo{r.q} }
+func (r repositories) ExchangeRates() storage.ExchangeRateRepository {
+	return exchangeRateRepo{r.q}
+}
+func (r repositories) LandedCosts() storage.LandedCostRepository { return landedCostRepo{r.q} }
+
+// Store is the Postgres implementation of storage.Store on top of the shared DB Pool.
+type Store struct {
+	repositories
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Store {
+	return &Store{repositories: repositories{q: conn{db}}, db: db}
+}
+
+func (s *Store) Begin(ctx context.Context) (storage.Tx, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{repositories: repositories{q: conn{tx}}, tx: tx}, nil
+}
+
+// Postgres error codes of the Transactions aborted due to concurrent ones
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// The method reports whether the Transaction failed due to a concurrent one and can be run again.
+func (s *Store) IsConflict(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == serializationFailure || pqErr.Code == deadlockDetected
+	}
+	return false
+}
+
+type Tx struct {
+	repositories
+	tx *sql.Tx
+}
+
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// Scans a single ID column of every returned row and keeps the last one, 0 if there are no rows.
+func scanID(rows *sql.Rows, err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var id int
+	for rows.Next() {
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+	}
+	return id, rows.Err()
+}
+
+// Same as QueryRow(...).Scan(&id), but no rows result in 0 instead of an error.
+func findID(r row) (int, error) {
+	var id int
+	err := r.Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+var _ storage.Store = (*Store)(nil)
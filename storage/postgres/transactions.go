@@ -0,0 +1,435 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"inv_app/storage"
+	"time"
+)
+
+type transactionRepo struct {
+	q querier
+}
+
+func (r transactionRepo) LockChain(ctx context.Context) error {
+	_, err := r.q.ExecContext(ctx, `
+		SELECT 1 FROM transactions_log_head FOR UPDATE;
+		`)
+	return err
+}
+
+func (r transactionRepo) Add(ctx context.Context, trx storage.Transaction) error {
+	// The head row lock keeps the chain in the commit order of concurrent Transactions
+	err := r.q.QueryRowContext(ctx, `
+		SELECT row_hash FROM transactions_log_head FOR UPDATE;
+		`).Scan(&trx.PrevHash)
+	if err != nil {
+		return err
+	}
+	err = r.q.QueryRowContext(ctx, `
+		SELECT nextval(pg_get_serial_sequence('transactions_log', 'transaction_id'));
+		`).Scan(&trx.TransactionID)
+	if err != nil {
+		return err
+	}
+	trx.Hash = storage.TransactionHash(trx)
+
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO transactions_log (
+				transaction_id, price_id, quantity_change, notes, job_ticket, updated_at,
+				serial_number_range, user_id, approved_by, transaction_type, from_location_id, to_location_id,
+				shipping_id, request_id, correlation_id, reverses_id, prev_hash, row_hash
+			)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, '')::TRANSACTION_TYPE, $11, $12, $13, $14, $15, $16, $17, $18);
+		`, trx.TransactionID, trx.PriceID, trx.Qty, trx.Notes, trx.JobTicket, trx.UpdatedAt.Format(time.DateOnly),
+		trx.SerialNumberRange, nullableID(trx.UserID), nullableID(trx.ApprovedBy), trx.Type,
+		nullableID(trx.FromLocationID), nullableID(trx.ToLocationID), nullableID(trx.ShippingID), nullableID(trx.RequestID),
+		trx.CorrelationID, nullableID(trx.ReversesID), trx.PrevHash, trx.Hash)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.q.ExecContext(ctx, `
+		UPDATE transactions_log_head SET transaction_id = $1, row_hash = $2;
+		`, trx.TransactionID, trx.Hash)
+	return err
+}
+
+const transactionColumns = `
+	transaction_id, price_id, quantity_change, COALESCE(notes, ''), COALESCE(job_ticket, ''),
+	updated_at, COALESCE(serial_number_range, ''), COALESCE(user_id, 0), COALESCE(approved_by, 0),
+	COALESCE(transaction_type::TEXT, ''), COALESCE(from_location_id, 0), COALESCE(to_location_id, 0),
+	COALESCE(shipping_id, 0), COALESCE(request_id, 0), correlation_id, COALESCE(reverses_id, 0),
+	prev_hash, row_hash`
+
+func (r transactionRepo) Chain(ctx context.Context, afterId int, limit int) ([]storage.Transaction, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+transactionColumns+`
+		FROM transactions_log
+		WHERE transaction_id > $1
+		ORDER BY transaction_id
+		LIMIT $2;
+		`, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanTransactions(rows)
+}
+
+func (r transactionRepo) ListCorrelated(ctx context.Context, transactionId int) ([]storage.Transaction, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+transactionColumns+`
+		FROM transactions_log
+		WHERE transaction_id = $1 OR correlation_id = (
+			SELECT correlation_id FROM transactions_log
+			WHERE transaction_id = $1 AND correlation_id <> ''
+		)
+		ORDER BY transaction_id;
+		`, transactionId)
+	if err != nil {
+		return nil, err
+	}
+	trxList, err := scanTransactions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(trxList) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	return trxList, nil
+}
+
+func (r transactionRepo) ListByPrice(ctx context.Context, priceId int) ([]storage.Transaction, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+transactionColumns+`
+		FROM transactions_log
+		WHERE price_id = $1
+		ORDER BY transaction_id;
+		`, priceId)
+	if err != nil {
+		return nil, err
+	}
+	return scanTransactions(rows)
+}
+
+func (r transactionRepo) ListReceipts(ctx context.Context, shippingId int) ([]storage.Transaction, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT `+transactionColumns+`
+		FROM transactions_log
+		WHERE shipping_id = $1 AND transaction_type = 'receipt'
+		ORDER BY transaction_id;
+		`, shippingId)
+	if err != nil {
+		return nil, err
+	}
+	return scanTransactions(rows)
+}
+
+func (r transactionRepo) ReversalOf(ctx context.Context, transactionId int) (int, error) {
+	var reversalId int
+	err := r.q.QueryRowContext(ctx, `
+		SELECT transaction_id FROM transactions_log WHERE reverses_id = $1;
+		`, transactionId).Scan(&reversalId)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return reversalId, err
+}
+
+func scanTransactions(rows *sql.Rows) ([]storage.Transaction, error) {
+	defer rows.Close()
+
+	trxList := []storage.Transaction{}
+	for rows.Next() {
+		var trx storage.Transaction
+		var updatedAt sql.NullTime
+		if err := rows.Scan(
+			&trx.TransactionID,
+			&trx.PriceID,
+			&trx.Qty,
+			&trx.Notes,
+			&trx.JobTicket,
+			&updatedAt,
+			&trx.SerialNumberRange,
+			&trx.UserID,
+			&trx.ApprovedBy,
+			&trx.Type,
+			&trx.FromLocationID,
+			&trx.ToLocationID,
+			&trx.ShippingID,
+			&trx.RequestID,
+			&trx.CorrelationID,
+			&trx.ReversesID,
+			&trx.PrevHash,
+			&trx.Hash,
+		); err != nil {
+			return nil, err
+		}
+		trx.UpdatedAt = updatedAt.Time
+		trxList = append(trxList, trx)
+	}
+	return trxList, rows.Err()
+}
+
+func (r transactionRepo) ChainHead(ctx context.Context) (int, string, error) {
+	var transactionId int
+	var hash string
+	err := r.q.QueryRowContext(ctx, `
+		SELECT transaction_id, row_hash FROM transactions_log_head;
+		`).Scan(&transactionId, &hash)
+	if err != nil {
+		return 0, "", err
+	}
+	return transactionId, hash, nil
+}
+
+// The lateral "r" is the rate converting the cost of the Price "p" to the report Currency (the argument currencyArg)
+// on the rate date (dateArg), 1 if the Currency is empty or the same. The rate is NULL if there is none effective on the date.
+func rateJoin(currencyArg int, dateArg int) string {
+	return fmt.Sprintf(`
+		LEFT JOIN LATERAL (
+			SELECT CASE WHEN $%[1]d::TEXT = '' OR p.currency::TEXT = $%[1]d::TEXT THEN 1 ELSE (
+				SELECT er.rate FROM exchange_rates er
+				WHERE er.currency = p.currency AND er.effective_date <= NULLIF($%[2]d::TEXT, '')::DATE
+				ORDER BY er.effective_date DESC
+				LIMIT 1
+			) END AS rate
+		) r ON TRUE`, currencyArg, dateArg)
+}
+
+// The currency of the report row: the report Currency (the argument currencyArg) or the one of the Price "p"
+func rowCurrency(currencyArg int) string {
+	return fmt.Sprintf("COALESCE(NULLIF($%d::TEXT, ''), p.currency::TEXT)", currencyArg)
+}
+
+// Converted values of the report rows, the same as the original ones at the rate 1
+const (
+	convertedUnitCost = "ROUND(p.cost * r.rate, 4)"
+	convertedValue    = "ROUND(ROUND(tl.quantity_change * p.cost, 2) * r.rate, 2)"
+	// The part of the value from the landed charges
+	convertedLandedValue = "ROUND(ROUND(tl.quantity_change * p.landed_cost, 2) * r.rate, 2)"
+)
+
+var transactionSortColumns = map[string]string{
+	"stockId":      "m.stock_id",
+	"materialType": "m.material_type",
+	"quantity":     "tl.quantity_change",
+	"unitCost":     convertedUnitCost,
+	"cost":         convertedValue,
+	"date":         "tl.updated_at",
+}
+
+func (r transactionRepo) Report(ctx context.Context, filter storage.ReportFilter, page storage.Page) ([]storage.TransactionRecord, int, error) {
+	list := listQuery{
+		query: `SELECT
+					m.stock_id,
+					m.material_type,
+					COALESCE(tl.transaction_type::TEXT, ''),
+					tl.quantity_change as "quantity",
+					` + convertedUnitCost + ` as "unit_cost",
+					` + convertedValue + ` as "cost",
+					` + convertedLandedValue + ` as "landed_cost",
+					` + rowCurrency(7) + ` as "currency",
+					tl.updated_at,
+					COALESCE(tl.serial_number_range, ''),
+					COUNT(*) OVER()
+				 FROM transactions_log tl
+				 LEFT JOIN prices p ON p.price_id = tl.price_id
+				 LEFT JOIN materials m ON m.material_id = p.material_id
+				 LEFT JOIN customers c ON m.customer_id = c.customer_id
+				 ` + rateJoin(7, 8) + `
+				 WHERE
+					($1 = 0 OR m.customer_id = $1) AND
+					($2 = '' OR m.material_type::TEXT = $2) AND
+					($3 = '' OR tl.updated_at::TEXT >= $3) AND
+					($4 = '' OR tl.updated_at::TEXT <= $4) AND
+					($5 = '' OR m.owner::TEXT = $5) AND
+					($6::INT[] IS NULL OR m.customer_id = ANY($6))
+				`,
+		args: []any{
+			filter.CustomerId, filter.MaterialType, filter.DateFrom, filter.DateTo, filter.Owner,
+			customerScope(filter.CustomerIDs), filter.Currency, filter.RateDate,
+		},
+		sortColumns:  transactionSortColumns,
+		defaultOrder: "tl.transaction_id ASC",
+	}
+
+	trxList := []storage.TransactionRecord{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		trx := storage.TransactionRecord{}
+		if err := rows.Scan(
+			&trx.StockID,
+			&trx.MaterialType,
+			&trx.Type,
+			&trx.Qty,
+			&trx.UnitCost,
+			&trx.Cost,
+			&trx.LandedCost,
+			&trx.Currency,
+			&trx.UpdatedAt,
+			&trx.SerialNumberRange,
+			total,
+		); err != nil {
+			return err
+		}
+		trxList = append(trxList, trx)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return trxList, total, nil
+}
+
+var balanceSortColumns = map[string]string{
+	"stockId":      "m.stock_id",
+	"description":  "m.description",
+	"materialType": "m.material_type",
+	"quantity":     "SUM(tl.quantity_change)",
+	"totalValue":   "SUM(" + convertedValue + ")",
+}
+
+func (r transactionRepo) Balance(ctx context.Context, filter storage.ReportFilter, page storage.Page) ([]storage.BalanceRecord, int, error) {
+	list := listQuery{
+		query: `
+		SELECT m.stock_id,
+			m.description,
+			m.material_type,
+			SUM(tl.quantity_change) AS "quantity",
+			SUM(` + convertedValue + `) AS "total_value",
+			SUM(` + convertedLandedValue + `) AS "landed_value",
+			` + rowCurrency(6) + ` AS "currency",
+			COUNT(*) OVER()
+		FROM transactions_log tl
+		LEFT JOIN prices p ON p.price_id = tl.price_id
+		LEFT JOIN materials m ON m.material_id = p.material_id
+		` + rateJoin(6, 7) + `
+		WHERE
+			($1 = 0 OR m.customer_id = $1) AND
+			($2 = '' OR m.material_type::TEXT = $2) AND
+			($3 = '' OR tl.updated_at::TEXT <= $3) AND
+			($4 = '' OR m.owner::TEXT = $4) AND
+			($5::INT[] IS NULL OR m.customer_id = ANY($5)) AND
+			m.location_id IS NOT NULL
+		GROUP BY m.stock_id, m.description, m.material_type, ` + rowCurrency(6) + `
+		`,
+		args: []any{
+			filter.CustomerId, filter.MaterialType, filter.DateAsOf, filter.Owner, customerScope(filter.CustomerIDs),
+			filter.Currency, filter.RateDate,
+		},
+		sortColumns:  balanceSortColumns,
+		defaultOrder: "m.material_type ASC, m.description ASC, m.stock_id ASC, " + rowCurrency(6) + " ASC",
+	}
+
+	blcList := []storage.BalanceRecord{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		balance := storage.BalanceRecord{}
+		if err := rows.Scan(
+			&balance.StockID,
+			&balance.Description,
+			&balance.MaterialType,
+			&balance.Qty,
+			&balance.TotalValue,
+			&balance.LandedValue,
+			&balance.Currency,
+			total,
+		); err != nil {
+			return err
+		}
+		blcList = append(blcList, balance)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return blcList, total, nil
+}
+
+var historySortColumns = map[string]string{
+	"transactionId": "h.transaction_id",
+	"date":          "h.updated_at",
+}
+
+func (r transactionRepo) History(ctx context.Context, materialId int, page storage.Page) ([]storage.HistoryRecord, int, error) {
+	list := listQuery{
+		query: `
+		SELECT h.*, COUNT(*) OVER()
+		FROM (
+			SELECT
+				tl.transaction_id,
+				COALESCE(tl.transaction_type::TEXT, '') AS transaction_type,
+				tl.quantity_change,
+				p.cost AS unit_cost,
+				ROUND(tl.quantity_change * p.cost, 2) AS value,
+				p.landed_cost,
+				p.currency,
+				SUM(tl.quantity_change) OVER running AS quantity_balance,
+				SUM(ROUND(tl.quantity_change * p.cost, 2)) OVER (
+					PARTITION BY p.currency ORDER BY tl.transaction_id
+				) AS value_balance,
+				COALESCE(tl.notes, '') AS notes,
+				COALESCE(tl.job_ticket, '') AS job_ticket,
+				COALESCE(tl.serial_number_range, '') AS serial_number_range,
+				tl.updated_at,
+				COALESCE(tl.user_id, 0) AS user_id,
+				COALESCE(u.username, '') AS username,
+				COALESCE(tl.approved_by, 0) AS approved_by,
+				COALESCE(fl.name, '') AS from_location,
+				COALESCE(tol.name, '') AS to_location,
+				COALESCE(tl.shipping_id, 0) AS shipping_id,
+				COALESCE(tl.request_id, 0) AS request_id,
+				tl.correlation_id
+			FROM transactions_log tl
+			JOIN prices p ON p.price_id = tl.price_id
+			LEFT JOIN users u ON u.user_id = tl.user_id
+			LEFT JOIN locations fl ON fl.location_id = tl.from_location_id
+			LEFT JOIN locations tol ON tol.location_id = tl.to_location_id
+			WHERE p.material_id = $1
+			WINDOW running AS (ORDER BY tl.transaction_id)
+		) h
+		`,
+		args:         []any{materialId},
+		sortColumns:  historySortColumns,
+		defaultOrder: "h.transaction_id ASC",
+	}
+
+	history := []storage.HistoryRecord{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		var record storage.HistoryRecord
+		var updatedAt sql.NullTime
+		if err := rows.Scan(
+			&record.TransactionID,
+			&record.Type,
+			&record.Qty,
+			&record.UnitCost,
+			&record.Value,
+			&record.LandedCost,
+			&record.Currency,
+			&record.QtyBalance,
+			&record.ValueBalance,
+			&record.Notes,
+			&record.JobTicket,
+			&record.SerialNumberRange,
+			&updatedAt,
+			&record.UserID,
+			&record.Username,
+			&record.ApprovedBy,
+			&record.FromLocation,
+			&record.ToLocation,
+			&record.ShippingID,
+			&record.RequestID,
+			&record.CorrelationID,
+			total,
+		); err != nil {
+			return err
+		}
+		record.UpdatedAt = updatedAt.Time
+		history = append(history, record)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return history, total, nil
+}
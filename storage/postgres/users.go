@@ -0,0 +1,199 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"inv_app/storage"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type userRepo struct {
+	q querier
+}
+
+func (r userRepo) Get(ctx context.Context, userId int) (storage.UserDB, error) {
+	return scanUser(r.q.QueryRowContext(ctx, `
+		SELECT user_id, username, password, role, is_active, is_service FROM users WHERE user_id = $1;
+		`, userId))
+}
+
+func (r userRepo) FindByUsername(ctx context.Context, username string) (storage.UserDB, error) {
+	return scanUser(r.q.QueryRowContext(ctx, `
+		SELECT user_id, username, password, role, is_active, is_service FROM users WHERE username = $1;
+		`, username))
+}
+
+var userSortColumns = map[string]string{
+	"userId":   "user_id",
+	"username": "username",
+	"role":     "role",
+}
+
+func (r userRepo) List(ctx context.Context, page storage.Page) ([]storage.UserDB, int, error) {
+	list := listQuery{
+		query:        "SELECT user_id, username, role, is_active, is_service, COUNT(*) OVER() FROM users",
+		sortColumns:  userSortColumns,
+		defaultOrder: "username ASC, user_id ASC",
+	}
+
+	users := []storage.UserDB{}
+	total, err := queryPage(ctx, r.q, list, page, func(rows *sql.Rows, total *int) error {
+		var user storage.UserDB
+		if err := rows.Scan(&user.UserID, &user.Username, &user.Role, &user.IsActive, &user.IsService, total); err != nil {
+			return err
+		}
+		users = append(users, user)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r userRepo) Create(ctx context.Context, user storage.UserDB) (int, error) {
+	var userId int
+	err := r.q.QueryRowContext(ctx, `
+		INSERT INTO users (username, password, role, is_active, is_service)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING user_id;`,
+		user.Username, user.Password, user.Role, user.IsActive, user.IsService,
+	).Scan(&userId)
+	if err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
+
+func (r userRepo) SetPassword(ctx context.Context, userId int, passwordHash string) error {
+	return r.update(ctx, `
+		UPDATE users
+		SET password = $2
+		WHERE user_id = $1;
+		`, userId, passwordHash)
+}
+
+func (r userRepo) SetRole(ctx context.Context, userId int, role string) error {
+	return r.update(ctx, `
+		UPDATE users
+		SET role = $2
+		WHERE user_id = $1;
+		`, userId, role)
+}
+
+func (r userRepo) SetActive(ctx context.Context, userId int, isActive bool) error {
+	return r.update(ctx, `
+		UPDATE users
+		SET is_active = $2
+		WHERE user_id = $1;
+		`, userId, isActive)
+}
+
+// Returns ErrNotFound if there is no such User
+func (r userRepo) update(ctx context.Context, query string, userId int, value any) error {
+	res, err := r.q.ExecContext(ctx, query, userId, value)
+	if err != nil {
+		return err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func scanUser(r row) (storage.UserDB, error) {
+	var user storage.UserDB
+	err := r.Scan(&user.UserID, &user.Username, &user.Password, &user.Role, &user.IsActive, &user.IsService)
+	if err == sql.ErrNoRows {
+		return storage.UserDB{}, storage.ErrNotFound
+	}
+	if err != nil {
+		return storage.UserDB{}, err
+	}
+	return user, nil
+}
+
+type sessionRepo struct {
+	q querier
+}
+
+func (r sessionRepo) Create(ctx context.Context, session storage.Session) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO sessions (token_hash, user_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4);
+		`, session.TokenHash, session.UserID, session.CreatedAt, session.ExpiresAt)
+	return err
+}
+
+func (r sessionRepo) GetUser(ctx context.Context, tokenHash string, now time.Time) (storage.UserDB, error) {
+	return scanUser(r.q.QueryRowContext(ctx, `
+		SELECT u.user_id, u.username, u.password, u.role, u.is_active, u.is_service
+		FROM sessions s
+		JOIN users u ON u.user_id = s.user_id
+		WHERE s.token_hash = $1 AND s.expires_at > $2 AND u.is_active;
+		`, tokenHash, now))
+}
+
+func (r sessionRepo) Delete(ctx context.Context, tokenHash string) error {
+	_, err := r.q.ExecContext(ctx, `
+		DELETE FROM sessions WHERE token_hash = $1;
+		`, tokenHash)
+	return err
+}
+
+func (r sessionRepo) DeleteForUser(ctx context.Context, userId int, keepTokenHash string) error {
+	_, err := r.q.ExecContext(ctx, `
+		DELETE FROM sessions WHERE user_id = $1 AND token_hash <> $2;
+		`, userId, keepTokenHash)
+	return err
+}
+
+func (r sessionRepo) DeleteExpired(ctx context.Context, now time.Time) error {
+	_, err := r.q.ExecContext(ctx, `
+		DELETE FROM sessions WHERE expires_at <= $1;
+		`, now)
+	return err
+}
+
+func (r userRepo) CustomerIDs(ctx context.Context, userId int) ([]int, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT customer_id FROM user_customers
+		WHERE user_id = $1
+		ORDER BY customer_id;
+		`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	customerIds := []int{}
+	for rows.Next() {
+		var customerId int
+		if err := rows.Scan(&customerId); err != nil {
+			return nil, err
+		}
+		customerIds = append(customerIds, customerId)
+	}
+	return customerIds, rows.Err()
+}
+
+func (r userRepo) SetCustomers(ctx context.Context, userId int, customerIds []int) error {
+	_, err := r.q.ExecContext(ctx, `
+		DELETE FROM user_customers WHERE user_id = $1;
+		`, userId)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.q.ExecContext(ctx, `
+		INSERT INTO user_customers (user_id, customer_id)
+		SELECT DISTINCT $1, unnest($2::INT[]);
+		`, userId, pq.Array(customerIds))
+	return err
+}
@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// The package describes how the Business Logic talks to the Storage.
+// Services depend on these Repositories only, the Postgres implementation lives in storage/postgres
+// and the in-memory one (used by tests) in storage/memory.
+
+var ErrNotFound = errors.New("Record not found")
+
+// ErrConflict is matched by the errors of statements violating a unique, foreign key or check constraint
+// and of Transactions still failing due to concurrent ones after all attempts.
+var ErrConflict = errors.New("Conflict with the current data")
+
+// Store gives access to the Repositories outside of a DB Transaction and opens new Transactions.
+type Store interface {
+	Repositories
+	Begin(ctx context.Context) (Tx, error)
+}
+
+// Tx is a DB Transaction. Repositories taken from it run all their statements inside the Transaction.
+type Tx interface {
+	Repositories
+	Commit() error
+	Rollback() error
+}
+
+type Repositories interface {
+	Materials() MaterialRepository
+	Prices() PriceRepository
+	Transactions() TransactionRepository
+	Incoming() IncomingRepository
+	Locations() LocationRepository
+	Warehouses() WarehouseRepository
+	Customers() CustomerRepository
+	Requests() RequestRepository
+	Users() UserRepository
+	Sessions() SessionRepository
+	Roles() RoleRepository
+	Logins() LoginRepository
+	APIKeys() APIKeyRepository
+	Operations() OperationRepository
+	Audit() AuditRepository
+	Costing() CostingRepository
+	ExchangeRates() ExchangeRateRepository
+	LandedCosts() LandedCostRepository
+}
+
+type MaterialRepository interface {
+	// Returns the Page of Materials and the total number of the filtered ones
+	List(ctx context.Context, filter MaterialFilter, page Page) ([]MaterialDB, int, error)
+	// Returns ErrNotFound if there is no such Material
+	Get(ctx context.Context, materialId int) (MaterialDB, error)
+	// Same as Get, but also locks the Material row until the end of the Transaction,
+	// so concurrent stock operations on the same Material are applied one by one
+	GetForUpdate(ctx context.Context, materialId int) (MaterialDB, error)
+	// Returns 0 if there is no Material with the Stock ID and Owner in the Location
+	FindAtLocation(ctx context.Context, stockId string, locationId int, owner string) (int, error)
+	// Returns 0 if there is no Material with the Stock ID and Owner without a Location
+	FindUnplaced(ctx context.Context, stockId string, owner string) (int, error)
+	Create(ctx context.Context, material MaterialDB) (int, error)
+	// Changes the Material quantity by the given (positive or negative) value
+	AddQuantity(ctx context.Context, materialId int, qty int) error
+	UpdateNotes(ctx context.Context, materialId int, notes string) error
+	// Assigns a Location to the Material without one
+	Place(ctx context.Context, materialId int, locationId int, qty int, notes string) error
+	// Releases the Material Location once its quantity is fully moved or removed
+	Unplace(ctx context.Context, materialId int) error
+	SetPrimary(ctx context.Context, materialId int, isPrimary bool) error
+	Types(ctx context.Context) ([]string, error)
+	// Returns the description of a Material with the Stock ID of the Customers (any if nil), ErrNotFound if there is none
+	Description(ctx context.Context, stockId string, customerIds []int) (string, error)
+}
+
+type PriceRepository interface {
+	// Returns the Prices with a remaining quantity, the oldest receipt first and then by Price ID
+	ListAvailable(ctx context.Context, materialId int) ([]Price, error)
+	// Same as ListAvailable, but also locks the Price rows until the end of the Transaction
+	ListAvailableForUpdate(ctx context.Context, materialId int) ([]Price, error)
+	// Adds a cost layer, layers with the same Material and Cost are never merged
+	Create(ctx context.Context, price Price) (int, error)
+	// Changes the Price quantity by the given value and returns the Price cost
+	AddQuantity(ctx context.Context, priceId int, qty int) (decimal.Decimal, error)
+	// Returns ErrNotFound if there is no such Price, the row stays locked until the end of the Transaction
+	GetForUpdate(ctx context.Context, priceId int) (Price, error)
+}
+
+type TransactionRepository interface {
+	// Locks the head of the chain until the end of the Transaction. A Transaction adding Transaction Logs
+	// takes it before locking any other row, so concurrent stock changes wait for each other at the start
+	// instead of deadlocking on the head while holding their Material rows.
+	LockChain(ctx context.Context) error
+	// Chains the Transaction Log to the last one, it has to be called inside a Transaction
+	// so the rows are chained in the order they are committed
+	Add(ctx context.Context, trx Transaction) error
+	// Returns the Page of the Transaction Logs of the Material, oldest first by default, and their total number.
+	// The balances are running totals in the order of the Transaction Logs, whatever the Page order is.
+	History(ctx context.Context, materialId int, page Page) ([]HistoryRecord, int, error)
+	// Returns the Transaction Log with the ones sharing its correlation ID ordered by ID,
+	// ErrNotFound if there is no such Transaction Log
+	ListCorrelated(ctx context.Context, transactionId int) ([]Transaction, error)
+	// Returns the Transaction Logs of the Price ordered by ID
+	ListByPrice(ctx context.Context, priceId int) ([]Transaction, error)
+	// Returns the receipt Transaction Logs of the Incoming Material ordered by ID
+	ListReceipts(ctx context.Context, shippingId int) ([]Transaction, error)
+	// Returns the ID of the reversal of the Transaction Log, 0 if it is not reversed
+	ReversalOf(ctx context.Context, transactionId int) (int, error)
+	// Returns up to limit Transaction Logs after the ID in the order of the chain
+	Chain(ctx context.Context, afterId int, limit int) ([]Transaction, error)
+	// Returns the ID and the hash of the last chained Transaction Log, 0 and "" if there is none
+	ChainHead(ctx context.Context) (int, string, error)
+	Report(ctx context.Context, filter ReportFilter, page Page) ([]TransactionRecord, int, error)
+	Balance(ctx context.Context, filter ReportFilter, page Page) ([]BalanceRecord, int, error)
+}
+
+type IncomingRepository interface {
+	Create(ctx context.Context, material IncomingMaterialDB) (int, error)
+	// Returns ErrNotFound if there is no such Incoming Material
+	Get(ctx context.Context, shippingId int) (IncomingMaterialDB, error)
+	// Same as Get, but also locks the row until the end of the Transaction
+	GetForUpdate(ctx context.Context, shippingId int) (IncomingMaterialDB, error)
+	// Returns all Incoming Materials still incoming if the Shipping ID is 0, the received ones
+	// are kept with no quantity for their landed charges but not listed
+	List(ctx context.Context, filter IncomingFilter, page Page) ([]IncomingMaterialDB, int, error)
+	Update(ctx context.Context, material IncomingMaterialDB) error
+	AddQuantity(ctx context.Context, shippingId int, qty int) error
+	// Adds the landed charges per unit to the landed cost and their value to the landed value
+	AddLandedCost(ctx context.Context, shippingId int, unitCost decimal.Decimal, value decimal.Decimal) error
+}
+
+type LocationRepository interface {
+	List(ctx context.Context, page Page) ([]LocationDB, int, error)
+	// Returns empty Locations and the ones keeping the same Stock ID and Owner
+	ListAvailable(ctx context.Context, filter LocationFilter, page Page) ([]LocationDB, int, error)
+	// Returns 0 if there is no such Location in the Warehouse
+	Find(ctx context.Context, name string, warehouseId int) (int, error)
+	Create(ctx context.Context, name string, warehouseId int) (int, error)
+}
+
+type WarehouseRepository interface {
+	List(ctx context.Context, page Page) ([]WarehouseDB, int, error)
+	// Returns 0 if there is no such Warehouse
+	Find(ctx context.Context, name string) (int, error)
+	Create(ctx context.Context, name string) (int, error)
+}
+
+type CustomerRepository interface {
+	List(ctx context.Context, filter CustomerFilter, page Page) ([]CustomerDB, int, error)
+	// Returns 0 if there is no such Customer
+	Find(ctx context.Context, name string, code string) (int, error)
+	Create(ctx context.Context, name string, code string) (int, error)
+}
+
+type RequestRepository interface {
+	Create(ctx context.Context, requests []RequestedMaterial) error
+	List(ctx context.Context, filter MaterialFilter, page Page) ([]MaterialDB, int, error)
+	Update(ctx context.Context, request RequestUpdate) error
+}
+
+type UserRepository interface {
+	// Returns ErrNotFound if there is no such User
+	Get(ctx context.Context, userId int) (UserDB, error)
+	// Returns ErrNotFound if there is no such User
+	FindByUsername(ctx context.Context, username string) (UserDB, error)
+	List(ctx context.Context, page Page) ([]UserDB, int, error)
+	Create(ctx context.Context, user UserDB) (int, error)
+	SetPassword(ctx context.Context, userId int, passwordHash string) error
+	SetRole(ctx context.Context, userId int, role string) error
+	SetActive(ctx context.Context, userId int, isActive bool) error
+	// Returns the IDs of the Customers the User is bound to, ordered
+	CustomerIDs(ctx context.Context, userId int) ([]int, error)
+	// Replaces the Customers the User is bound to
+	SetCustomers(ctx context.Context, userId int, customerIds []int) error
+}
+
+type SessionRepository interface {
+	Create(ctx context.Context, session Session) error
+	// Returns the active User of the Session not expired at the given time or ErrNotFound
+	GetUser(ctx context.Context, tokenHash string, now time.Time) (UserDB, error)
+	Delete(ctx context.Context, tokenHash string) error
+	// Deletes all Sessions of the User except the one with the given token hash (if any)
+	DeleteForUser(ctx context.Context, userId int, keepTokenHash string) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+type RoleRepository interface {
+	// Returns the Roles with their Permissions ordered by name
+	List(ctx context.Context) ([]RoleDB, error)
+	// Returns the Permission names of the Role, none if there is no such Role
+	Permissions(ctx context.Context, role string) ([]string, error)
+	ListPermissions(ctx context.Context) ([]PermissionDB, error)
+	// Returns false if there is no such Role
+	IsCustomerScoped(ctx context.Context, role string) (bool, error)
+	// Replaces the Permissions of the Role, returns ErrNotFound if there is no such Role
+	SetPermissions(ctx context.Context, role string, permissions []string) error
+}
+
+// The audit log is append-only, there is no way to change or delete an Entry
+type AuditRepository interface {
+	Add(ctx context.Context, entry AuditEntry) error
+	// Returns the Page of Entries, the latest first by default, and the total number of the filtered ones
+	List(ctx context.Context, filter AuditFilter, page Page) ([]AuditEntry, int, error)
+}
+
+type LoginRepository interface {
+	// Returns ErrNotFound if the key has no failed logins.
+	// Inside a Transaction the row stays locked until it ends.
+	GetThrottle(ctx context.Context, key string) (LoginThrottle, error)
+	// Inserts or replaces the Throttle of the key
+	SaveThrottle(ctx context.Context, throttle LoginThrottle) error
+	DeleteThrottle(ctx context.Context, key string) error
+	AddAttempt(ctx context.Context, attempt LoginAttempt) error
+	// Returns the Page of login Attempts, the latest first by default, and the total number of the filtered ones
+	ListAttempts(ctx context.Context, filter LoginFilter, page Page) ([]LoginAttempt, int, error)
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key APIKey) (int, error)
+	// Returns the Keys of the User, revoked ones included, the latest first
+	List(ctx context.Context, userId int) ([]APIKey, error)
+	// Returns the Key and its active User if the Key is neither revoked nor expired at the given time, or ErrNotFound
+	GetUser(ctx context.Context, keyHash string, now time.Time) (APIKey, UserDB, error)
+	SetLastUsed(ctx context.Context, keyId int, now time.Time) error
+	// Returns ErrNotFound if there is no such Key or it is already revoked
+	Revoke(ctx context.Context, keyId int, now time.Time) error
+}
+
+type OperationRepository interface {
+	// Returns the material types whose moves and removals need a second User
+	DualControlTypes(ctx context.Context) ([]string, error)
+	SetDualControlTypes(ctx context.Context, materialTypes []string) error
+	Create(ctx context.Context, operation PendingOperation) (int, error)
+	// Returns ErrNotFound if there is no such Operation.
+	// The Operation row stays locked until the end of the Transaction.
+	GetForUpdate(ctx context.Context, operationId int) (PendingOperation, error)
+	// Returns the Page of Operations with their Material, the oldest first, and the total number of the filtered ones
+	List(ctx context.Context, filter OperationFilter, page Page) ([]PendingOperation, int, error)
+	// Sets the status of a pending Operation, returns ErrNotFound if it is not pending
+	Decide(ctx context.Context, operationId int, status string, decidedBy int, decidedAt time.Time) error
+}
+
+type CostingRepository interface {
+	// Returns the rules ordered by Owner and Customer
+	Rules(ctx context.Context) ([]CostingRule, error)
+	// Replaces all rules
+	SetRules(ctx context.Context, rules []CostingRule) error
+}
+
+type ExchangeRateRepository interface {
+	// Returns the Page of the rates of the Currency (all if empty), the latest first, and the total number of them
+	List(ctx context.Context, currency string, page Page) ([]ExchangeRate, int, error)
+	// Adds the rate or replaces the one of the same Currency and effective date
+	Upsert(ctx context.Context, rate ExchangeRate) error
+	// Returns the currencies of the Prices other than the base one without a rate effective on the date
+	Missing(ctx context.Context, base string, date string) ([]string, error)
+	// Returns the rate of the Currency effective on the date, ErrNotFound if there is none
+	Effective(ctx context.Context, currency string, date string) (ExchangeRate, error)
+}
+
+type LandedCostRepository interface {
+	// Adds the charge with its Allocations and returns its ID
+	Create(ctx context.Context, charge LandedCharge) (int, error)
+	// Returns the Page of the charges with their Allocations, the latest first, and the total number of them.
+	// Only the charges allocated to the shipment if the Shipping ID is not 0.
+	List(ctx context.Context, shippingId int, page Page) ([]LandedCharge, int, error)
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// Number of attempts for a Transaction failed by a concurrent one (deadlock or serialization failure)
+const maxTxAttempts = 3
+
+// conflictChecker is implemented by the Stores able to tell a conflict between concurrent Transactions.
+// Such Transactions are safe to run again from the start.
+type conflictChecker interface {
+	IsConflict(err error) bool
+}
+
+// The method runs fn inside a single DB Transaction.
+// The Transaction is committed only if fn returns nil, any error or panic rolls it back.
+// A Transaction aborted due to a conflict with a concurrent one is retried, so fn must not keep any state between the calls.
+func WithTx(ctx context.Context, store Store, fn func(tx Tx) error) error {
+	checker, canRetry := store.(conflictChecker)
+
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = runTx(ctx, store, fn)
+		if err == nil || !canRetry || !checker.IsConflict(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: %w", ErrConflict, err)
+}
+
+func runTx(ctx context.Context, store Store, fn func(tx Tx) error) error {
+	tx, err := store.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}